@@ -82,6 +82,8 @@ type BestArena struct {
 	Head    *Chunk
 	Current *Chunk
 	Offset  int
+
+	keep []any
 }
 
 func NewBestArena() *BestArena {
@@ -132,6 +134,14 @@ func (a *BestArena) grow(requiredSize int) {
 func (a *BestArena) Reset() {
 	a.Current = a.Head
 	a.Offset = 0
+	a.keep = nil
+}
+
+// Keep holds on to a heap value until Reset. The garbage collector does not
+// see pointers stored in arena memory, so anything on the heap that arena
+// memory points to must be kept this way.
+func (a *BestArena) Keep(v any) {
+	a.keep = append(a.keep, v)
 }
 
 func (a *BestArena) AllocUnsafe(size, align int) unsafe.Pointer {
@@ -146,3 +156,28 @@ func (a *BestArena) AllocUnsafe(size, align int) unsafe.Pointer {
 	a.Offset += size
 	return ptr
 }
+
+// AllocSlice returns a slice of n elements carved from the arena. The
+// elements are not zeroed when the memory is reused after Reset.
+func AllocSlice[T any](a *BestArena, n int) []T {
+	if n == 0 {
+		return []T{}
+	}
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	align := int(unsafe.Alignof(zero))
+	ptr := a.AllocUnsafe(size*n, align)
+	return unsafe.Slice((*T)(ptr), n)
+}
+
+// String copies s into the arena, so the copy lives exactly as long as the
+// arena's memory and does not keep s's backing array alive.
+func (a *BestArena) String(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	ptr := a.AllocUnsafe(len(s), 1)
+	buf := unsafe.Slice((*byte)(ptr), len(s))
+	copy(buf, s)
+	return unsafe.String((*byte)(ptr), len(s))
+}
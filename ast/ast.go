@@ -3,12 +3,22 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"mcompiler/intern"
 	"mcompiler/token"
 )
 
+// Node is implemented by every piece of syntax. Pos is the position of its
+// first byte and End the position just past its last one.
+//
+// A statement's extent leaves out the `;` that ends it, and the
+// parentheses around an expression belong to no node: in `(a + b) * c;`
+// the sum spans `a + b`, the product `a + b) * c` and the statement, which
+// starts at its first token, `(a + b) * c`.
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Pos
+	End() token.Pos
 }
 
 type Statement interface {
@@ -21,8 +31,45 @@ type Expression interface {
 	expressionNode()
 }
 
+// TypeExpression is the syntax of a type: a name such as `int`, or a
+// function type such as `fn(int, bool) => int`.
+type TypeExpression interface {
+	Node
+	typeNode()
+}
+
+// CustomExpression and CustomStatement are embedded by node types defined
+// outside this package, such as those built by parser extensions. Their Pos
+// and End report Span unless the embedding type defines its own.
+type CustomExpression struct {
+	Span Span
+}
+
+func (CustomExpression) expressionNode()  {}
+func (c CustomExpression) Pos() token.Pos { return c.Span.Start }
+func (c CustomExpression) End() token.Pos { return c.Span.End }
+
+type CustomStatement struct {
+	Span Span
+}
+
+func (CustomStatement) statementNode()   {}
+func (c CustomStatement) Pos() token.Pos { return c.Span.Start }
+func (c CustomStatement) End() token.Pos { return c.Span.End }
+
+// Span is the source range [Start, End) of a piece of syntax.
+type Span struct {
+	Start token.Pos
+	End   token.Pos
+}
+
+// Program's Source and Spans are recorded by the parser for incremental
+// reparsing: Spans[i] is the extent of Statements[i] within Source, from
+// its Pos to its End.
 type Program struct {
 	Statements []Statement
+	Source     string
+	Spans      []Span
 }
 
 func (p *Program) TokenLiteral() string {
@@ -39,6 +86,18 @@ func (p *Program) String() string {
 	}
 	return out.String()
 }
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) == 0 {
+		return token.Pos{}
+	}
+	return p.Statements[0].Pos()
+}
+func (p *Program) End() token.Pos {
+	if len(p.Statements) == 0 {
+		return token.Pos{}
+	}
+	return p.Statements[len(p.Statements)-1].End()
+}
 
 type ExpressionStatement struct {
 	Token      token.Token
@@ -57,10 +116,15 @@ func (es *ExpressionStatement) String() string {
 	out.WriteString(";")
 	return out.String()
 }
+func (es *ExpressionStatement) Pos() token.Pos { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Pos { return endOf(es.Expression, es.Token) }
 
+// LetStatement's Type is the annotation of Name, as in `let n: int = 1;`,
+// or nil if it has none.
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
+	Type  TypeExpression
 	Value Expression
 }
 
@@ -74,6 +138,10 @@ func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": ")
+		out.WriteString(ls.Type.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -83,6 +151,16 @@ func (ls *LetStatement) String() string {
 	out.WriteString(";")
 	return out.String()
 }
+func (ls *LetStatement) Pos() token.Pos { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Pos {
+	if ls.Value == nil && ls.Type != nil {
+		return ls.Type.End()
+	}
+	if ls.Value == nil && ls.Name != nil {
+		return ls.Name.End()
+	}
+	return endOf(ls.Value, ls.Token)
+}
 
 type BooleanLiteral struct {
 	Token token.Token
@@ -96,10 +174,15 @@ func (bl *BooleanLiteral) TokenLiteral() string {
 }
 
 func (bl *BooleanLiteral) String() string { return bl.TokenLiteral() }
+func (bl *BooleanLiteral) Pos() token.Pos { return bl.Token.Pos }
+func (bl *BooleanLiteral) End() token.Pos { return bl.Token.End() }
 
+// Identifier's Symbol is intern.NoSymbol unless the source was parsed with
+// an interner.
 type Identifier struct {
-	Token token.Token
-	Value string
+	Token  token.Token
+	Value  string
+	Symbol intern.Symbol
 }
 
 func (i *Identifier) expressionNode() {}
@@ -109,6 +192,8 @@ func (i *Identifier) TokenLiteral() string {
 }
 
 func (i *Identifier) String() string { return i.Value }
+func (i *Identifier) Pos() token.Pos { return i.Token.Pos }
+func (i *Identifier) End() token.Pos { return i.Token.End() }
 
 type IntegerLiteral struct {
 	Token token.Token
@@ -121,6 +206,8 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 func (il *IntegerLiteral) String() string { return fmt.Sprintf("%d", il.Value) }
+func (il *IntegerLiteral) Pos() token.Pos { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Pos { return il.Token.End() }
 
 type ReturnStatement struct {
 	Token token.Token
@@ -142,6 +229,8 @@ func (rs *ReturnStatement) String() string {
 	out.WriteString(";")
 	return out.String()
 }
+func (rs *ReturnStatement) Pos() token.Pos { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Pos { return endOf(rs.Value, rs.Token) }
 
 type UnaryExpression struct {
 	Token token.Token
@@ -159,6 +248,8 @@ func (ue *UnaryExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+func (ue *UnaryExpression) Pos() token.Pos { return ue.Token.Pos }
+func (ue *UnaryExpression) End() token.Pos { return endOf(ue.Right, ue.Token) }
 
 type BinaryExpression struct {
 	Token token.Token
@@ -185,10 +276,76 @@ func (be *BinaryExpression) String() string {
 
 	return out.String()
 }
+func (be *BinaryExpression) Pos() token.Pos { return posOf(be.Left, be.Token) }
+func (be *BinaryExpression) End() token.Pos { return endOf(be.Right, be.Token) }
+
+type AssignExpression struct {
+	Token token.Token
+	Name  *Identifier
+	Value Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Name.String())
+	out.WriteString(" = ")
+	if ae.Value != nil {
+		out.WriteString(ae.Value.String())
+	} else {
+		out.WriteString("nil")
+	}
+	out.WriteString(")")
+	return out.String()
+}
+func (ae *AssignExpression) Pos() token.Pos {
+	if ae.Name == nil {
+		return ae.Token.Pos
+	}
+	return ae.Name.Pos()
+}
+func (ae *AssignExpression) End() token.Pos { return endOf(ae.Value, ae.Token) }
+
+// PipeExpression is `Left |> Right`: Left is passed as the first argument
+// of the call described by Right.
+type PipeExpression struct {
+	Token token.Token
+	Left  Expression
+	Right Expression
+}
+
+func (pe *PipeExpression) expressionNode() {}
+func (pe *PipeExpression) TokenLiteral() string {
+	return pe.Token.Literal
+}
+
+func (pe *PipeExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(pe.Left.String())
+	out.WriteString(" |> ")
+	if pe.Right != nil {
+		out.WriteString(pe.Right.String())
+	} else {
+		out.WriteString("nil")
+	}
+	out.WriteString(")")
+	return out.String()
+}
+func (pe *PipeExpression) Pos() token.Pos { return posOf(pe.Left, pe.Token) }
+func (pe *PipeExpression) End() token.Pos { return endOf(pe.Right, pe.Token) }
 
+// BlockStatement's Rbrace is the position of the closing brace, or the zero
+// Pos if the block was not closed.
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Rbrace     token.Pos
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -204,6 +361,16 @@ func (bs *BlockStatement) String() string {
 	out.WriteString("}")
 	return out.String()
 }
+func (bs *BlockStatement) Pos() token.Pos { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Pos {
+	if bs.Rbrace.Line != 0 {
+		return token.Token{Literal: "}", Pos: bs.Rbrace}.End()
+	}
+	if n := len(bs.Statements); n > 0 {
+		return endOf(bs.Statements[n-1], bs.Token)
+	}
+	return bs.Token.End()
+}
 
 type IfStatement struct {
 	Token       token.Token
@@ -228,10 +395,23 @@ func (is *IfStatement) String() string {
 	}
 	return out.String()
 }
+func (is *IfStatement) Pos() token.Pos { return is.Token.Pos }
+func (is *IfStatement) End() token.Pos {
+	if is.Alternative != nil {
+		return is.Alternative.End()
+	}
+	if is.Consequence != nil {
+		return is.Consequence.End()
+	}
+	return endOf(is.Condition, is.Token)
+}
 
+// FunctionInvokeExpression's Rparen is the position of the closing
+// parenthesis, or the zero Pos if the call was not closed.
 type FunctionInvokeExpression struct {
 	Token     token.Token
 	Arguments []Expression
+	Rparen    token.Pos
 }
 
 func (fie *FunctionInvokeExpression) expressionNode() {}
@@ -250,11 +430,46 @@ func (fie *FunctionInvokeExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
-
+func (fie *FunctionInvokeExpression) Pos() token.Pos { return fie.Token.Pos }
+func (fie *FunctionInvokeExpression) End() token.Pos {
+	if fie.Rparen.Line != 0 {
+		return token.Token{Literal: ")", Pos: fie.Rparen}.End()
+	}
+	if n := len(fie.Arguments); n > 0 {
+		return endOf(fie.Arguments[n-1], fie.Token)
+	}
+	return fie.Token.End()
+}
+
+// FunctionExpression is `fn(params) { body }`. The shorthand `fn(params) => expr`
+// is desugared into a body holding a single return statement whose token is
+// the `=>`; Arrow records that the shorthand was used.
+//
+// ParameterTypes holds the annotations of the parameters, as in
+// `fn(x: int, y)`: nil if none has one, otherwise one for each parameter,
+// nil where it has none.
+//
+// A parser in lazy mode leaves Body nil and sets Lazy instead, along with
+// the source and closing brace of the skipped body; use ParseBody to get the
+// body either way.
 type FunctionExpression struct {
-	Token      token.Token
-	Parameters []Identifier
-	Body       Statement
+	Token          token.Token
+	Parameters     []Identifier
+	ParameterTypes []TypeExpression
+	Body           Statement
+	Arrow          bool
+	Lazy           func() Statement
+	LazySource     string    // the skipped body, from `{` to `}`
+	LazyRbrace     token.Pos // the `}` of the skipped body
+}
+
+// ParseBody returns the body, parsing it first if it was skipped.
+func (fs *FunctionExpression) ParseBody() Statement {
+	if fs.Lazy != nil {
+		fs.Body = fs.Lazy()
+		fs.Lazy, fs.LazySource, fs.LazyRbrace = nil, "", token.Pos{}
+	}
+	return fs.Body
 }
 
 func (fs *FunctionExpression) expressionNode() {}
@@ -266,11 +481,175 @@ func (fs *FunctionExpression) String() string {
 	out.WriteString(fs.TokenLiteral() + "(")
 	for i, param := range fs.Parameters {
 		out.WriteString(param.String())
+		if typ := fs.ParameterType(i); typ != nil {
+			out.WriteString(": ")
+			out.WriteString(typ.String())
+		}
 		if i < len(fs.Parameters)-1 {
 			out.WriteString(", ")
 		}
 	}
 	out.WriteString(")")
-	out.WriteString(fs.Body.String())
+	if ret, ok := fs.arrowResult(); ok {
+		out.WriteString(" => ")
+		out.WriteString(ret.String())
+		return out.String()
+	}
+	if fs.Lazy != nil {
+		// A skipped body is printed as it was written.
+		out.WriteString(fs.LazySource)
+	} else if fs.Body != nil {
+		out.WriteString(fs.Body.String())
+	}
+	return out.String()
+}
+func (fs *FunctionExpression) Pos() token.Pos { return fs.Token.Pos }
+func (fs *FunctionExpression) End() token.Pos {
+	if fs.Lazy != nil {
+		return token.Token{Literal: "}", Pos: fs.LazyRbrace}.End()
+	}
+	return endOf(fs.Body, fs.Token)
+}
+
+// ParameterType returns the annotation of parameter i, or nil if it has
+// none.
+func (fs *FunctionExpression) ParameterType(i int) TypeExpression {
+	if i < len(fs.ParameterTypes) {
+		return fs.ParameterTypes[i]
+	}
+	return nil
+}
+
+func (fs *FunctionExpression) arrowResult() (Expression, bool) {
+	if !fs.Arrow {
+		return nil, false
+	}
+	body, ok := fs.Body.(*BlockStatement)
+	if !ok || len(body.Statements) != 1 {
+		return nil, false
+	}
+	ret, ok := body.Statements[0].(*ReturnStatement)
+	if !ok || ret.Value == nil {
+		return nil, false
+	}
+	return ret.Value, true
+}
+
+// NamedType is a name such as `int`, or a name applied to arguments such as
+// `array(int)`. Rparen is the position of the closing parenthesis, or the
+// zero Pos if there are no arguments or they were not closed.
+type NamedType struct {
+	Token     token.Token
+	Name      string
+	Arguments []TypeExpression
+	Rparen    token.Pos
+}
+
+func (nt *NamedType) typeNode() {}
+func (nt *NamedType) TokenLiteral() string {
+	return nt.Token.Literal
+}
+func (nt *NamedType) String() string {
+	if nt.Arguments == nil {
+		return nt.Name
+	}
+	var out bytes.Buffer
+	out.WriteString(nt.Name + "(")
+	for i, arg := range nt.Arguments {
+		out.WriteString(arg.String())
+		if i < len(nt.Arguments)-1 {
+			out.WriteString(", ")
+		}
+	}
+	out.WriteString(")")
 	return out.String()
 }
+func (nt *NamedType) Pos() token.Pos { return nt.Token.Pos }
+func (nt *NamedType) End() token.Pos {
+	if nt.Rparen.Line != 0 {
+		return token.Token{Literal: ")", Pos: nt.Rparen}.End()
+	}
+	if n := len(nt.Arguments); n > 0 {
+		return endOf(nt.Arguments[n-1], nt.Token)
+	}
+	return nt.Token.End()
+}
+
+type FunctionType struct {
+	Token      token.Token
+	Parameters []TypeExpression
+	Result     TypeExpression
+}
+
+func (ft *FunctionType) typeNode() {}
+func (ft *FunctionType) TokenLiteral() string {
+	return ft.Token.Literal
+}
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+	out.WriteString(ft.TokenLiteral() + "(")
+	for i, param := range ft.Parameters {
+		out.WriteString(param.String())
+		if i < len(ft.Parameters)-1 {
+			out.WriteString(", ")
+		}
+	}
+	out.WriteString(") => ")
+	out.WriteString(ft.Result.String())
+	return out.String()
+}
+func (ft *FunctionType) Pos() token.Pos { return ft.Token.Pos }
+func (ft *FunctionType) End() token.Pos { return endOf(ft.Result, ft.Token) }
+
+// MissingExpression, MissingStatement and MissingType stand in for syntax a
+// tolerant parser expected but did not find. Token is where it was expected
+// and Span covers the tokens skipped in its place; the span is empty when
+// nothing was skipped.
+type MissingExpression struct {
+	Token token.Token
+	Span  Span
+}
+
+func (me *MissingExpression) expressionNode()      {}
+func (me *MissingExpression) TokenLiteral() string { return "" }
+func (me *MissingExpression) String() string       { return "<missing>" }
+func (me *MissingExpression) Pos() token.Pos       { return me.Span.Start }
+func (me *MissingExpression) End() token.Pos       { return me.Span.End }
+
+type MissingStatement struct {
+	Token token.Token
+	Span  Span
+}
+
+func (ms *MissingStatement) statementNode()       {}
+func (ms *MissingStatement) TokenLiteral() string { return "" }
+func (ms *MissingStatement) String() string       { return "<missing>" }
+func (ms *MissingStatement) Pos() token.Pos       { return ms.Span.Start }
+func (ms *MissingStatement) End() token.Pos       { return ms.Span.End }
+
+type MissingType struct {
+	Token token.Token
+	Span  Span
+}
+
+func (mt *MissingType) typeNode()            {}
+func (mt *MissingType) TokenLiteral() string { return "" }
+func (mt *MissingType) String() string       { return "<missing>" }
+func (mt *MissingType) Pos() token.Pos       { return mt.Span.Start }
+func (mt *MissingType) End() token.Pos       { return mt.Span.End }
+
+// posOf returns the start of n, or of tok if n is nil.
+func posOf(n Node, tok token.Token) token.Pos {
+	if n == nil {
+		return tok.Pos
+	}
+	return n.Pos()
+}
+
+// endOf returns the end of n, or of tok if n is nil.
+func endOf(n Node, tok token.Token) token.Pos {
+	if n == nil {
+		return tok.End()
+	}
+	return n.End()
+}
@@ -0,0 +1,165 @@
+package ast
+
+import (
+	"fmt"
+	"mcompiler/arena"
+	"mcompiler/token"
+	"strings"
+)
+
+// Cloner is implemented by nodes defined outside this package to let Clone
+// copy them. CloneNode should copy its children with Clone and a.
+type Cloner interface {
+	CloneNode(a *arena.BestArena) Node
+}
+
+// Clone returns a deep copy of the tree rooted at node. When a is not nil
+// the copy's nodes, lists and strings are carved from a and are valid until
+// a is reset; otherwise they live on the Go heap. Either way the copy shares
+// no memory with node, so it stays valid when the arena node was parsed
+// into is reset. Function bodies skipped by a lazy parser are parsed first,
+// since parsing them later would allocate into the original's arena.
+//
+// Clone panics on a node defined outside this package that does not
+// implement Cloner.
+func Clone[N Node](node N, a *arena.BestArena) N {
+	c := &cloner{arena: a}
+	return cloneNode(c, node)
+}
+
+type cloner struct {
+	arena *arena.BestArena
+}
+
+func cloneNode[N Node](c *cloner, node N) N {
+	if Node(node) == nil {
+		return node
+	}
+	return c.node(node).(N)
+}
+
+// cloneAlloc returns a pointer to a copy of v, in the cloner's arena if it
+// has one.
+func cloneAlloc[T any](c *cloner, v T) *T {
+	var n *T
+	if c.arena == nil {
+		n = new(T)
+	} else {
+		n = arena.Alloc[T](c.arena)
+	}
+	*n = v
+	return n
+}
+
+func cloneList[T any](c *cloner, list []T, elem func(T) T) []T {
+	if list == nil {
+		return nil
+	}
+	var out []T
+	if c.arena == nil {
+		out = make([]T, len(list))
+	} else {
+		out = arena.AllocSlice[T](c.arena, len(list))
+	}
+	for i, v := range list {
+		out[i] = elem(v)
+	}
+	return out
+}
+
+func cloneNodes[N Node](c *cloner, list []N) []N {
+	return cloneList(c, list, func(n N) N { return cloneNode(c, n) })
+}
+
+func (c *cloner) string(s string) string {
+	if c.arena != nil {
+		return c.arena.String(s)
+	}
+	return strings.Clone(s)
+}
+
+func (c *cloner) token(t token.Token) token.Token {
+	if typ, ok := tokenTypes[string(t.Type)]; ok {
+		t.Type = typ
+	} else {
+		t.Type = token.TokenType(c.string(string(t.Type)))
+	}
+	t.Literal = c.string(t.Literal)
+	return t
+}
+
+func (c *cloner) identifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return cloneAlloc(c, c.identifierValue(*ident))
+}
+
+func (c *cloner) identifierValue(ident Identifier) Identifier {
+	ident.Token = c.token(ident.Token)
+	ident.Value = c.string(ident.Value)
+	return ident
+}
+
+func (c *cloner) node(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		return cloneAlloc(c, Program{
+			Statements: cloneNodes(c, n.Statements),
+			Source:     c.string(n.Source),
+			Spans:      cloneList(c, n.Spans, func(s Span) Span { return s }),
+		})
+	case *ExpressionStatement:
+		return cloneAlloc(c, ExpressionStatement{Token: c.token(n.Token), Expression: cloneNode(c, n.Expression)})
+	case *LetStatement:
+		return cloneAlloc(c, LetStatement{Token: c.token(n.Token), Name: c.identifier(n.Name), Type: cloneNode(c, n.Type), Value: cloneNode(c, n.Value)})
+	case *ReturnStatement:
+		return cloneAlloc(c, ReturnStatement{Token: c.token(n.Token), Value: cloneNode(c, n.Value)})
+	case *BlockStatement:
+		return cloneAlloc(c, BlockStatement{Token: c.token(n.Token), Statements: cloneNodes(c, n.Statements), Rbrace: n.Rbrace})
+	case *IfStatement:
+		return cloneAlloc(c, IfStatement{
+			Token:       c.token(n.Token),
+			Condition:   cloneNode(c, n.Condition),
+			Consequence: cloneNode(c, n.Consequence),
+			Alternative: cloneNode(c, n.Alternative),
+		})
+	case *UnaryExpression:
+		return cloneAlloc(c, UnaryExpression{Token: c.token(n.Token), Right: cloneNode(c, n.Right)})
+	case *BinaryExpression:
+		return cloneAlloc(c, BinaryExpression{Token: c.token(n.Token), Left: cloneNode(c, n.Left), Right: cloneNode(c, n.Right)})
+	case *AssignExpression:
+		return cloneAlloc(c, AssignExpression{Token: c.token(n.Token), Name: c.identifier(n.Name), Value: cloneNode(c, n.Value)})
+	case *PipeExpression:
+		return cloneAlloc(c, PipeExpression{Token: c.token(n.Token), Left: cloneNode(c, n.Left), Right: cloneNode(c, n.Right)})
+	case *FunctionInvokeExpression:
+		return cloneAlloc(c, FunctionInvokeExpression{Token: c.token(n.Token), Arguments: cloneNodes(c, n.Arguments), Rparen: n.Rparen})
+	case *FunctionExpression:
+		return cloneAlloc(c, FunctionExpression{
+			Token:          c.token(n.Token),
+			Parameters:     cloneList(c, n.Parameters, c.identifierValue),
+			ParameterTypes: cloneNodes(c, n.ParameterTypes),
+			Body:           cloneNode(c, n.ParseBody()),
+			Arrow:          n.Arrow,
+		})
+	case *Identifier:
+		return c.identifier(n)
+	case *IntegerLiteral:
+		return cloneAlloc(c, IntegerLiteral{Token: c.token(n.Token), Value: n.Value})
+	case *BooleanLiteral:
+		return cloneAlloc(c, BooleanLiteral{Token: c.token(n.Token), Value: n.Value})
+	case *NamedType:
+		return cloneAlloc(c, NamedType{Token: c.token(n.Token), Name: c.string(n.Name), Arguments: cloneNodes(c, n.Arguments), Rparen: n.Rparen})
+	case *FunctionType:
+		return cloneAlloc(c, FunctionType{Token: c.token(n.Token), Parameters: cloneNodes(c, n.Parameters), Result: cloneNode(c, n.Result)})
+	case *MissingExpression:
+		return cloneAlloc(c, MissingExpression{Token: c.token(n.Token), Span: n.Span})
+	case *MissingStatement:
+		return cloneAlloc(c, MissingStatement{Token: c.token(n.Token), Span: n.Span})
+	case *MissingType:
+		return cloneAlloc(c, MissingType{Token: c.token(n.Token), Span: n.Span})
+	case Cloner:
+		return n.CloneNode(c.arena)
+	}
+	panic(fmt.Sprintf("ast.Clone: cannot clone %T", node))
+}
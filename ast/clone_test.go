@@ -0,0 +1,82 @@
+package ast_test
+
+import (
+	"mcompiler/arena"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"testing"
+)
+
+func (c *custom) CloneNode(a *arena.BestArena) ast.Node {
+	return &custom{CustomExpression: c.CustomExpression, Inner: ast.Clone(c.Inner, a)}
+}
+
+// A clone outlives the arena the original was parsed into, whichever arena
+// it is cloned into.
+func TestClone(t *testing.T) {
+	for _, name := range []string{"heap", "arena"} {
+		var into *arena.BestArena
+		if name == "arena" {
+			into = arena.NewBestArena()
+		}
+		from := arena.NewBestArena()
+		program := parse(t, equalSource, parser.Options{Arena: from})
+		clone := ast.Clone(program, into)
+
+		original := map[ast.Node]bool{}
+		ast.Inspect(program, func(n ast.Node) bool {
+			original[n] = true
+			return true
+		})
+		ast.Inspect(clone, func(n ast.Node) bool {
+			if n != nil && original[n] {
+				t.Errorf("%s: clone shares %T with the original", name, n)
+			}
+			return true
+		})
+
+		from.Reset()
+		parse(t, "let overwritten = 123456789 * 987654321;", parser.Options{Arena: from})
+		want := parse(t, equalSource, parser.Options{})
+		if !ast.EqualPositions(clone, want) {
+			t.Errorf("%s: clone = %q, want %q", name, clone.String(), want.String())
+		}
+		if clone.Source != equalSource || len(clone.Spans) != len(want.Spans) {
+			t.Errorf("%s: clone lost the source of the program", name)
+		}
+	}
+}
+
+func TestClone_LazyBodies(t *testing.T) {
+	program := parse(t, equalSource, parser.Options{LazyBodies: true})
+	clone := ast.Clone(program, nil)
+	ast.InspectType(clone, func(fn *ast.FunctionExpression) bool {
+		if fn.Lazy != nil || fn.Body == nil {
+			t.Errorf("clone of %s has a lazy body", fn)
+		}
+		return true
+	})
+	if !ast.Equal(clone, program) {
+		t.Errorf("clone = %q, want %q", clone.String(), program.String())
+	}
+}
+
+func TestClone_Subtrees(t *testing.T) {
+	var expr ast.Expression
+	if ast.Clone(expr, nil) != nil {
+		t.Errorf("clone of a nil expression is not nil")
+	}
+
+	tolerant := parser.NewWithOptions(lexer.New("let x = ;"), parser.Options{Tolerant: true}).ParseProgram()
+	let := tolerant.Statements[0].(*ast.LetStatement)
+	if value := ast.Clone(let.Value, nil); !ast.EqualPositions(value, let.Value) {
+		t.Errorf("clone of %s differs", let.Value)
+	}
+
+	c := &custom{Inner: &ast.IntegerLiteral{Value: 1}}
+	cc := ast.Clone(c, nil)
+	if cc == c || cc.Inner == c.Inner || !ast.Equal(cc, c) {
+		t.Errorf("custom node was not copied through CloneNode")
+	}
+}
@@ -0,0 +1,372 @@
+// Package compact stores an AST as flat, typed arrays instead of a graph of
+// interface values. A node is a fixed 16-byte record that refers to its
+// children by index; variable-length child lists live in a shared uint32
+// array. Trees convert to and from the pointer AST in package ast, so passes
+// can move over one at a time.
+package compact
+
+import (
+	"fmt"
+	"mcompiler/token"
+	"unsafe"
+)
+
+// NodeID indexes Tree.Nodes. The zero NodeID is reserved to mean "no node".
+type NodeID uint32
+
+const None NodeID = 0
+
+type Kind uint8
+
+const (
+	KindInvalid Kind = iota
+	KindProgram
+	KindExpressionStatement
+	KindLet
+	KindReturn
+	KindBlock
+	KindIf
+	KindIdentifier
+	KindInteger
+	KindBoolean
+	KindUnary
+	KindBinary
+	KindAssign
+	KindPipe
+	KindCall
+	KindFunction
+	KindNamedType
+	KindFunctionType
+)
+
+const (
+	// FlagArrow marks a KindFunction written as `fn(...) => expr`.
+	FlagArrow uint8 = 1 << iota
+	// FlagTyped marks a KindLet or KindFunction with type annotations.
+	FlagTyped
+	// FlagArguments marks a KindNamedType written with an argument list,
+	// even an empty one.
+	FlagArguments
+	// FlagClosed marks a KindBlock, KindCall or KindNamedType whose closing
+	// `}` or `)` is recorded, in Tokens right after its main token.
+	FlagClosed
+)
+
+// Node is one AST node. What LHS and RHS hold depends on Kind:
+//
+//	Program, Block        LHS: first child in Extra   RHS: child count
+//	ExpressionStatement   LHS: expression
+//	Let                   LHS: name identifier        RHS: value, or with FlagTyped Extra[RHS] type, Extra[RHS+1] value
+//	Return                LHS: value, or None
+//	If                    LHS: condition              RHS: Extra[RHS] consequence, Extra[RHS+1] alternative
+//	Identifier            LHS: index into Strings     RHS: intern.Symbol
+//	Integer               LHS: index into Ints
+//	Boolean               LHS: 0 or 1
+//	Unary                 LHS: operand
+//	Binary, Assign, Pipe  LHS: left                   RHS: right
+//	Call                  LHS: first argument in Extra  RHS: argument count
+//	Function              LHS: first parameter in Extra RHS: parameter count; the body follows the parameters,
+//	                      and with FlagTyped the type of each parameter, or None, follows the body
+//	NamedType             LHS: first argument in Extra  RHS: argument count; the name is the token's literal
+//	FunctionType          LHS: first parameter in Extra RHS: parameter count; the result follows the parameters
+type Node struct {
+	Kind  Kind
+	Flags uint8
+	Token uint32
+	LHS   uint32
+	RHS   uint32
+}
+
+// Token is a node's main token, with its type and literal held in the tree's
+// tables.
+type Token struct {
+	Type   uint16
+	Lit    uint32
+	Offset uint32
+	Line   uint32
+	Column uint32
+}
+
+type Tree struct {
+	Nodes   []Node
+	Extra   []uint32
+	Tokens  []Token
+	Types   []token.TokenType
+	Strings []string
+	Ints    []int64
+	Root    NodeID
+}
+
+func (t *Tree) Node(id NodeID) Node {
+	return t.Nodes[id]
+}
+
+// Token rebuilds the main token of a node.
+func (t *Tree) Token(id NodeID) token.Token {
+	tok := t.Tokens[t.Nodes[id].Token]
+	return token.Token{
+		Type:    t.Types[tok.Type],
+		Literal: t.Strings[tok.Lit],
+		Pos:     token.Pos{Offset: int(tok.Offset), Line: int(tok.Line), Column: int(tok.Column)},
+	}
+}
+
+// Closing returns the position of the closing `}` or `)` of a node with
+// FlagClosed, or the zero position.
+func (t *Tree) Closing(id NodeID) token.Pos {
+	n := t.Nodes[id]
+	if n.Flags&FlagClosed == 0 {
+		return token.Pos{}
+	}
+	tok := t.Tokens[n.Token+1]
+	return token.Pos{Offset: int(tok.Offset), Line: int(tok.Line), Column: int(tok.Column)}
+}
+
+// List returns the child list stored at Extra[start:start+n].
+func (t *Tree) List(start, n uint32) []NodeID {
+	ids := make([]NodeID, n)
+	for i := range ids {
+		ids[i] = NodeID(t.Extra[start+uint32(i)])
+	}
+	return ids
+}
+
+// Children appends the direct children of id to buf in source order.
+func (t *Tree) Children(id NodeID, buf []NodeID) []NodeID {
+	n := t.Nodes[id]
+	switch n.Kind {
+	case KindProgram, KindBlock, KindCall, KindNamedType:
+		for i := uint32(0); i < n.RHS; i++ {
+			buf = append(buf, NodeID(t.Extra[n.LHS+i]))
+		}
+	case KindFunction:
+		for i := uint32(0); i < n.RHS; i++ {
+			buf = append(buf, NodeID(t.Extra[n.LHS+i]))
+			if n.Flags&FlagTyped != 0 && t.Extra[n.LHS+n.RHS+1+i] != 0 {
+				buf = append(buf, NodeID(t.Extra[n.LHS+n.RHS+1+i]))
+			}
+		}
+		buf = append(buf, NodeID(t.Extra[n.LHS+n.RHS]))
+	case KindFunctionType:
+		for i := uint32(0); i <= n.RHS; i++ {
+			buf = append(buf, NodeID(t.Extra[n.LHS+i]))
+		}
+	case KindLet:
+		if n.Flags&FlagTyped != 0 {
+			buf = append(buf, NodeID(n.LHS), NodeID(t.Extra[n.RHS]))
+			if value := t.Extra[n.RHS+1]; value != 0 {
+				buf = append(buf, NodeID(value))
+			}
+			break
+		}
+		buf = append(buf, NodeID(n.LHS))
+		if n.RHS != 0 {
+			buf = append(buf, NodeID(n.RHS))
+		}
+	case KindExpressionStatement, KindReturn, KindUnary:
+		if n.LHS != 0 {
+			buf = append(buf, NodeID(n.LHS))
+		}
+	case KindBinary, KindAssign, KindPipe:
+		buf = append(buf, NodeID(n.LHS))
+		if n.RHS != 0 {
+			buf = append(buf, NodeID(n.RHS))
+		}
+	case KindIf:
+		buf = append(buf, NodeID(n.LHS), NodeID(t.Extra[n.RHS]))
+		if alt := t.Extra[n.RHS+1]; alt != 0 {
+			buf = append(buf, NodeID(alt))
+		}
+	}
+	return buf
+}
+
+// Validate checks that the tree is laid out as FromAST lays it out, so that
+// ToAST and Children can read it without going out of range: the root is
+// the only KindProgram, and every node has a known kind, refers only to
+// nodes before it and of the kinds its own kind allows there, and indexes
+// the tables and Extra within their bounds.
+func (t *Tree) Validate() error {
+	if t.Root == None || int(t.Root) >= len(t.Nodes) || t.Nodes[t.Root].Kind != KindProgram {
+		return fmt.Errorf("compact: root %d is not a program", t.Root)
+	}
+	for _, tok := range t.Tokens {
+		if int(tok.Type) >= len(t.Types) || int(tok.Lit) >= len(t.Strings) {
+			return fmt.Errorf("compact: token out of range")
+		}
+	}
+	for id := NodeID(1); int(id) < len(t.Nodes); id++ {
+		if err := t.validNode(id); err != nil {
+			return fmt.Errorf("compact: node %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// A role is what a child stands for in its parent, and decides the kinds it
+// may have.
+type role uint8
+
+const (
+	roleStatement role = iota
+	roleExpression
+	roleIdentifier
+	roleType
+)
+
+func (t *Tree) validNode(id NodeID) error {
+	n := t.Nodes[id]
+	if int(n.Token) >= len(t.Tokens) || n.Flags&FlagClosed != 0 && int(n.Token)+1 >= len(t.Tokens) {
+		return fmt.Errorf("token out of range")
+	}
+	// child checks a child ID, which None passes unless required is set.
+	child := func(c uint32, r role, required bool) error {
+		if c == 0 && !required {
+			return nil
+		}
+		if c == 0 || c >= uint32(id) {
+			return fmt.Errorf("child %d out of order", c)
+		}
+		kind := t.Nodes[c].Kind
+		var ok bool
+		switch r {
+		case roleStatement:
+			ok = kind >= KindExpressionStatement && kind <= KindIf
+		case roleExpression:
+			ok = kind >= KindIdentifier && kind <= KindFunction
+		case roleIdentifier:
+			ok = kind == KindIdentifier
+		case roleType:
+			ok = kind == KindNamedType || kind == KindFunctionType
+		}
+		if !ok {
+			return fmt.Errorf("child %d has kind %d", c, kind)
+		}
+		return nil
+	}
+	// extra returns Extra[start:start+n], or an error if it is out of range.
+	extra := func(start, n uint32) ([]uint32, error) {
+		if uint64(start)+uint64(n) > uint64(len(t.Extra)) {
+			return nil, fmt.Errorf("list out of range")
+		}
+		return t.Extra[start : start+n], nil
+	}
+	list := func(start, n uint32, r role) error {
+		ids, err := extra(start, n)
+		for _, c := range ids {
+			if err == nil {
+				err = child(c, r, false)
+			}
+		}
+		return err
+	}
+
+	switch n.Kind {
+	case KindProgram:
+		if id != t.Root {
+			return fmt.Errorf("program below the root")
+		}
+		return list(n.LHS, n.RHS, roleStatement)
+	case KindBlock:
+		return list(n.LHS, n.RHS, roleStatement)
+	case KindExpressionStatement, KindReturn, KindUnary:
+		return child(n.LHS, roleExpression, false)
+	case KindLet:
+		if err := child(n.LHS, roleIdentifier, false); err != nil {
+			return err
+		}
+		if n.Flags&FlagTyped == 0 {
+			return child(n.RHS, roleExpression, false)
+		}
+		ids, err := extra(n.RHS, 2)
+		if err != nil {
+			return err
+		}
+		if err := child(ids[0], roleType, false); err != nil {
+			return err
+		}
+		return child(ids[1], roleExpression, false)
+	case KindIf:
+		ids, err := extra(n.RHS, 2)
+		if err != nil {
+			return err
+		}
+		for i, c := range []uint32{n.LHS, ids[0], ids[1]} {
+			r := roleStatement
+			if i == 0 {
+				r = roleExpression
+			}
+			if err := child(c, r, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	case KindIdentifier:
+		if int(n.LHS) >= len(t.Strings) {
+			return fmt.Errorf("string out of range")
+		}
+		return nil
+	case KindInteger:
+		if int(n.LHS) >= len(t.Ints) {
+			return fmt.Errorf("integer out of range")
+		}
+		return nil
+	case KindBoolean:
+		if n.LHS > 1 {
+			return fmt.Errorf("boolean %d", n.LHS)
+		}
+		return nil
+	case KindBinary, KindPipe:
+		if err := child(n.LHS, roleExpression, false); err != nil {
+			return err
+		}
+		return child(n.RHS, roleExpression, false)
+	case KindAssign:
+		if err := child(n.LHS, roleIdentifier, false); err != nil {
+			return err
+		}
+		return child(n.RHS, roleExpression, false)
+	case KindCall:
+		return list(n.LHS, n.RHS, roleExpression)
+	case KindFunction:
+		size := uint64(n.RHS) + 1
+		if n.Flags&FlagTyped != 0 {
+			size += uint64(n.RHS)
+		}
+		if uint64(n.LHS)+size > uint64(len(t.Extra)) {
+			return fmt.Errorf("list out of range")
+		}
+		for i := uint32(0); i < n.RHS; i++ {
+			if err := child(t.Extra[n.LHS+i], roleIdentifier, true); err != nil {
+				return err
+			}
+		}
+		if err := child(t.Extra[n.LHS+n.RHS], roleStatement, false); err != nil {
+			return err
+		}
+		if n.Flags&FlagTyped != 0 {
+			return list(n.LHS+n.RHS+1, n.RHS, roleType)
+		}
+		return nil
+	case KindNamedType:
+		return list(n.LHS, n.RHS, roleType)
+	case KindFunctionType:
+		if n.RHS == ^uint32(0) {
+			return fmt.Errorf("list out of range")
+		}
+		return list(n.LHS, n.RHS+1, roleType)
+	default:
+		return fmt.Errorf("unknown kind %d", n.Kind)
+	}
+}
+
+// Size is the number of bytes held by the tree's arrays, not counting the
+// bytes of the interned strings themselves.
+func (t *Tree) Size() int {
+	return len(t.Nodes)*int(unsafe.Sizeof(Node{})) +
+		len(t.Extra)*4 +
+		len(t.Tokens)*int(unsafe.Sizeof(Token{})) +
+		len(t.Types)*int(unsafe.Sizeof(token.TokenType(""))) +
+		len(t.Strings)*int(unsafe.Sizeof("")) +
+		len(t.Ints)*8
+}
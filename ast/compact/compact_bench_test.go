@@ -0,0 +1,212 @@
+package compact
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func generateSource(functions int) string {
+	var sb strings.Builder
+	for i := 0; i < functions; i++ {
+		fmt.Fprintf(&sb, "let f = fn(a, b, c) {\n")
+		fmt.Fprintf(&sb, "\tlet x = a * %d + b - (c / 2);\n", i)
+		sb.WriteString("\tif (x > b) { return add(a, b * 2, c); } else { return -x ** 2; }\n")
+		sb.WriteString("\tlet g = fn(y) => y * x |> double;\n")
+		sb.WriteString("\treturn g(x) == !true;\n")
+		sb.WriteString("};\n")
+	}
+	return sb.String()
+}
+
+// pointerSize counts the bytes of a pointer AST's structs and slice backing
+// arrays, leaving out string bytes as Tree.Size does.
+func pointerSize(node ast.Node) (size, nodes int) {
+	add := func(n ast.Node) {
+		s, c := pointerSize(n)
+		size += s
+		nodes += c
+	}
+	stmtSlice := func(stmts []ast.Statement) {
+		size += len(stmts) * int(unsafe.Sizeof(ast.Statement(nil)))
+		for _, s := range stmts {
+			add(s)
+		}
+	}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		size += int(unsafe.Sizeof(*n))
+		stmtSlice(n.Statements)
+	case *ast.ExpressionStatement:
+		size += int(unsafe.Sizeof(*n))
+		add(n.Expression)
+	case *ast.LetStatement:
+		size += int(unsafe.Sizeof(*n))
+		add(n.Name)
+		add(n.Value)
+	case *ast.ReturnStatement:
+		size += int(unsafe.Sizeof(*n))
+		add(n.Value)
+	case *ast.BlockStatement:
+		size += int(unsafe.Sizeof(*n))
+		stmtSlice(n.Statements)
+	case *ast.IfStatement:
+		size += int(unsafe.Sizeof(*n))
+		add(n.Condition)
+		add(n.Consequence)
+		if n.Alternative != nil {
+			add(n.Alternative)
+		}
+	case *ast.Identifier:
+		size += int(unsafe.Sizeof(*n))
+	case *ast.IntegerLiteral:
+		size += int(unsafe.Sizeof(*n))
+	case *ast.BooleanLiteral:
+		size += int(unsafe.Sizeof(*n))
+	case *ast.UnaryExpression:
+		size += int(unsafe.Sizeof(*n))
+		add(n.Right)
+	case *ast.BinaryExpression:
+		size += int(unsafe.Sizeof(*n))
+		add(n.Left)
+		add(n.Right)
+	case *ast.PipeExpression:
+		size += int(unsafe.Sizeof(*n))
+		add(n.Left)
+		add(n.Right)
+	case *ast.FunctionInvokeExpression:
+		size += int(unsafe.Sizeof(*n))
+		size += len(n.Arguments) * int(unsafe.Sizeof(ast.Expression(nil)))
+		for _, arg := range n.Arguments {
+			add(arg)
+		}
+	case *ast.FunctionExpression:
+		size += int(unsafe.Sizeof(*n))
+		for i := range n.Parameters {
+			add(&n.Parameters[i])
+			size -= int(unsafe.Sizeof(n.Parameters[i])) // stored inline, counted below
+		}
+		size += len(n.Parameters) * int(unsafe.Sizeof(ast.Identifier{}))
+		add(n.Body)
+	}
+	return size, nodes + 1
+}
+
+func sumPointer(node ast.Node) (sum int64) {
+	switch n := node.(type) {
+	case *ast.Program:
+		for _, s := range n.Statements {
+			sum += sumPointer(s)
+		}
+	case *ast.ExpressionStatement:
+		sum += sumPointer(n.Expression)
+	case *ast.LetStatement:
+		sum += sumPointer(n.Value)
+	case *ast.ReturnStatement:
+		sum += sumPointer(n.Value)
+	case *ast.BlockStatement:
+		for _, s := range n.Statements {
+			sum += sumPointer(s)
+		}
+	case *ast.IfStatement:
+		sum += sumPointer(n.Condition) + sumPointer(n.Consequence)
+		if n.Alternative != nil {
+			sum += sumPointer(n.Alternative)
+		}
+	case *ast.IntegerLiteral:
+		sum += n.Value
+	case *ast.UnaryExpression:
+		sum += sumPointer(n.Right)
+	case *ast.BinaryExpression:
+		sum += sumPointer(n.Left) + sumPointer(n.Right)
+	case *ast.PipeExpression:
+		sum += sumPointer(n.Left) + sumPointer(n.Right)
+	case *ast.FunctionInvokeExpression:
+		for _, arg := range n.Arguments {
+			sum += sumPointer(arg)
+		}
+	case *ast.FunctionExpression:
+		sum += sumPointer(n.Body)
+	}
+	return sum
+}
+
+func sumCompact(t *Tree, stack []NodeID) (int64, []NodeID) {
+	var sum int64
+	stack = append(stack[:0], t.Root)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n := t.Nodes[id]
+		if n.Kind == KindInteger {
+			sum += t.Ints[n.LHS]
+		}
+		stack = t.Children(id, stack)
+	}
+	return sum, stack
+}
+
+func BenchmarkNodeSize(b *testing.B) {
+	program := parse(b, generateSource(500))
+	tree, err := FromAST(program)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Pointer", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			size, nodes := pointerSize(program)
+			b.ReportMetric(float64(size)/float64(nodes), "B/node")
+		}
+	})
+	b.Run("Compact", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			nodes := len(tree.Nodes) - 1
+			b.ReportMetric(float64(tree.Size())/float64(nodes), "B/node")
+		}
+	})
+}
+
+func BenchmarkTraverse(b *testing.B) {
+	program := parse(b, generateSource(500))
+	tree, err := FromAST(program)
+	if err != nil {
+		b.Fatal(err)
+	}
+	want := sumPointer(program)
+
+	b.Run("Pointer", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if sumPointer(program) != want {
+				b.Fatal("wrong sum")
+			}
+		}
+	})
+	b.Run("Compact", func(b *testing.B) {
+		var stack []NodeID
+		for i := 0; i < b.N; i++ {
+			var sum int64
+			sum, stack = sumCompact(tree, stack)
+			if sum != want {
+				b.Fatal("wrong sum")
+			}
+		}
+	})
+	// Passes that do not care about tree shape can scan the node array.
+	b.Run("CompactLinear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sum int64
+			for _, n := range tree.Nodes {
+				if n.Kind == KindInteger {
+					sum += tree.Ints[n.LHS]
+				}
+			}
+			if sum != want {
+				b.Fatal("wrong sum")
+			}
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package compact
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/intern"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"strings"
+	"testing"
+)
+
+const source = `
+let add = fn(a, b) { return a + b; };
+let double = fn(x) => x * 2;
+if (add(1, 2) > 2) { xs |> map(double) |> sum; } else { y = !true; }
+return -5 ** 2 == 10;
+let typed: fn(int, bool) => array(int) = fn(n: int, b) => rest(xs);
+let f = fn(a) {
+ a
+};
+f(1,
+ 2);
+`
+
+func parse(t testing.TB, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+	return program
+}
+
+func TestRoundTrip(t *testing.T) {
+	program := parse(t, source)
+
+	tree, err := FromAST(program)
+	if err != nil {
+		t.Fatalf("FromAST failed: %s", err)
+	}
+	if err := tree.Validate(); err != nil {
+		t.Fatalf("Validate failed: %s", err)
+	}
+
+	back := tree.ToAST()
+	if back.String() != program.String() {
+		t.Errorf("round trip changed the program.\nexpected=%q\ngot=%q", program.String(), back.String())
+	}
+	if got, want := spans(back), spans(program); got != want {
+		t.Errorf("round trip changed the extents.\nexpected=\n%s\ngot=\n%s", want, got)
+	}
+
+	for i, stmt := range program.Statements {
+		want, got := stmt.(ast.Node), back.Statements[i].(ast.Node)
+		if want.TokenLiteral() != got.TokenLiteral() {
+			t.Errorf("stmt %d token wrong. expected=%q, got=%q", i, want.TokenLiteral(), got.TokenLiteral())
+		}
+	}
+
+	fn := back.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionExpression)
+	if !fn.Arrow {
+		t.Errorf("arrow flag lost")
+	}
+	if pos := fn.Token.Pos; pos.Line != 3 || pos.Column != 14 {
+		t.Errorf("fn position wrong. got=%s", pos)
+	}
+}
+
+// A lazily parsed body is parsed on the way in and keeps its extent.
+func TestRoundTripLazy(t *testing.T) {
+	program := parser.NewWithOptions(lexer.New(source), parser.Options{LazyBodies: true}).ParseProgram()
+	want := spans(parse(t, source))
+
+	tree, err := FromAST(program)
+	if err != nil {
+		t.Fatalf("FromAST failed: %s", err)
+	}
+	if got := spans(tree.ToAST()); got != want {
+		t.Errorf("round trip changed the extents.\nexpected=\n%s\ngot=\n%s", want, got)
+	}
+}
+
+// spans lists the extent of every node in the tree.
+func spans(node ast.Node) string {
+	var out strings.Builder
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			fmt.Fprintf(&out, "%T %v-%v\n", n, n.Pos(), n.End())
+		}
+		return true
+	})
+	return out.String()
+}
+
+func TestRoundTripSymbols(t *testing.T) {
+	table := intern.NewTable()
+	p := parser.NewWithOptions(lexer.New("let x = x + y;"), parser.Options{Interner: table})
+	program := p.ParseProgram()
+
+	tree, err := FromAST(program)
+	if err != nil {
+		t.Fatalf("FromAST failed: %s", err)
+	}
+	let := tree.ToAST().Statements[0].(*ast.LetStatement)
+	use := let.Value.(*ast.BinaryExpression).Left.(*ast.Identifier)
+
+	sym, _ := table.Lookup("x")
+	if let.Name.Symbol != sym || use.Symbol != sym || use.Token.Sym != sym {
+		t.Errorf("symbols lost. expected=%d, got=%d, %d, %d", sym, let.Name.Symbol, use.Symbol, use.Token.Sym)
+	}
+}
+
+func TestChildren(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Kind
+	}{
+		{"let x = a + f(1, 2);", []Kind{KindProgram, KindLet, KindIdentifier, KindBinary, KindIdentifier, KindCall, KindInteger, KindInteger}},
+		{"let x: fn(int) => t = fn(a: int, b) => a;", []Kind{KindProgram, KindLet, KindIdentifier,
+			KindFunctionType, KindNamedType, KindNamedType, KindFunction, KindIdentifier, KindNamedType,
+			KindIdentifier, KindBlock, KindReturn, KindIdentifier}},
+	}
+	for _, tt := range tests {
+		tree, err := FromAST(parse(t, tt.input))
+		if err != nil {
+			t.Fatalf("FromAST failed: %s", err)
+		}
+
+		kinds := []Kind{}
+		var visit func(id NodeID)
+		visit = func(id NodeID) {
+			kinds = append(kinds, tree.Node(id).Kind)
+			for _, child := range tree.Children(id, nil) {
+				visit(child)
+			}
+		}
+		visit(tree.Root)
+
+		if len(kinds) != len(tt.expected) {
+			t.Fatalf("%q: wrong traversal. expected=%v, got=%v", tt.input, tt.expected, kinds)
+		}
+		for i := range tt.expected {
+			if kinds[i] != tt.expected[i] {
+				t.Fatalf("%q: wrong traversal. expected=%v, got=%v", tt.input, tt.expected, kinds)
+			}
+		}
+	}
+}
+
+type opaque struct {
+	ast.CustomStatement
+}
+
+func (opaque) TokenLiteral() string { return "" }
+func (opaque) String() string       { return "" }
+
+func TestFromASTUnsupported(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{opaque{}}}
+	if _, err := FromAST(program); err == nil {
+		t.Errorf("expected an error for an unsupported node")
+	}
+}
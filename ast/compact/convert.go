@@ -0,0 +1,383 @@
+package compact
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/intern"
+	"mcompiler/token"
+)
+
+type builder struct {
+	tree    *Tree
+	types   map[token.TokenType]uint16
+	strings map[string]uint32
+	ids     []uint32 // scratch stack for child lists
+	err     error
+}
+
+// FromAST flattens a program. Nodes this package has no kind for, such as
+// those built by parser extensions, are reported as an error.
+func FromAST(program *ast.Program) (*Tree, error) {
+	b := &builder{
+		tree: &Tree{
+			Nodes: []Node{{}}, // NodeID 0 is None
+		},
+		types:   make(map[token.TokenType]uint16),
+		strings: make(map[string]uint32),
+	}
+	b.tree.Root = b.program(program)
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.tree, nil
+}
+
+func (b *builder) unsupported(node ast.Node) NodeID {
+	if b.err == nil {
+		b.err = fmt.Errorf("compact: unsupported node %T", node)
+	}
+	return None
+}
+
+func (b *builder) program(program *ast.Program) NodeID {
+	start, n := b.statements(program.Statements)
+	return b.add(KindProgram, 0, token.Token{}, start, n)
+}
+
+func (b *builder) statements(stmts []ast.Statement) (uint32, uint32) {
+	base := len(b.ids)
+	for _, stmt := range stmts {
+		b.ids = append(b.ids, uint32(b.statement(stmt)))
+	}
+	return b.list(base)
+}
+
+// list moves the child IDs pushed since base into Extra.
+func (b *builder) list(base int) (uint32, uint32) {
+	start := uint32(len(b.tree.Extra))
+	b.tree.Extra = append(b.tree.Extra, b.ids[base:]...)
+	n := uint32(len(b.ids) - base)
+	b.ids = b.ids[:base]
+	return start, n
+}
+
+func (b *builder) add(kind Kind, flags uint8, tok token.Token, lhs, rhs uint32) NodeID {
+	id := NodeID(len(b.tree.Nodes))
+	b.tree.Nodes = append(b.tree.Nodes, Node{
+		Kind:  kind,
+		Flags: flags,
+		Token: b.token(tok),
+		LHS:   lhs,
+		RHS:   rhs,
+	})
+	return id
+}
+
+// addClosed adds a node whose closing `}` or `)` is at closing, if it was
+// written.
+func (b *builder) addClosed(kind Kind, flags uint8, tok, closing token.Token, lhs, rhs uint32) NodeID {
+	if closing.Pos.Line == 0 {
+		return b.add(kind, flags, tok, lhs, rhs)
+	}
+	id := b.add(kind, flags|FlagClosed, tok, lhs, rhs)
+	b.token(closing)
+	return id
+}
+
+func (b *builder) token(tok token.Token) uint32 {
+	typ, ok := b.types[tok.Type]
+	if !ok {
+		typ = uint16(len(b.tree.Types))
+		b.tree.Types = append(b.tree.Types, tok.Type)
+		b.types[tok.Type] = typ
+	}
+	id := uint32(len(b.tree.Tokens))
+	b.tree.Tokens = append(b.tree.Tokens, Token{
+		Type:   typ,
+		Lit:    b.string(tok.Literal),
+		Offset: uint32(tok.Pos.Offset),
+		Line:   uint32(tok.Pos.Line),
+		Column: uint32(tok.Pos.Column),
+	})
+	return id
+}
+
+func (b *builder) string(s string) uint32 {
+	if id, ok := b.strings[s]; ok {
+		return id
+	}
+	id := uint32(len(b.tree.Strings))
+	b.tree.Strings = append(b.tree.Strings, s)
+	b.strings[s] = id
+	return id
+}
+
+func (b *builder) statement(stmt ast.Statement) NodeID {
+	switch stmt := stmt.(type) {
+	case nil:
+		return None
+	case *ast.ExpressionStatement:
+		return b.add(KindExpressionStatement, 0, stmt.Token, uint32(b.expression(stmt.Expression)), 0)
+	case *ast.LetStatement:
+		name := b.identifier(stmt.Name)
+		if stmt.Type != nil {
+			typ := b.typeExpression(stmt.Type)
+			value := b.expression(stmt.Value)
+			start := uint32(len(b.tree.Extra))
+			b.tree.Extra = append(b.tree.Extra, uint32(typ), uint32(value))
+			return b.add(KindLet, FlagTyped, stmt.Token, uint32(name), start)
+		}
+		return b.add(KindLet, 0, stmt.Token, uint32(name), uint32(b.expression(stmt.Value)))
+	case *ast.ReturnStatement:
+		return b.add(KindReturn, 0, stmt.Token, uint32(b.expression(stmt.Value)), 0)
+	case *ast.BlockStatement:
+		start, n := b.statements(stmt.Statements)
+		rbrace := token.Token{Type: token.RBRACE, Literal: "}", Pos: stmt.Rbrace}
+		return b.addClosed(KindBlock, 0, stmt.Token, rbrace, start, n)
+	case *ast.IfStatement:
+		cond := b.expression(stmt.Condition)
+		cons := b.statement(stmt.Consequence)
+		alt := b.statement(stmt.Alternative)
+		branches := uint32(len(b.tree.Extra))
+		b.tree.Extra = append(b.tree.Extra, uint32(cons), uint32(alt))
+		return b.add(KindIf, 0, stmt.Token, uint32(cond), branches)
+	default:
+		return b.unsupported(stmt)
+	}
+}
+
+func (b *builder) identifier(ident *ast.Identifier) NodeID {
+	if ident == nil {
+		return None
+	}
+	return b.add(KindIdentifier, 0, ident.Token, b.string(ident.Value), uint32(ident.Symbol))
+}
+
+func (b *builder) expression(expr ast.Expression) NodeID {
+	switch expr := expr.(type) {
+	case nil:
+		return None
+	case *ast.Identifier:
+		return b.identifier(expr)
+	case *ast.IntegerLiteral:
+		idx := uint32(len(b.tree.Ints))
+		b.tree.Ints = append(b.tree.Ints, expr.Value)
+		return b.add(KindInteger, 0, expr.Token, idx, 0)
+	case *ast.BooleanLiteral:
+		var value uint32
+		if expr.Value {
+			value = 1
+		}
+		return b.add(KindBoolean, 0, expr.Token, value, 0)
+	case *ast.UnaryExpression:
+		return b.add(KindUnary, 0, expr.Token, uint32(b.expression(expr.Right)), 0)
+	case *ast.BinaryExpression:
+		left := b.expression(expr.Left)
+		return b.add(KindBinary, 0, expr.Token, uint32(left), uint32(b.expression(expr.Right)))
+	case *ast.AssignExpression:
+		name := b.identifier(expr.Name)
+		return b.add(KindAssign, 0, expr.Token, uint32(name), uint32(b.expression(expr.Value)))
+	case *ast.PipeExpression:
+		left := b.expression(expr.Left)
+		return b.add(KindPipe, 0, expr.Token, uint32(left), uint32(b.expression(expr.Right)))
+	case *ast.FunctionInvokeExpression:
+		base := len(b.ids)
+		for _, arg := range expr.Arguments {
+			b.ids = append(b.ids, uint32(b.expression(arg)))
+		}
+		start, n := b.list(base)
+		rparen := token.Token{Type: token.RPAREN, Literal: ")", Pos: expr.Rparen}
+		return b.addClosed(KindCall, 0, expr.Token, rparen, start, n)
+	case *ast.FunctionExpression:
+		base := len(b.ids)
+		for i := range expr.Parameters {
+			b.ids = append(b.ids, uint32(b.identifier(&expr.Parameters[i])))
+		}
+		b.ids = append(b.ids, uint32(b.statement(expr.ParseBody())))
+		var flags uint8
+		if expr.Arrow {
+			flags |= FlagArrow
+		}
+		if expr.ParameterTypes != nil {
+			flags |= FlagTyped
+			for i := range expr.Parameters {
+				b.ids = append(b.ids, uint32(b.typeExpression(expr.ParameterType(i))))
+			}
+		}
+		start, _ := b.list(base)
+		return b.add(KindFunction, flags, expr.Token, start, uint32(len(expr.Parameters)))
+	default:
+		return b.unsupported(expr)
+	}
+}
+
+func (b *builder) typeExpression(typ ast.TypeExpression) NodeID {
+	switch typ := typ.(type) {
+	case nil:
+		return None
+	case *ast.NamedType:
+		base := len(b.ids)
+		for _, arg := range typ.Arguments {
+			b.ids = append(b.ids, uint32(b.typeExpression(arg)))
+		}
+		start, n := b.list(base)
+		var flags uint8
+		if typ.Arguments != nil {
+			flags |= FlagArguments
+		}
+		rparen := token.Token{Type: token.RPAREN, Literal: ")", Pos: typ.Rparen}
+		return b.addClosed(KindNamedType, flags, typ.Token, rparen, start, n)
+	case *ast.FunctionType:
+		base := len(b.ids)
+		for _, param := range typ.Parameters {
+			b.ids = append(b.ids, uint32(b.typeExpression(param)))
+		}
+		b.ids = append(b.ids, uint32(b.typeExpression(typ.Result)))
+		start, n := b.list(base)
+		return b.add(KindFunctionType, 0, typ.Token, start, n-1)
+	default:
+		return b.unsupported(typ)
+	}
+}
+
+// ToAST rebuilds the pointer AST of the tree.
+func (t *Tree) ToAST() *ast.Program {
+	root := t.Nodes[t.Root]
+	return &ast.Program{Statements: t.statements(root.LHS, root.RHS)}
+}
+
+func (t *Tree) statements(start, n uint32) []ast.Statement {
+	stmts := make([]ast.Statement, n)
+	for i := range stmts {
+		stmts[i] = t.statement(NodeID(t.Extra[start+uint32(i)]))
+	}
+	return stmts
+}
+
+func (t *Tree) statement(id NodeID) ast.Statement {
+	if id == None {
+		return nil
+	}
+	n := t.Nodes[id]
+	tok := t.Token(id)
+	switch n.Kind {
+	case KindExpressionStatement:
+		return &ast.ExpressionStatement{Token: tok, Expression: t.expression(NodeID(n.LHS))}
+	case KindLet:
+		if n.Flags&FlagTyped != 0 {
+			return &ast.LetStatement{
+				Token: tok,
+				Name:  t.identifier(NodeID(n.LHS)),
+				Type:  t.typeExpression(NodeID(t.Extra[n.RHS])),
+				Value: t.expression(NodeID(t.Extra[n.RHS+1])),
+			}
+		}
+		return &ast.LetStatement{Token: tok, Name: t.identifier(NodeID(n.LHS)), Value: t.expression(NodeID(n.RHS))}
+	case KindReturn:
+		return &ast.ReturnStatement{Token: tok, Value: t.expression(NodeID(n.LHS))}
+	case KindBlock:
+		return &ast.BlockStatement{Token: tok, Statements: t.statements(n.LHS, n.RHS), Rbrace: t.Closing(id)}
+	case KindIf:
+		return &ast.IfStatement{
+			Token:       tok,
+			Condition:   t.expression(NodeID(n.LHS)),
+			Consequence: t.statement(NodeID(t.Extra[n.RHS])),
+			Alternative: t.statement(NodeID(t.Extra[n.RHS+1])),
+		}
+	default:
+		panic(fmt.Sprintf("compact: node %d of kind %d is not a statement", id, n.Kind))
+	}
+}
+
+func (t *Tree) identifier(id NodeID) *ast.Identifier {
+	if id == None {
+		return nil
+	}
+	n := t.Nodes[id]
+	tok := t.Token(id)
+	tok.Sym = intern.Symbol(n.RHS)
+	return &ast.Identifier{Token: tok, Value: t.Strings[n.LHS], Symbol: tok.Sym}
+}
+
+func (t *Tree) expression(id NodeID) ast.Expression {
+	if id == None {
+		return nil
+	}
+	n := t.Nodes[id]
+	tok := t.Token(id)
+	switch n.Kind {
+	case KindIdentifier:
+		return t.identifier(id)
+	case KindInteger:
+		return &ast.IntegerLiteral{Token: tok, Value: t.Ints[n.LHS]}
+	case KindBoolean:
+		return &ast.BooleanLiteral{Token: tok, Value: n.LHS == 1}
+	case KindUnary:
+		return &ast.UnaryExpression{Token: tok, Right: t.expression(NodeID(n.LHS))}
+	case KindBinary:
+		return &ast.BinaryExpression{Token: tok, Left: t.expression(NodeID(n.LHS)), Right: t.expression(NodeID(n.RHS))}
+	case KindAssign:
+		return &ast.AssignExpression{Token: tok, Name: t.identifier(NodeID(n.LHS)), Value: t.expression(NodeID(n.RHS))}
+	case KindPipe:
+		return &ast.PipeExpression{Token: tok, Left: t.expression(NodeID(n.LHS)), Right: t.expression(NodeID(n.RHS))}
+	case KindCall:
+		args := make([]ast.Expression, n.RHS)
+		for i := range args {
+			args[i] = t.expression(NodeID(t.Extra[n.LHS+uint32(i)]))
+		}
+		return &ast.FunctionInvokeExpression{Token: tok, Arguments: args, Rparen: t.Closing(id)}
+	case KindFunction:
+		params := make([]ast.Identifier, n.RHS)
+		for i := range params {
+			params[i] = *t.identifier(NodeID(t.Extra[n.LHS+uint32(i)]))
+		}
+		var types []ast.TypeExpression
+		if n.Flags&FlagTyped != 0 {
+			types = make([]ast.TypeExpression, n.RHS)
+			for i := range types {
+				types[i] = t.typeExpression(NodeID(t.Extra[n.LHS+n.RHS+1+uint32(i)]))
+			}
+		}
+		return &ast.FunctionExpression{
+			Token:          tok,
+			Parameters:     params,
+			ParameterTypes: types,
+			Body:           t.statement(NodeID(t.Extra[n.LHS+n.RHS])),
+			Arrow:          n.Flags&FlagArrow != 0,
+		}
+	default:
+		panic(fmt.Sprintf("compact: node %d of kind %d is not an expression", id, n.Kind))
+	}
+}
+
+func (t *Tree) typeExpression(id NodeID) ast.TypeExpression {
+	if id == None {
+		return nil
+	}
+	n := t.Nodes[id]
+	tok := t.Token(id)
+	switch n.Kind {
+	case KindNamedType:
+		typ := &ast.NamedType{Token: tok, Name: tok.Literal, Rparen: t.Closing(id)}
+		if n.Flags&FlagArguments != 0 {
+			typ.Arguments = t.typeExpressions(n.LHS, n.RHS)
+		}
+		return typ
+	case KindFunctionType:
+		return &ast.FunctionType{
+			Token:      tok,
+			Parameters: t.typeExpressions(n.LHS, n.RHS),
+			Result:     t.typeExpression(NodeID(t.Extra[n.LHS+n.RHS])),
+		}
+	default:
+		panic(fmt.Sprintf("compact: node %d of kind %d is not a type", id, n.Kind))
+	}
+}
+
+func (t *Tree) typeExpressions(start, n uint32) []ast.TypeExpression {
+	types := make([]ast.TypeExpression, n)
+	for i := range types {
+		types[i] = t.typeExpression(NodeID(t.Extra[start+uint32(i)]))
+	}
+	return types
+}
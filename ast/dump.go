@@ -0,0 +1,329 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DumpTree writes the tree rooted at node as an indented outline, one node
+// per line with its type, span and scalar fields, each child labelled with
+// the field that holds it:
+//
+//	Program 1:1-1:14
+//	  statements[0]: LetStatement 1:1-1:14
+//	    name: Identifier 1:5-1:6 value="x"
+//	    value: BinaryExpression 1:9-1:14 op="+"
+//
+// Absent children are shown as nil. Function bodies skipped by a lazy
+// parser are parsed on the way, here and in the other dumps.
+func DumpTree(w io.Writer, node Node) error {
+	var b strings.Builder
+	dumpTree(&b, "", node, 0)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DumpSExpr writes the tree rooted at node as an S-expression: each node is
+// (Type attrs... children...), with its children in field order, lists of
+// children in parentheses of their own and nil for an absent child. A node
+// that does not fit on a line is broken up with one child per line.
+func DumpSExpr(w io.Writer, node Node) error {
+	var b strings.Builder
+	dumpSExpr(&b, node, 0)
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DumpDot writes the tree rooted at node as a Graphviz digraph, for
+// rendering with `dot -Tsvg`. Edges are labelled with field names.
+func DumpDot(w io.Writer, node Node) error {
+	var b strings.Builder
+	b.WriteString("digraph AST {\n")
+	b.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	id := 0
+	dumpDot(&b, node, &id)
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// A dumped node is its type, its scalar fields, already formatted, and its
+// children grouped by field.
+type dumped struct {
+	kind   string
+	attrs  []dumpAttr
+	fields []dumpField
+}
+
+// A dumpAttr with no value is a flag that is shown by name when set.
+type dumpAttr struct {
+	name, value string
+}
+
+func (a dumpAttr) String() string {
+	if a.value == "" {
+		return a.name
+	}
+	return a.name + "=" + a.value
+}
+
+// atom is the attribute in an S-expression, where values go unnamed.
+func (a dumpAttr) atom() string {
+	if a.value == "" {
+		return a.name
+	}
+	return a.value
+}
+
+type dumpField struct {
+	name  string
+	nodes []Node // one child, nil if absent, or the elements of a list
+	list  bool
+}
+
+func dumpChild(name string, n Node) dumpField { return dumpField{name: name, nodes: []Node{n}} }
+
+func dumpList[N Node](name string, list []N) dumpField {
+	f := dumpField{name: name, list: true}
+	for _, n := range list {
+		f.nodes = append(f.nodes, n)
+	}
+	return f
+}
+
+// nilIfAbsent keeps a nil *Identifier from becoming a non-nil Node.
+func nilIfAbsent(ident *Identifier) Node {
+	if ident == nil {
+		return nil
+	}
+	return ident
+}
+
+func dumpQuoted(name, s string) dumpAttr { return dumpAttr{name, strconv.Quote(s)} }
+
+func describeNode(node Node) dumped {
+	switch n := node.(type) {
+	case *Program:
+		return dumped{"Program", nil, []dumpField{dumpList("statements", n.Statements)}}
+	case *ExpressionStatement:
+		return dumped{"ExpressionStatement", nil, []dumpField{dumpChild("expression", n.Expression)}}
+	case *LetStatement:
+		fields := []dumpField{dumpChild("name", nilIfAbsent(n.Name))}
+		if n.Type != nil {
+			fields = append(fields, dumpChild("type", n.Type))
+		}
+		return dumped{"LetStatement", nil, append(fields, dumpChild("value", n.Value))}
+	case *ReturnStatement:
+		return dumped{"ReturnStatement", nil, []dumpField{dumpChild("value", n.Value)}}
+	case *BlockStatement:
+		return dumped{"BlockStatement", nil, []dumpField{dumpList("statements", n.Statements)}}
+	case *IfStatement:
+		return dumped{"IfStatement", nil, []dumpField{
+			dumpChild("condition", n.Condition), dumpChild("consequence", n.Consequence), dumpChild("alternative", n.Alternative)}}
+	case *UnaryExpression:
+		return dumped{"UnaryExpression", []dumpAttr{dumpQuoted("op", n.Token.Literal)},
+			[]dumpField{dumpChild("right", n.Right)}}
+	case *BinaryExpression:
+		return dumped{"BinaryExpression", []dumpAttr{dumpQuoted("op", n.Token.Literal)},
+			[]dumpField{dumpChild("left", n.Left), dumpChild("right", n.Right)}}
+	case *AssignExpression:
+		return dumped{"AssignExpression", nil, []dumpField{dumpChild("name", nilIfAbsent(n.Name)), dumpChild("value", n.Value)}}
+	case *PipeExpression:
+		return dumped{"PipeExpression", nil, []dumpField{dumpChild("left", n.Left), dumpChild("right", n.Right)}}
+	case *FunctionInvokeExpression:
+		return dumped{"FunctionInvokeExpression", []dumpAttr{dumpQuoted("function", n.Token.Literal)},
+			[]dumpField{dumpList("arguments", n.Arguments)}}
+	case *FunctionExpression:
+		params := dumpField{name: "parameters", list: true}
+		for i := range n.Parameters {
+			params.nodes = append(params.nodes, &n.Parameters[i])
+		}
+		fields := []dumpField{params}
+		if n.ParameterTypes != nil {
+			fields = append(fields, dumpList("parameterTypes", n.ParameterTypes))
+		}
+		var attrs []dumpAttr
+		if n.Arrow {
+			attrs = append(attrs, dumpAttr{name: "arrow"})
+		}
+		return dumped{"FunctionExpression", attrs, append(fields, dumpChild("body", n.ParseBody()))}
+	case *Identifier:
+		return dumped{"Identifier", []dumpAttr{dumpQuoted("value", n.Value)}, nil}
+	case *IntegerLiteral:
+		return dumped{"IntegerLiteral", []dumpAttr{{"value", strconv.FormatInt(n.Value, 10)}}, nil}
+	case *BooleanLiteral:
+		return dumped{"BooleanLiteral", []dumpAttr{{"value", strconv.FormatBool(n.Value)}}, nil}
+	case *NamedType:
+		var fields []dumpField
+		if n.Arguments != nil {
+			fields = append(fields, dumpList("arguments", n.Arguments))
+		}
+		return dumped{"NamedType", []dumpAttr{dumpQuoted("name", n.Name)}, fields}
+	case *FunctionType:
+		return dumped{"FunctionType", nil, []dumpField{dumpList("parameters", n.Parameters), dumpChild("result", n.Result)}}
+	case *MissingExpression:
+		return dumped{"MissingExpression", nil, nil}
+	case *MissingStatement:
+		return dumped{"MissingStatement", nil, nil}
+	case *MissingType:
+		return dumped{"MissingType", nil, nil}
+	}
+
+	// A node from outside this package shows its children if it lets Walk
+	// reach them, and its source form otherwise.
+	d := dumped{kind: strings.TrimPrefix(fmt.Sprintf("%T", node), "*")}
+	if cw, ok := node.(ChildWalker); ok {
+		children := dumpField{name: "children", list: true}
+		cw.WalkChildren(inspector(func(n Node) bool {
+			if n != nil {
+				children.nodes = append(children.nodes, n)
+			}
+			return false
+		}))
+		d.fields = append(d.fields, children)
+	} else {
+		d.attrs = append(d.attrs, dumpQuoted("text", node.String()))
+	}
+	return d
+}
+
+func dumpSpan(node Node) string {
+	return node.Pos().String() + "-" + node.End().String()
+}
+
+func dumpTree(b *strings.Builder, label string, node Node, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(label)
+	if node == nil {
+		b.WriteString("nil\n")
+		return
+	}
+	d := describeNode(node)
+	b.WriteString(d.kind)
+	b.WriteByte(' ')
+	b.WriteString(dumpSpan(node))
+	for _, a := range d.attrs {
+		b.WriteString(" " + a.String())
+	}
+	b.WriteByte('\n')
+
+	for _, f := range d.fields {
+		if !f.list {
+			dumpTree(b, f.name+": ", f.nodes[0], depth+1)
+			continue
+		}
+		if len(f.nodes) == 0 {
+			b.WriteString(strings.Repeat("  ", depth+1) + f.name + ": []\n")
+		}
+		for i, n := range f.nodes {
+			dumpTree(b, f.name+"["+strconv.Itoa(i)+"]: ", n, depth+1)
+		}
+	}
+}
+
+// sexprWidth is how long a node may be on one line before DumpSExpr breaks
+// it up.
+const sexprWidth = 72
+
+func dumpSExpr(b *strings.Builder, node Node, depth int) {
+	flat := sexprFlat(node)
+	if node == nil || len(flat)+2*depth <= sexprWidth {
+		b.WriteString(flat)
+		return
+	}
+
+	d := describeNode(node)
+	b.WriteString("(" + d.kind)
+	for _, a := range d.attrs {
+		b.WriteString(" " + a.atom())
+	}
+	for _, f := range d.fields {
+		b.WriteByte('\n')
+		b.WriteString(strings.Repeat("  ", depth+1))
+		if !f.list {
+			dumpSExpr(b, f.nodes[0], depth+1)
+			continue
+		}
+		if flat := sexprList(f.nodes); len(flat)+2*(depth+1) <= sexprWidth {
+			b.WriteString(flat)
+			continue
+		}
+		b.WriteByte('(')
+		for i, n := range f.nodes {
+			if i > 0 {
+				b.WriteByte('\n')
+				b.WriteString(strings.Repeat("  ", depth+1) + " ")
+			}
+			dumpSExpr(b, n, depth+1)
+		}
+		b.WriteByte(')')
+	}
+	b.WriteByte(')')
+}
+
+func sexprFlat(node Node) string {
+	if node == nil {
+		return "nil"
+	}
+	d := describeNode(node)
+	parts := []string{d.kind}
+	for _, a := range d.attrs {
+		parts = append(parts, a.atom())
+	}
+	for _, f := range d.fields {
+		if !f.list {
+			parts = append(parts, sexprFlat(f.nodes[0]))
+			continue
+		}
+		parts = append(parts, sexprList(f.nodes))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func sexprList(nodes []Node) string {
+	list := make([]string, len(nodes))
+	for i, n := range nodes {
+		list[i] = sexprFlat(n)
+	}
+	return "(" + strings.Join(list, " ") + ")"
+}
+
+// dumpDot writes node and the edges to its children, and returns the name
+// of node's vertex.
+func dumpDot(b *strings.Builder, node Node, id *int) string {
+	name := "n" + strconv.Itoa(*id)
+	*id++
+
+	d := describeNode(node)
+	lines := []string{d.kind}
+	for _, a := range d.attrs {
+		lines = append(lines, a.String())
+	}
+	lines = append(lines, dumpSpan(node))
+	fmt.Fprintf(b, "\t%s [label=%s];\n", name, dotQuote(strings.Join(lines, "\n")))
+
+	for _, f := range d.fields {
+		for i, n := range f.nodes {
+			if n == nil {
+				continue
+			}
+			label := f.name
+			if f.list {
+				label += "[" + strconv.Itoa(i) + "]"
+			}
+			child := dumpDot(b, n, id)
+			fmt.Fprintf(b, "\t%s -> %s [label=%s];\n", name, child, dotQuote(label))
+		}
+	}
+	return name
+}
+
+// dotQuote returns s as a DOT string, with line breaks that centre each
+// line.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
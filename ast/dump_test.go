@@ -0,0 +1,108 @@
+package ast_test
+
+import (
+	"io"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"strings"
+	"testing"
+)
+
+const dumpSource = `let inc = fn(x) => x + 1;
+if (inc(y)) { -y } else {}`
+
+func dump(t *testing.T, f func(io.Writer, ast.Node) error, n ast.Node) string {
+	t.Helper()
+	var b strings.Builder
+	if err := f(&b, n); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestDumpTree(t *testing.T) {
+	program := parse(t, dumpSource, parser.Options{})
+	got := dump(t, ast.DumpTree, program)
+	expected := `Program 1:1-2:27
+  statements[0]: LetStatement 1:1-1:25
+    name: Identifier 1:5-1:8 value="inc"
+    value: FunctionExpression 1:11-1:25 arrow
+      parameters[0]: Identifier 1:14-1:15 value="x"
+      body: BlockStatement 1:17-1:25
+        statements[0]: ReturnStatement 1:17-1:25
+          value: BinaryExpression 1:20-1:25 op="+"
+            left: Identifier 1:20-1:21 value="x"
+            right: IntegerLiteral 1:24-1:25 value=1
+  statements[1]: IfStatement 2:1-2:27
+    condition: FunctionInvokeExpression 2:5-2:11 function="inc"
+      arguments[0]: Identifier 2:9-2:10 value="y"
+    consequence: BlockStatement 2:13-2:19
+      statements[0]: ExpressionStatement 2:15-2:17
+        expression: UnaryExpression 2:15-2:17 op="-"
+          right: Identifier 2:16-2:17 value="y"
+    alternative: BlockStatement 2:25-2:27
+      statements: []
+`
+	if got != expected {
+		t.Errorf("DumpTree:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestDumpSExpr(t *testing.T) {
+	program := parse(t, dumpSource, parser.Options{})
+	got := dump(t, ast.DumpSExpr, program)
+	expected := `(Program
+  ((LetStatement
+    (Identifier "inc")
+    (FunctionExpression arrow
+      ((Identifier "x"))
+      (BlockStatement
+        ((ReturnStatement
+          (BinaryExpression "+" (Identifier "x") (IntegerLiteral 1)))))))
+   (IfStatement
+    (FunctionInvokeExpression "inc" ((Identifier "y")))
+    (BlockStatement
+      ((ExpressionStatement (UnaryExpression "-" (Identifier "y")))))
+    (BlockStatement ()))))
+`
+	if got != expected {
+		t.Errorf("DumpSExpr:\n%s\nwant:\n%s", got, expected)
+	}
+
+	// Absent children keep their place, and short trees stay on one line.
+	tolerant := parser.NewWithOptions(lexer.New("let x = ; if (x) {}"), parser.Options{Tolerant: true}).ParseProgram()
+	got = dump(t, ast.DumpSExpr, tolerant.Statements[1])
+	if expected := "(IfStatement (Identifier \"x\") (BlockStatement ()) nil)\n"; got != expected {
+		t.Errorf("DumpSExpr = %q, want %q", got, expected)
+	}
+}
+
+func TestDumpDot(t *testing.T) {
+	program := parse(t, `f(a, 2);`, parser.Options{})
+	got := dump(t, ast.DumpDot, program)
+	expected := `digraph AST {
+	node [shape=box, fontname="monospace"];
+	n0 [label="Program\n1:1-1:8"];
+	n1 [label="ExpressionStatement\n1:1-1:8"];
+	n2 [label="FunctionInvokeExpression\nfunction=\"f\"\n1:1-1:8"];
+	n3 [label="Identifier\nvalue=\"a\"\n1:3-1:4"];
+	n2 -> n3 [label="arguments[0]"];
+	n4 [label="IntegerLiteral\nvalue=2\n1:6-1:7"];
+	n2 -> n4 [label="arguments[1]"];
+	n1 -> n2 [label="expression"];
+	n0 -> n1 [label="statements[0]"];
+}
+`
+	if got != expected {
+		t.Errorf("DumpDot:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestDump_Custom(t *testing.T) {
+	node := &custom{Inner: &ast.IntegerLiteral{Value: 1}}
+	got := dump(t, ast.DumpSExpr, node)
+	if expected := "(ast_test.custom ((IntegerLiteral 1)))\n"; got != expected {
+		t.Errorf("DumpSExpr = %q, want %q", got, expected)
+	}
+}
@@ -0,0 +1,361 @@
+package ast
+
+import (
+	"fmt"
+	"mcompiler/token"
+	"reflect"
+)
+
+// Equal reports whether the trees rooted at a and b have the same shape,
+// tokens and values, wherever they are in the source. Whether a block or
+// call was closed counts; where it was closed does not. Identifiers'
+// interned symbols are not compared, so trees from different interners can
+// be equal. Function bodies skipped by a lazy parser are parsed on the way.
+//
+// Nodes defined outside this package are compared with reflect.DeepEqual,
+// positions included.
+func Equal(a, b Node) bool {
+	return comparer{positions: false}.node(a, b)
+}
+
+// EqualPositions is like Equal but also requires every token, closing
+// bracket and skipped span to be at the same position.
+func EqualPositions(a, b Node) bool {
+	return comparer{positions: true}.node(a, b)
+}
+
+type comparer struct {
+	positions bool
+}
+
+func (c comparer) node(a, b Node) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+
+	switch x := a.(type) {
+	case *Program:
+		y := b.(*Program)
+		return equalList(c, x.Statements, y.Statements)
+	case *ExpressionStatement:
+		y := b.(*ExpressionStatement)
+		return c.token(x.Token, y.Token) && c.node(x.Expression, y.Expression)
+	case *LetStatement:
+		y := b.(*LetStatement)
+		return c.token(x.Token, y.Token) && c.identifier(x.Name, y.Name) && c.node(x.Type, y.Type) && c.node(x.Value, y.Value)
+	case *ReturnStatement:
+		y := b.(*ReturnStatement)
+		return c.token(x.Token, y.Token) && c.node(x.Value, y.Value)
+	case *BlockStatement:
+		y := b.(*BlockStatement)
+		return c.token(x.Token, y.Token) && c.closing(x.Rbrace, y.Rbrace) && equalList(c, x.Statements, y.Statements)
+	case *IfStatement:
+		y := b.(*IfStatement)
+		return c.token(x.Token, y.Token) && c.node(x.Condition, y.Condition) &&
+			c.node(x.Consequence, y.Consequence) && c.node(x.Alternative, y.Alternative)
+	case *UnaryExpression:
+		y := b.(*UnaryExpression)
+		return c.token(x.Token, y.Token) && c.node(x.Right, y.Right)
+	case *BinaryExpression:
+		y := b.(*BinaryExpression)
+		return c.token(x.Token, y.Token) && c.node(x.Left, y.Left) && c.node(x.Right, y.Right)
+	case *AssignExpression:
+		y := b.(*AssignExpression)
+		return c.token(x.Token, y.Token) && c.identifier(x.Name, y.Name) && c.node(x.Value, y.Value)
+	case *PipeExpression:
+		y := b.(*PipeExpression)
+		return c.token(x.Token, y.Token) && c.node(x.Left, y.Left) && c.node(x.Right, y.Right)
+	case *FunctionInvokeExpression:
+		y := b.(*FunctionInvokeExpression)
+		return c.token(x.Token, y.Token) && c.closing(x.Rparen, y.Rparen) && equalList(c, x.Arguments, y.Arguments)
+	case *FunctionExpression:
+		y := b.(*FunctionExpression)
+		if !c.token(x.Token, y.Token) || x.Arrow != y.Arrow || len(x.Parameters) != len(y.Parameters) {
+			return false
+		}
+		for i := range x.Parameters {
+			if !c.identifier(&x.Parameters[i], &y.Parameters[i]) || !c.node(x.ParameterType(i), y.ParameterType(i)) {
+				return false
+			}
+		}
+		return c.node(x.ParseBody(), y.ParseBody())
+	case *Identifier:
+		return c.identifier(x, b.(*Identifier))
+	case *IntegerLiteral:
+		y := b.(*IntegerLiteral)
+		return c.token(x.Token, y.Token) && x.Value == y.Value
+	case *BooleanLiteral:
+		y := b.(*BooleanLiteral)
+		return c.token(x.Token, y.Token) && x.Value == y.Value
+	case *NamedType:
+		y := b.(*NamedType)
+		return c.token(x.Token, y.Token) && x.Name == y.Name && c.closing(x.Rparen, y.Rparen) && equalList(c, x.Arguments, y.Arguments)
+	case *FunctionType:
+		y := b.(*FunctionType)
+		return c.token(x.Token, y.Token) && equalList(c, x.Parameters, y.Parameters) && c.node(x.Result, y.Result)
+	case *MissingExpression:
+		y := b.(*MissingExpression)
+		return c.token(x.Token, y.Token) && c.span(x.Span, y.Span)
+	case *MissingStatement:
+		y := b.(*MissingStatement)
+		return c.token(x.Token, y.Token) && c.span(x.Span, y.Span)
+	case *MissingType:
+		y := b.(*MissingType)
+		return c.token(x.Token, y.Token) && c.span(x.Span, y.Span)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func equalList[N Node](c comparer, a, b []N) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !c.node(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// identifier compares without calling methods on a nil *Identifier.
+func (c comparer) identifier(a, b *Identifier) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return c.token(a.Token, b.Token) && a.Value == b.Value
+}
+
+func (c comparer) token(a, b token.Token) bool {
+	return a.Type == b.Type && a.Literal == b.Literal && (!c.positions || a.Pos == b.Pos)
+}
+
+// closing compares the positions of closing brackets, which are zero for a
+// bracket that is missing.
+func (c comparer) closing(a, b token.Pos) bool {
+	if c.positions {
+		return a == b
+	}
+	return (a.Line == 0) == (b.Line == 0)
+}
+
+func (c comparer) span(a, b Span) bool {
+	return !c.positions || a == b
+}
+
+// Hash returns a structural hash of the tree rooted at node: trees that are
+// Equal hash alike, wherever they are in the source. The hash is computed
+// with 64-bit FNV-1a over a fixed encoding of the tree, so it is the same
+// in every process and can be stored, as a cache key for instance. Function
+// bodies skipped by a lazy parser are parsed on the way.
+//
+// Nodes defined outside this package are hashed by their type and source
+// form.
+func Hash(node Node) uint64 {
+	h := &hasher{sum: fnvOffset}
+	h.node(node)
+	return h.sum
+}
+
+// Tags for the hash encoding; changing them changes every hash.
+const (
+	hashNil byte = iota
+	hashProgram
+	hashExpressionStatement
+	hashLetStatement
+	hashReturnStatement
+	hashBlockStatement
+	hashIfStatement
+	hashUnaryExpression
+	hashBinaryExpression
+	hashAssignExpression
+	hashPipeExpression
+	hashFunctionInvokeExpression
+	hashFunctionExpression
+	hashIdentifier
+	hashIntegerLiteral
+	hashBooleanLiteral
+	hashNamedType
+	hashFunctionType
+	hashMissingExpression
+	hashMissingStatement
+	hashMissingType
+	hashCustom
+)
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// hasher feeds the encoding of a tree through 64-bit FNV-1a.
+type hasher struct {
+	sum uint64
+}
+
+func (h *hasher) byte(b byte) {
+	h.sum ^= uint64(b)
+	h.sum *= fnvPrime
+}
+
+func (h *hasher) uint64(v uint64) {
+	for i := 0; i < 8; i++ {
+		h.byte(byte(v >> (8 * i)))
+	}
+}
+
+func (h *hasher) bool(v bool) {
+	if v {
+		h.byte(1)
+	} else {
+		h.byte(0)
+	}
+}
+
+// string writes s with its length first, so that adjacent strings cannot
+// run into each other.
+func (h *hasher) string(s string) {
+	h.uint64(uint64(len(s)))
+	for i := 0; i < len(s); i++ {
+		h.byte(s[i])
+	}
+}
+
+func (h *hasher) token(t token.Token) {
+	h.string(string(t.Type))
+	h.string(t.Literal)
+}
+
+func (h *hasher) identifier(ident *Identifier) {
+	if ident == nil {
+		h.byte(hashNil)
+		return
+	}
+	h.byte(hashIdentifier)
+	h.token(ident.Token)
+	h.string(ident.Value)
+}
+
+func hashList[N Node](h *hasher, list []N) {
+	h.uint64(uint64(len(list)))
+	for _, n := range list {
+		h.node(n)
+	}
+}
+
+func (h *hasher) node(node Node) {
+	if node == nil {
+		h.byte(hashNil)
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		h.byte(hashProgram)
+		hashList(h, n.Statements)
+	case *ExpressionStatement:
+		h.byte(hashExpressionStatement)
+		h.token(n.Token)
+		h.node(n.Expression)
+	case *LetStatement:
+		h.byte(hashLetStatement)
+		h.token(n.Token)
+		h.identifier(n.Name)
+		// Annotations are hashed only where present, so that stored hashes
+		// of trees without them stay valid. No expression hashes like a type.
+		if n.Type != nil {
+			h.node(n.Type)
+		}
+		h.node(n.Value)
+	case *ReturnStatement:
+		h.byte(hashReturnStatement)
+		h.token(n.Token)
+		h.node(n.Value)
+	case *BlockStatement:
+		h.byte(hashBlockStatement)
+		h.token(n.Token)
+		h.bool(n.Rbrace.Line != 0)
+		hashList(h, n.Statements)
+	case *IfStatement:
+		h.byte(hashIfStatement)
+		h.token(n.Token)
+		h.node(n.Condition)
+		h.node(n.Consequence)
+		h.node(n.Alternative)
+	case *UnaryExpression:
+		h.byte(hashUnaryExpression)
+		h.token(n.Token)
+		h.node(n.Right)
+	case *BinaryExpression:
+		h.byte(hashBinaryExpression)
+		h.token(n.Token)
+		h.node(n.Left)
+		h.node(n.Right)
+	case *AssignExpression:
+		h.byte(hashAssignExpression)
+		h.token(n.Token)
+		h.identifier(n.Name)
+		h.node(n.Value)
+	case *PipeExpression:
+		h.byte(hashPipeExpression)
+		h.token(n.Token)
+		h.node(n.Left)
+		h.node(n.Right)
+	case *FunctionInvokeExpression:
+		h.byte(hashFunctionInvokeExpression)
+		h.token(n.Token)
+		h.bool(n.Rparen.Line != 0)
+		hashList(h, n.Arguments)
+	case *FunctionExpression:
+		h.byte(hashFunctionExpression)
+		h.token(n.Token)
+		h.bool(n.Arrow)
+		h.uint64(uint64(len(n.Parameters)))
+		for i := range n.Parameters {
+			h.identifier(&n.Parameters[i])
+			if n.ParameterTypes != nil {
+				h.node(n.ParameterType(i))
+			}
+		}
+		h.node(n.ParseBody())
+	case *Identifier:
+		h.identifier(n)
+	case *IntegerLiteral:
+		h.byte(hashIntegerLiteral)
+		h.token(n.Token)
+		h.uint64(uint64(n.Value))
+	case *BooleanLiteral:
+		h.byte(hashBooleanLiteral)
+		h.token(n.Token)
+		h.bool(n.Value)
+	case *NamedType:
+		h.byte(hashNamedType)
+		h.token(n.Token)
+		h.string(n.Name)
+		if n.Arguments != nil {
+			h.bool(n.Rparen.Line != 0)
+			hashList(h, n.Arguments)
+		}
+	case *FunctionType:
+		h.byte(hashFunctionType)
+		h.token(n.Token)
+		hashList(h, n.Parameters)
+		h.node(n.Result)
+	case *MissingExpression:
+		h.byte(hashMissingExpression)
+		h.token(n.Token)
+	case *MissingStatement:
+		h.byte(hashMissingStatement)
+		h.token(n.Token)
+	case *MissingType:
+		h.byte(hashMissingType)
+		h.token(n.Token)
+	default:
+		h.byte(hashCustom)
+		h.string(fmt.Sprintf("%T", n))
+		h.string(n.String())
+	}
+}
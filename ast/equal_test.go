@@ -0,0 +1,94 @@
+package ast_test
+
+import (
+	"mcompiler/ast"
+	"mcompiler/intern"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"testing"
+)
+
+const equalSource = `let add = fn(a, b) { return a + b; };
+if (add(1, 2) > x) { y = -3; } else { xs |> sum }
+let sq = fn(x) => x ** 2;`
+
+func TestEqual(t *testing.T) {
+	program := parse(t, equalSource, parser.Options{})
+	same := []struct {
+		name  string
+		other *ast.Program
+	}{
+		{"reparsed", parse(t, equalSource, parser.Options{})},
+		{"lazy", parse(t, equalSource, parser.Options{LazyBodies: true})},
+		{"interned", parse(t, equalSource, parser.Options{Interner: intern.NewTable()})},
+	}
+	for _, tt := range same {
+		if !ast.Equal(program, tt.other) || !ast.EqualPositions(program, tt.other) {
+			t.Errorf("%s: program is not equal to itself", tt.name)
+		}
+		if ast.Hash(program) != ast.Hash(tt.other) {
+			t.Errorf("%s: hashes differ", tt.name)
+		}
+	}
+
+	moved := parse(t, "\n\n  "+equalSource, parser.Options{})
+	if !ast.Equal(program, moved) {
+		t.Errorf("moving a program makes it unequal")
+	}
+	if ast.EqualPositions(program, moved) {
+		t.Errorf("EqualPositions ignores positions")
+	}
+	if ast.Hash(program) != ast.Hash(moved) {
+		t.Errorf("moving a program changes its hash")
+	}
+}
+
+func TestEqual_Differences(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"a + b;", "a - b;"},
+		{"f(x);", "f(y);"},
+		{"f(x);", "f(x, y);"},
+		{"let x = 1;", "let y = 1;"},
+		{"fn(x) => x;", "fn(x) { return x; };"},
+		{"fn(x) { x };", "fn(y) { x };"},
+		{"let x = 1;", "let x: int = 1;"},
+		{"fn(x, y) => x;", "fn(x, y: int) => x;"},
+		{"let x: array(int) = 1;", "let x: array(bool) = 1;"},
+		{"if (x) { 1 }", "if (x) { 1 } else { 2 }"},
+		{"true;", "false;"},
+		{"1;", "2;"},
+		{"x;", "x; x;"},
+		// These print the same; the first call or block was never closed.
+		{"f(1", "f(1)"},
+		{"if (x) { 1", "if (x) { 1 }"},
+	}
+	tolerant := parser.Options{Tolerant: true}
+	for _, tt := range tests {
+		a := parser.NewWithOptions(lexer.New(tt.a), tolerant).ParseProgram()
+		b := parser.NewWithOptions(lexer.New(tt.b), tolerant).ParseProgram()
+		if ast.Equal(a, b) || ast.Equal(b, a) {
+			t.Errorf("%q and %q are equal", tt.a, tt.b)
+		}
+		if ast.Hash(a) == ast.Hash(b) {
+			t.Errorf("%q and %q hash alike", tt.a, tt.b)
+		}
+	}
+}
+
+func TestEqual_Nil(t *testing.T) {
+	x := &ast.Identifier{Value: "x"}
+	if !ast.Equal(nil, nil) || ast.Equal(x, nil) || ast.Equal(nil, x) {
+		t.Errorf("Equal mishandles nil")
+	}
+	if !ast.Equal(&ast.LetStatement{}, &ast.LetStatement{}) {
+		t.Errorf("let statements without names are not equal")
+	}
+}
+
+// Hashes are stored across runs, so they must not change by accident.
+func TestHash_Stable(t *testing.T) {
+	program := parse(t, "let x = f(1, true);", parser.Options{})
+	if got := ast.Hash(program); got != 0x4a8c012da978e9cf {
+		t.Errorf("Hash = %#x; if the encoding changed on purpose, update this value", got)
+	}
+}
@@ -0,0 +1,729 @@
+package ast
+
+import (
+	"fmt"
+	"mcompiler/arena"
+	"mcompiler/simd"
+	"mcompiler/token"
+	"strconv"
+	"strings"
+)
+
+// JSONVersion is the version of the schema written by MarshalJSON. It is
+// bumped whenever a change would make older readers misread a document.
+//
+// A document is {"version": 1, "root": node}. A node is an object whose
+// "node" member names its type, such as "LetStatement", followed by "pos"
+// and "end", its extent as [offset, line, column]. Every node but Program
+// then has a "token" {"type", "literal", "pos"}, and the remaining members
+// are the node's fields in lower case: child nodes, lists of them, or null
+// for an absent child. BlockStatement's "rbrace" and
+// FunctionInvokeExpression's "rparen" are positions, or null when the
+// bracket was not closed, and the Missing nodes carry their "span" as a
+// pair of positions. LetStatement's "type", FunctionExpression's
+// "parameterTypes" and NamedType's "arguments" and "rparen" are null when
+// there are no annotations or arguments; they were added in version 2, and
+// version 1 documents, which have none, are still read.
+//
+// "pos" and "end" are written for the benefit of other tools and ignored by
+// UnmarshalJSON, which derives them from the tokens like any other tree.
+// Program's Source and Spans and the interned symbols are not part of the
+// schema, so a decoded program cannot be reparsed incrementally.
+const JSONVersion = 2
+
+// MarshalJSON encodes the tree rooted at node. Function bodies skipped by a
+// lazy parser are parsed on the way. Node types defined outside this
+// package are reported as an error.
+func MarshalJSON(node Node) ([]byte, error) {
+	e := &jsonEncoder{}
+	e.buf = append(e.buf, `{"version":`...)
+	e.buf = strconv.AppendInt(e.buf, JSONVersion, 10)
+	e.buf = append(e.buf, `,"root":`...)
+	e.node(node)
+	e.buf = append(e.buf, '}')
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf, nil
+}
+
+type jsonEncoder struct {
+	buf []byte
+	err error
+}
+
+func (e *jsonEncoder) node(node Node) {
+	if node == nil {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		e.open("Program", n)
+		e.field("statements")
+		encodeList(e, n.Statements)
+	case *ExpressionStatement:
+		e.openToken("ExpressionStatement", n, n.Token)
+		e.field("expression")
+		e.node(n.Expression)
+	case *LetStatement:
+		e.openToken("LetStatement", n, n.Token)
+		e.field("name")
+		e.identifier(n.Name)
+		e.field("type")
+		e.node(n.Type)
+		e.field("value")
+		e.node(n.Value)
+	case *ReturnStatement:
+		e.openToken("ReturnStatement", n, n.Token)
+		e.field("value")
+		e.node(n.Value)
+	case *BlockStatement:
+		e.openToken("BlockStatement", n, n.Token)
+		e.field("statements")
+		encodeList(e, n.Statements)
+		e.field("rbrace")
+		e.optionalPos(n.Rbrace)
+	case *IfStatement:
+		e.openToken("IfStatement", n, n.Token)
+		e.field("condition")
+		e.node(n.Condition)
+		e.field("consequence")
+		e.node(n.Consequence)
+		e.field("alternative")
+		e.node(n.Alternative)
+	case *UnaryExpression:
+		e.openToken("UnaryExpression", n, n.Token)
+		e.field("right")
+		e.node(n.Right)
+	case *BinaryExpression:
+		e.openToken("BinaryExpression", n, n.Token)
+		e.field("left")
+		e.node(n.Left)
+		e.field("right")
+		e.node(n.Right)
+	case *AssignExpression:
+		e.openToken("AssignExpression", n, n.Token)
+		e.field("name")
+		e.identifier(n.Name)
+		e.field("value")
+		e.node(n.Value)
+	case *PipeExpression:
+		e.openToken("PipeExpression", n, n.Token)
+		e.field("left")
+		e.node(n.Left)
+		e.field("right")
+		e.node(n.Right)
+	case *FunctionInvokeExpression:
+		e.openToken("FunctionInvokeExpression", n, n.Token)
+		e.field("arguments")
+		encodeList(e, n.Arguments)
+		e.field("rparen")
+		e.optionalPos(n.Rparen)
+	case *FunctionExpression:
+		e.openToken("FunctionExpression", n, n.Token)
+		e.field("parameters")
+		e.buf = append(e.buf, '[')
+		for i := range n.Parameters {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.node(&n.Parameters[i])
+		}
+		e.buf = append(e.buf, ']')
+		e.field("parameterTypes")
+		optionalList(e, n.ParameterTypes)
+		e.field("body")
+		e.node(n.ParseBody())
+		e.field("arrow")
+		e.buf = strconv.AppendBool(e.buf, n.Arrow)
+	case *Identifier:
+		e.openToken("Identifier", n, n.Token)
+		e.field("value")
+		e.string(n.Value)
+	case *IntegerLiteral:
+		e.openToken("IntegerLiteral", n, n.Token)
+		e.field("value")
+		e.buf = strconv.AppendInt(e.buf, n.Value, 10)
+	case *BooleanLiteral:
+		e.openToken("BooleanLiteral", n, n.Token)
+		e.field("value")
+		e.buf = strconv.AppendBool(e.buf, n.Value)
+	case *NamedType:
+		e.openToken("NamedType", n, n.Token)
+		e.field("name")
+		e.string(n.Name)
+		e.field("arguments")
+		optionalList(e, n.Arguments)
+		e.field("rparen")
+		e.optionalPos(n.Rparen)
+	case *FunctionType:
+		e.openToken("FunctionType", n, n.Token)
+		e.field("parameters")
+		encodeList(e, n.Parameters)
+		e.field("result")
+		e.node(n.Result)
+	case *MissingExpression:
+		e.openToken("MissingExpression", n, n.Token)
+		e.span(n.Span)
+	case *MissingStatement:
+		e.openToken("MissingStatement", n, n.Token)
+		e.span(n.Span)
+	case *MissingType:
+		e.openToken("MissingType", n, n.Token)
+		e.span(n.Span)
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("ast: cannot encode %T as JSON", node)
+		}
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	e.buf = append(e.buf, '}')
+}
+
+// identifier encodes a nil *Identifier as null rather than calling its
+// methods.
+func (e *jsonEncoder) identifier(ident *Identifier) {
+	if ident == nil {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	e.node(ident)
+}
+
+func encodeList[N Node](e *jsonEncoder, list []N) {
+	e.buf = append(e.buf, '[')
+	for i, n := range list {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.node(n)
+	}
+	e.buf = append(e.buf, ']')
+}
+
+// optionalList encodes a nil list as null, to tell it from an empty one.
+func optionalList[N Node](e *jsonEncoder, list []N) {
+	if list == nil {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	encodeList(e, list)
+}
+
+func (e *jsonEncoder) open(kind string, n Node) {
+	e.buf = append(e.buf, `{"node":"`...)
+	e.buf = append(e.buf, kind...)
+	e.buf = append(e.buf, `","pos":`...)
+	e.pos(n.Pos())
+	e.buf = append(e.buf, `,"end":`...)
+	e.pos(n.End())
+}
+
+func (e *jsonEncoder) openToken(kind string, n Node, tok token.Token) {
+	e.open(kind, n)
+	e.buf = append(e.buf, `,"token":{"type":`...)
+	e.string(string(tok.Type))
+	e.buf = append(e.buf, `,"literal":`...)
+	e.string(tok.Literal)
+	e.buf = append(e.buf, `,"pos":`...)
+	e.pos(tok.Pos)
+	e.buf = append(e.buf, '}')
+}
+
+func (e *jsonEncoder) field(name string) {
+	e.buf = append(e.buf, ',', '"')
+	e.buf = append(e.buf, name...)
+	e.buf = append(e.buf, '"', ':')
+}
+
+func (e *jsonEncoder) pos(p token.Pos) {
+	e.buf = append(e.buf, '[')
+	e.buf = strconv.AppendInt(e.buf, int64(p.Offset), 10)
+	e.buf = append(e.buf, ',')
+	e.buf = strconv.AppendInt(e.buf, int64(p.Line), 10)
+	e.buf = append(e.buf, ',')
+	e.buf = strconv.AppendInt(e.buf, int64(p.Column), 10)
+	e.buf = append(e.buf, ']')
+}
+
+func (e *jsonEncoder) optionalPos(p token.Pos) {
+	if p.Line == 0 {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	e.pos(p)
+}
+
+func (e *jsonEncoder) span(s Span) {
+	e.field("span")
+	e.buf = append(e.buf, '[')
+	e.pos(s.Start)
+	e.buf = append(e.buf, ',')
+	e.pos(s.End)
+	e.buf = append(e.buf, ']')
+}
+
+const hexDigits = "0123456789abcdef"
+
+func (e *jsonEncoder) string(s string) {
+	e.buf = append(e.buf, '"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= ' ' && c != '"' && c != '\\' {
+			continue
+		}
+		e.buf = append(e.buf, s[start:i]...)
+		switch c {
+		case '"', '\\':
+			e.buf = append(e.buf, '\\', c)
+		case '\n':
+			e.buf = append(e.buf, '\\', 'n')
+		case '\t':
+			e.buf = append(e.buf, '\\', 't')
+		case '\r':
+			e.buf = append(e.buf, '\\', 'r')
+		default:
+			e.buf = append(e.buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+		}
+		start = i + 1
+	}
+	e.buf = append(e.buf, s[start:]...)
+	e.buf = append(e.buf, '"')
+}
+
+// UnmarshalJSON decodes a document written by MarshalJSON. The JSON is
+// parsed with the simd parser, and when a is not nil both its tree and the
+// decoded nodes, lists and strings are carved from a, so a warm arena
+// decodes without allocating; the result is valid until a is reset. With a
+// nil arena the nodes live on the Go heap. The result does not refer to
+// data either way.
+//
+// Expressions, blocks and types may nest at most MaxJSONDepth deep, counted
+// as the parser counts them, so every tree the parser builds within its
+// default depth decodes and deeper documents are rejected before they
+// overflow the stack.
+func UnmarshalJSON(data []byte, a *arena.BestArena) (node Node, err error) {
+	scratch := a
+	if scratch == nil {
+		scratch = arena.NewBestArena()
+	}
+	p := simd.NewParser(data, scratch)
+	p.MaxDepth = maxDocumentDepth
+	root, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			jerr, ok := r.(jsonError)
+			if !ok {
+				panic(r)
+			}
+			node, err = nil, jerr
+		}
+	}()
+
+	d := &jsonDecoder{arena: a}
+	if root.Type != simd.Object {
+		d.fail("document is not an object")
+	}
+	version := d.member(root, "version")
+	if version == nil {
+		d.fail("document has no version")
+	}
+	if version.Type != simd.Number || (version.ValueStr != strconv.Itoa(JSONVersion) && version.ValueStr != "1") {
+		d.fail("unsupported version %s", version.ValueStr)
+	}
+	tree := d.member(root, "root")
+	if tree == nil {
+		d.fail("document has no root")
+	}
+	return d.node(tree), nil
+}
+
+// MaxJSONDepth is how deeply UnmarshalJSON lets expressions, blocks and
+// types nest, the parser's default MaxDepth.
+const MaxJSONDepth = 1000
+
+// maxDocumentDepth bounds the nesting of the JSON itself. It is well above
+// MaxJSONDepth because the parser builds a chain like 1 + 2 + 3 at a single
+// depth while its JSON nests once per operator.
+const maxDocumentDepth = 1 << 16
+
+type jsonError struct{ msg string }
+
+func (e jsonError) Error() string { return "ast: " + e.msg }
+
+type jsonDecoder struct {
+	arena *arena.BestArena
+	depth int
+}
+
+// enter is called on decoding an expression, block or type, and fails if
+// that nests them deeper than MaxJSONDepth; leave is called once it is
+// decoded.
+func (d *jsonDecoder) enter() {
+	d.depth++
+	if d.depth > MaxJSONDepth {
+		d.fail("maximum nesting depth of %d exceeded", MaxJSONDepth)
+	}
+}
+
+func (d *jsonDecoder) leave() {
+	d.depth--
+}
+
+func (d *jsonDecoder) fail(format string, args ...any) {
+	panic(jsonError{fmt.Sprintf(format, args...)})
+}
+
+// member returns the value of obj's member key, or nil if there is none.
+func (d *jsonDecoder) member(obj *simd.Node, key string) *simd.Node {
+	for m := obj.Children; m != nil; m = m.Next {
+		if m.Key == key {
+			return m
+		}
+	}
+	return nil
+}
+
+func (d *jsonDecoder) node(v *simd.Node) Node {
+	if v == nil || v.Type == simd.Null {
+		return nil
+	}
+	if v.Type != simd.Object {
+		d.fail("expected a node, found %s", describe(v))
+	}
+	kind := d.member(v, "node")
+	if kind == nil || kind.Type != simd.String {
+		d.fail("node without a type")
+	}
+
+	switch kind.ValueStr {
+	case "Program":
+		return jsonAlloc(d, Program{Statements: decodeList(d, d.member(v, "statements"), d.statement)})
+	case "ExpressionStatement":
+		return jsonAlloc(d, ExpressionStatement{Token: d.token(v), Expression: d.expression(d.member(v, "expression"))})
+	case "LetStatement":
+		return jsonAlloc(d, LetStatement{
+			Token: d.token(v),
+			Name:  d.identifier(d.member(v, "name")),
+			Type:  d.typeExpression(d.member(v, "type")),
+			Value: d.expression(d.member(v, "value")),
+		})
+	case "ReturnStatement":
+		return jsonAlloc(d, ReturnStatement{Token: d.token(v), Value: d.expression(d.member(v, "value"))})
+	case "BlockStatement":
+		d.enter()
+		defer d.leave()
+		return jsonAlloc(d, BlockStatement{
+			Token:      d.token(v),
+			Statements: decodeList(d, d.member(v, "statements"), d.statement),
+			Rbrace:     d.optionalPos(d.member(v, "rbrace")),
+		})
+	case "IfStatement":
+		return jsonAlloc(d, IfStatement{
+			Token:       d.token(v),
+			Condition:   d.expression(d.member(v, "condition")),
+			Consequence: d.statement(d.member(v, "consequence")),
+			Alternative: d.statement(d.member(v, "alternative")),
+		})
+	case "UnaryExpression":
+		return jsonAlloc(d, UnaryExpression{Token: d.token(v), Right: d.expression(d.member(v, "right"))})
+	case "BinaryExpression":
+		return jsonAlloc(d, BinaryExpression{Token: d.token(v), Left: d.operand(d.member(v, "left")), Right: d.expression(d.member(v, "right"))})
+	case "AssignExpression":
+		return jsonAlloc(d, AssignExpression{Token: d.token(v), Name: d.identifier(d.member(v, "name")), Value: d.expression(d.member(v, "value"))})
+	case "PipeExpression":
+		return jsonAlloc(d, PipeExpression{Token: d.token(v), Left: d.operand(d.member(v, "left")), Right: d.expression(d.member(v, "right"))})
+	case "FunctionInvokeExpression":
+		return jsonAlloc(d, FunctionInvokeExpression{
+			Token:     d.token(v),
+			Arguments: decodeList(d, d.member(v, "arguments"), d.expression),
+			Rparen:    d.optionalPos(d.member(v, "rparen")),
+		})
+	case "FunctionExpression":
+		params := decodeList(d, d.member(v, "parameters"), func(p *simd.Node) Identifier {
+			ident := d.identifier(p)
+			if ident == nil {
+				d.fail("null parameter")
+			}
+			return *ident
+		})
+		arrow := d.bool(d.member(v, "arrow"))
+		if arrow {
+			// The parser wraps an arrow's expression in a block without
+			// nesting any deeper.
+			d.depth--
+			defer func() { d.depth++ }()
+		}
+		return jsonAlloc(d, FunctionExpression{
+			Token:          d.token(v),
+			Parameters:     params,
+			ParameterTypes: decodeList(d, d.member(v, "parameterTypes"), d.typeExpression),
+			Body:           d.statement(d.member(v, "body")),
+			Arrow:          arrow,
+		})
+	case "Identifier":
+		return d.identifier(v)
+	case "IntegerLiteral":
+		value := d.member(v, "value")
+		if value == nil || value.Type != simd.Number {
+			d.fail("IntegerLiteral without a value")
+		}
+		n, err := strconv.ParseInt(value.ValueStr, 10, 64)
+		if err != nil {
+			d.fail("invalid integer %s", value.ValueStr)
+		}
+		return jsonAlloc(d, IntegerLiteral{Token: d.token(v), Value: n})
+	case "BooleanLiteral":
+		return jsonAlloc(d, BooleanLiteral{Token: d.token(v), Value: d.bool(d.member(v, "value"))})
+	case "NamedType":
+		return jsonAlloc(d, NamedType{
+			Token:     d.token(v),
+			Name:      d.string(d.member(v, "name")),
+			Arguments: decodeList(d, d.member(v, "arguments"), d.typeExpression),
+			Rparen:    d.optionalPos(d.member(v, "rparen")),
+		})
+	case "FunctionType":
+		return jsonAlloc(d, FunctionType{
+			Token:      d.token(v),
+			Parameters: decodeList(d, d.member(v, "parameters"), d.typeExpression),
+			Result:     d.typeExpression(d.member(v, "result")),
+		})
+	case "MissingExpression":
+		return jsonAlloc(d, MissingExpression{Token: d.token(v), Span: d.span(d.member(v, "span"))})
+	case "MissingStatement":
+		return jsonAlloc(d, MissingStatement{Token: d.token(v), Span: d.span(d.member(v, "span"))})
+	case "MissingType":
+		return jsonAlloc(d, MissingType{Token: d.token(v), Span: d.span(d.member(v, "span"))})
+	}
+	d.fail("unknown node type %q", kind.ValueStr)
+	return nil
+}
+
+// jsonAlloc returns a pointer to a copy of v, in the decoder's arena if it
+// has one.
+func jsonAlloc[T any](d *jsonDecoder, v T) *T {
+	var n *T
+	if d.arena == nil {
+		n = new(T)
+	} else {
+		n = arena.Alloc[T](d.arena)
+	}
+	*n = v
+	return n
+}
+
+func decodeList[T any](d *jsonDecoder, v *simd.Node, elem func(*simd.Node) T) []T {
+	if v == nil || v.Type == simd.Null {
+		return nil
+	}
+	if v.Type != simd.Array {
+		d.fail("expected a list, found %s", describe(v))
+	}
+	n := 0
+	for e := v.Children; e != nil; e = e.Next {
+		n++
+	}
+	var list []T
+	if d.arena == nil {
+		list = make([]T, n)
+	} else {
+		list = arena.AllocSlice[T](d.arena, n)
+	}
+	i := 0
+	for e := v.Children; e != nil; e = e.Next {
+		list[i] = elem(e)
+		i++
+	}
+	return list
+}
+
+func (d *jsonDecoder) statement(v *simd.Node) Statement {
+	n := d.node(v)
+	if n == nil {
+		return nil
+	}
+	s, ok := n.(Statement)
+	if !ok {
+		d.fail("%T is not a statement", n)
+	}
+	return s
+}
+
+func (d *jsonDecoder) expression(v *simd.Node) Expression {
+	d.enter()
+	defer d.leave()
+	return d.operand(v)
+}
+
+// operand decodes the left operand of a binary or pipe expression, which
+// the parser builds at the depth of the whole expression.
+func (d *jsonDecoder) operand(v *simd.Node) Expression {
+	n := d.node(v)
+	if n == nil {
+		return nil
+	}
+	e, ok := n.(Expression)
+	if !ok {
+		d.fail("%T is not an expression", n)
+	}
+	return e
+}
+
+func (d *jsonDecoder) typeExpression(v *simd.Node) TypeExpression {
+	d.enter()
+	defer d.leave()
+	n := d.node(v)
+	if n == nil {
+		return nil
+	}
+	t, ok := n.(TypeExpression)
+	if !ok {
+		d.fail("%T is not a type", n)
+	}
+	return t
+}
+
+func (d *jsonDecoder) identifier(v *simd.Node) *Identifier {
+	if v == nil || v.Type == simd.Null {
+		return nil
+	}
+	if kind := d.member(v, "node"); kind == nil || kind.ValueStr != "Identifier" {
+		d.fail("expected an Identifier, found %s", describe(v))
+	}
+	tok := d.token(v)
+	value := d.member(v, "value")
+	ident := Identifier{Token: tok, Value: tok.Literal}
+	if value == nil || value.Type != simd.String {
+		d.fail("Identifier without a value")
+	}
+	if value.ValueStr != tok.Literal {
+		ident.Value = d.string(value)
+	}
+	return jsonAlloc(d, ident)
+}
+
+func (d *jsonDecoder) token(v *simd.Node) token.Token {
+	t := d.member(v, "token")
+	if t == nil || t.Type != simd.Object {
+		d.fail("node without a token")
+	}
+	typ := d.member(t, "type")
+	if typ == nil || typ.Type != simd.String {
+		d.fail("token without a type")
+	}
+	tt, ok := tokenTypes[typ.ValueStr]
+	if !ok {
+		tt = token.TokenType(d.string(typ))
+	}
+	return token.Token{
+		Type:    tt,
+		Literal: d.string(d.member(t, "literal")),
+		Pos:     d.pos(d.member(t, "pos")),
+	}
+}
+
+// tokenTypes lets decoded tokens share the constant type names rather than
+// copy each one.
+var tokenTypes = func() map[string]token.TokenType {
+	m := make(map[string]token.TokenType)
+	for _, t := range []token.TokenType{
+		token.ILLEGAL, token.EOF, token.IDENT, token.INT, token.COMMA, token.SEMICOLON,
+		token.COLON, token.ASSIGN, token.PLUS, token.MINUS, token.LPAREN, token.RPAREN,
+		token.LBRACE, token.RBRACE, token.FUNCTION, token.LET, token.BANG, token.ASTERISK,
+		token.POWER, token.SLASH, token.LT, token.GT, token.IF, token.ELSE, token.RETURN,
+		token.TRUE, token.FALSE, token.EQUAL, token.NOTEQUAL, token.PIPE, token.ARROW,
+	} {
+		m[string(t)] = t
+	}
+	return m
+}()
+
+func (d *jsonDecoder) pos(v *simd.Node) token.Pos {
+	if v == nil || v.Type != simd.Array {
+		d.fail("expected a position, found %s", describe(v))
+	}
+	var p [3]int
+	i := 0
+	for e := v.Children; e != nil; e = e.Next {
+		if i == len(p) || e.Type != simd.Number {
+			d.fail("malformed position")
+		}
+		n, err := strconv.Atoi(e.ValueStr)
+		if err != nil || n < 0 {
+			d.fail("malformed position")
+		}
+		p[i] = n
+		i++
+	}
+	if i != len(p) {
+		d.fail("malformed position")
+	}
+	return token.Pos{Offset: p[0], Line: p[1], Column: p[2]}
+}
+
+func (d *jsonDecoder) optionalPos(v *simd.Node) token.Pos {
+	if v == nil || v.Type == simd.Null {
+		return token.Pos{}
+	}
+	return d.pos(v)
+}
+
+func (d *jsonDecoder) span(v *simd.Node) Span {
+	if v == nil || v.Type != simd.Array || v.Children == nil || v.Children.Next == nil {
+		d.fail("expected a span, found %s", describe(v))
+	}
+	return Span{Start: d.pos(v.Children), End: d.pos(v.Children.Next)}
+}
+
+func (d *jsonDecoder) bool(v *simd.Node) bool {
+	if v == nil || v.Type == simd.False {
+		return false
+	}
+	if v.Type != simd.True {
+		d.fail("expected a boolean, found %s", describe(v))
+	}
+	return true
+}
+
+// string returns a copy of a string value that does not point into the
+// document.
+func (d *jsonDecoder) string(v *simd.Node) string {
+	if v == nil || v.Type != simd.String {
+		d.fail("expected a string, found %s", describe(v))
+	}
+	s, err := simd.Unescape(v.ValueStr)
+	if err != nil {
+		d.fail("%v", err)
+	}
+	if d.arena != nil {
+		return d.arena.String(s)
+	}
+	return strings.Clone(s)
+}
+
+func describe(v *simd.Node) string {
+	if v == nil {
+		return "nothing"
+	}
+	switch v.Type {
+	case simd.Null:
+		return "null"
+	case simd.True, simd.False:
+		return "a boolean"
+	case simd.Number:
+		return "a number"
+	case simd.String:
+		return "a string"
+	case simd.Object:
+		return "an object"
+	}
+	return "a list"
+}
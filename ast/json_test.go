@@ -0,0 +1,233 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"mcompiler/arena"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"mcompiler/token"
+	"strings"
+	"testing"
+)
+
+func TestJSON_RoundTrip(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  parser.Options
+	}{
+		{posSource, parser.Options{}},
+		{walkSource, parser.Options{}},
+		{walkSource, parser.Options{LazyBodies: true}},
+		{"let x = ; if (x { y(1, ; } fn(a) => ;", parser.Options{Tolerant: true}},
+		{"", parser.Options{}},
+		{"let f: fn(array(int)) => int = fn(xs: array(int), n) => n;", parser.Options{}},
+	}
+	for _, tt := range tests {
+		program := parser.NewWithOptions(lexer.New(tt.input), tt.opts).ParseProgram()
+		data, err := ast.MarshalJSON(program)
+		if err != nil {
+			t.Fatalf("MarshalJSON(%q): %v", tt.input, err)
+		}
+		if !json.Valid(data) {
+			t.Fatalf("MarshalJSON(%q) wrote invalid JSON: %s", tt.input, data)
+		}
+
+		for _, a := range []*arena.BestArena{nil, arena.NewBestArena()} {
+			node, err := ast.UnmarshalJSON(data, a)
+			if err != nil {
+				t.Fatalf("UnmarshalJSON(%q): %v", tt.input, err)
+			}
+			decoded := node.(*ast.Program)
+			if !ast.EqualPositions(decoded, program) {
+				t.Errorf("decoded %q, want %q", decoded.String(), program.String())
+			}
+			if got, want := spans(decoded), spans(program); got != want {
+				t.Errorf("decoded extents:\n%s\nwant:\n%s", got, want)
+			}
+			again, err := ast.MarshalJSON(decoded)
+			if err != nil || string(again) != string(data) {
+				t.Errorf("re-encoding %q differs:\n%s\nwant:\n%s", tt.input, again, data)
+			}
+		}
+	}
+}
+
+func TestJSON_Schema(t *testing.T) {
+	data, err := ast.MarshalJSON(parse(t, "f(-x);", parser.Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"version":2,"root":{"node":"Program","pos":[0,1,1],"end":[5,1,6],"statements":[` +
+		`{"node":"ExpressionStatement","pos":[0,1,1],"end":[5,1,6],"token":{"type":"IDENT","literal":"f","pos":[0,1,1]},"expression":` +
+		`{"node":"FunctionInvokeExpression","pos":[0,1,1],"end":[5,1,6],"token":{"type":"IDENT","literal":"f","pos":[0,1,1]},"arguments":[` +
+		`{"node":"UnaryExpression","pos":[2,1,3],"end":[4,1,5],"token":{"type":"-","literal":"-","pos":[2,1,3]},"right":` +
+		`{"node":"Identifier","pos":[3,1,4],"end":[4,1,5],"token":{"type":"IDENT","literal":"x","pos":[3,1,4]},"value":"x"}}` +
+		`],"rparen":[4,1,5]}}]}}`
+	if string(data) != expected {
+		t.Errorf("MarshalJSON =\n%s\nwant:\n%s", data, expected)
+	}
+}
+
+func TestJSON_Types(t *testing.T) {
+	typ := parser.New(lexer.New("fn(int, fn(bool) => int) => bool")).ParseType()
+	data, err := ast.MarshalJSON(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := ast.UnmarshalJSON(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := node.(*ast.FunctionType); !ok || node.String() != typ.String() {
+		t.Errorf("decoded %T %s, want %s", node, node, typ)
+	}
+}
+
+func TestJSON_Escapes(t *testing.T) {
+	ident := &ast.Identifier{
+		Token: token.Token{Type: token.IDENT, Literal: "a\"b\\c\n\x01"},
+		Value: "a\"b\\c\n\x01",
+	}
+	data, err := ast.MarshalJSON(ident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(data) {
+		t.Fatalf("invalid JSON: %s", data)
+	}
+	node, err := ast.UnmarshalJSON(data, arena.NewBestArena())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := node.(*ast.Identifier); got.Value != ident.Value || got.Token.Literal != ident.Token.Literal {
+		t.Errorf("decoded %q, want %q", got.Value, ident.Value)
+	}
+}
+
+func TestJSON_Errors(t *testing.T) {
+	if _, err := ast.MarshalJSON(&custom{Inner: &ast.IntegerLiteral{}}); err == nil ||
+		!strings.Contains(err.Error(), "cannot encode *ast_test.custom") {
+		t.Errorf("MarshalJSON of a custom node: %v", err)
+	}
+
+	pos := `"pos":[0,1,1],"end":[1,1,2]`
+	tok := `"token":{"type":"INT","literal":"1","pos":[0,1,1]}`
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`{"version":1,"root":`, "simd:"},
+		{`[]`, "not an object"},
+		{`{"version":3,"root":null}`, "unsupported version 3"},
+		{`{"root":null}`, "document has no version"},
+		{`{"version":1}`, "document has no root"},
+		{`{"version":1,"root":{"node":"Blob"}}`, `unknown node type "Blob"`},
+		{`{"version":1,"root":{"node":"IntegerLiteral",` + pos + `,"value":1}}`, "node without a token"},
+		{`{"version":1,"root":{"node":"IntegerLiteral",` + pos + `,` + tok + `,"value":"1"}}`, "without a value"},
+		{`{"version":1,"root":{"node":"ReturnStatement",` + pos + `,` + tok + `,"value":{"node":"ReturnStatement",` + tok + `}}}`,
+			"*ast.ReturnStatement is not an expression"},
+		{`{"version":1,"root":{"node":"Program","statements":{}}}`, "expected a list, found an object"},
+		{`{"version":1,"root":{"node":"IntegerLiteral","token":{"type":"INT","literal":"1","pos":[0,1]},"value":1}}`, "malformed position"},
+	}
+	for _, tt := range tests {
+		_, err := ast.UnmarshalJSON([]byte(tt.input), nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("UnmarshalJSON(%s) error = %v, want %q", tt.input, err, tt.err)
+		}
+	}
+}
+
+// Every tree the parser builds within its default depth decodes, and
+// deeper documents fail before they exhaust the stack.
+func TestJSON_MaxDepth(t *testing.T) {
+	max := parser.DefaultMaxDepth
+	for _, input := range []string{
+		strings.Repeat("-", max-1) + "1;",
+		strings.Repeat("fn() { ", max/2-1) + "1" + strings.Repeat(" }", max/2-1) + ";",
+		strings.Repeat("if (a) { ", max-1) + strings.Repeat("}", max-1),
+		strings.Repeat("fn() => ", max-1) + "1;",
+		strings.Repeat("1 + ", 5000) + "1;",
+	} {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%.20q...: %v", input, p.Errors()[0])
+		}
+		data, err := ast.MarshalJSON(program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ast.UnmarshalJSON(data, nil); err != nil {
+			t.Errorf("UnmarshalJSON of %.20q...: %v", input, err)
+		}
+	}
+
+	unary := func(n int) string {
+		tok := `"token":{"type":"-","literal":"-","pos":[0,1,1]}`
+		return `{"version":1,"root":` + strings.Repeat(`{"node":"UnaryExpression",`+tok+`,"right":`, n) +
+			`null` + strings.Repeat("}", n) + "}"
+	}
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{unary(ast.MaxJSONDepth + 1), "maximum nesting depth of 1000 exceeded"},
+		{unary(3_000_000), "simd: Maximum nesting depth"},
+	}
+	for _, tt := range tests {
+		_, err := ast.UnmarshalJSON([]byte(tt.input), nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("UnmarshalJSON of %d bytes: %v, want %q", len(tt.input), err, tt.err)
+		}
+	}
+}
+
+func TestJSON_UnmarshalAllocations(t *testing.T) {
+	data, err := ast.MarshalJSON(parse(t, posSource, parser.Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := arena.NewBestArena()
+	allocs := testing.AllocsPerRun(100, func() {
+		a.Reset()
+		if _, err := ast.UnmarshalJSON(data, a); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("UnmarshalJSON into a warm arena made %v allocations, want 0", allocs)
+	}
+}
+
+// spans lists the extent of every node in the tree.
+func spans(node ast.Node) string {
+	var out strings.Builder
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			fmt.Fprintf(&out, "%T %v-%v\n", n, n.Pos(), n.End())
+		}
+		return true
+	})
+	return out.String()
+}
+
+func BenchmarkUnmarshalJSON(b *testing.B) {
+	source := strings.Repeat(posSource, 200)
+	program := parser.New(lexer.New(source)).ParseProgram()
+	data, err := ast.MarshalJSON(program)
+	if err != nil {
+		b.Fatal(err)
+	}
+	a := arena.NewBestArena()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Reset()
+		if _, err := ast.UnmarshalJSON(data, a); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package ast_test
+
+import (
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"mcompiler/token"
+	"strings"
+	"testing"
+)
+
+const posSource = `let add = fn(a, b) {
+  return a + b * 2;
+};
+if (add(1, 2) > x) { y = -3; } else { xs |> sum }
+let sq = fn(x) => x ** 2;
+`
+
+func TestPosEnd(t *testing.T) {
+	program := parse(t, posSource, parser.Options{})
+
+	text := func(n ast.Node) string {
+		return posSource[n.Pos().Offset:n.End().Offset]
+	}
+
+	var got []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.BinaryExpression, *ast.BlockStatement, *ast.FunctionInvokeExpression,
+			*ast.IfStatement, *ast.PipeExpression, *ast.AssignExpression, *ast.FunctionExpression:
+			got = append(got, text(n))
+		}
+		return true
+	})
+	expected := []string{
+		"fn(a, b) {\n  return a + b * 2;\n}",
+		"{\n  return a + b * 2;\n}",
+		"a + b * 2",
+		"b * 2",
+		"if (add(1, 2) > x) { y = -3; } else { xs |> sum }",
+		"add(1, 2) > x",
+		"add(1, 2)",
+		"{ y = -3; }",
+		"y = -3",
+		"{ xs |> sum }",
+		"xs |> sum",
+		"fn(x) => x ** 2",
+		"=> x ** 2", // the desugared block starts at its arrow
+		"x ** 2",
+	}
+	if strings.Join(got, "\n|") != strings.Join(expected, "\n|") {
+		t.Errorf("extents:\n%q\nwant:\n%q", got, expected)
+	}
+
+	if text(program) != strings.TrimSuffix(posSource, ";\n") {
+		t.Errorf("program extent = %q", text(program))
+	}
+}
+
+// Every node's Line and Column agree with its Offset, and children lie
+// within their parents.
+func TestPosEnd_Nesting(t *testing.T) {
+	input := posSource + "\nlet f = fn() { g(h(1), !true) };"
+	for _, opts := range []parser.Options{{}, {LazyBodies: true}} {
+		program := parse(t, input, opts)
+
+		var parents []ast.Node
+		ast.Inspect(program, func(n ast.Node) bool {
+			if n == nil {
+				parents = parents[:len(parents)-1]
+				return true
+			}
+			checkPos(t, input, n, n.Pos())
+			checkPos(t, input, n, n.End())
+			if n.End().Offset < n.Pos().Offset {
+				t.Errorf("%s ends at %s before it starts at %s", n, n.End(), n.Pos())
+			}
+			if len(parents) > 0 {
+				parent := parents[len(parents)-1]
+				if n.Pos().Offset < parent.Pos().Offset || n.End().Offset > parent.End().Offset {
+					t.Errorf("%s [%s, %s) is outside its parent %s [%s, %s)",
+						n, n.Pos(), n.End(), parent, parent.Pos(), parent.End())
+				}
+			}
+			parents = append(parents, n)
+			return true
+		})
+	}
+}
+
+func checkPos(t *testing.T, src string, n ast.Node, pos token.Pos) {
+	t.Helper()
+	line := 1 + strings.Count(src[:pos.Offset], "\n")
+	column := pos.Offset - strings.LastIndexByte(src[:pos.Offset], '\n')
+	if pos.Line != line || pos.Column != column {
+		t.Errorf("%s: position %s does not match offset %d (%d:%d)", n, pos, pos.Offset, line, column)
+	}
+}
+
+// Parentheses belong to no node, and a statement leaves out its `;`.
+func TestPosEnd_Parentheses(t *testing.T) {
+	input := "(a + b) * c;\nf((x));\n"
+	program := parse(t, input, parser.Options{})
+
+	var got []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			got = append(got, input[n.Pos().Offset:n.End().Offset])
+		}
+		return true
+	})
+	expected := []string{
+		"(a + b) * c;\nf((x))", // the program
+		"(a + b) * c",          // the statement starts at its first token
+		"a + b) * c",
+		"a + b",
+		"a",
+		"b",
+		"c",
+		"f((x))",
+		"f((x))",
+		"x",
+	}
+	if strings.Join(got, "\n|") != strings.Join(expected, "\n|") {
+		t.Errorf("extents:\n%q\nwant:\n%q", got, expected)
+	}
+}
+
+func TestPosEnd_Missing(t *testing.T) {
+	p := parser.NewWithOptions(lexer.New("let x = ;"), parser.Options{Tolerant: true})
+	let := p.ParseProgram().Statements[0]
+	if let.Pos().String() != "1:1" || let.End().String() != "1:10" {
+		t.Errorf("let spans %s-%s, want 1:1-1:10", let.Pos(), let.End())
+	}
+}
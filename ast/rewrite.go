@@ -0,0 +1,219 @@
+package ast
+
+import "fmt"
+
+// Rewrite traverses the AST rooted at root in the order of Walk, calling pre
+// before and post after the children of each non-nil node, and returns the
+// possibly replaced root. The functions may change the tree through the
+// Cursor they are given.
+//
+// If pre returns false, the children and post of that node are skipped. If
+// post returns false, the traversal stops and Rewrite returns at once.
+//
+// Nodes and lists created by a rewrite live on the Go heap, so a tree
+// allocated in an arena must not be rewritten while the arena is in use.
+func Rewrite(root Node, pre, post func(*Cursor) bool) (result Node) {
+	defer func() {
+		if r := recover(); r != nil && r != errAbort {
+			panic(r)
+		}
+	}()
+
+	a := &application{pre: pre, post: post}
+	result = root
+	a.apply(nil, "", nil, func(n Node) { result = n }, root)
+	return result
+}
+
+var errAbort = new(int)
+
+// A Cursor describes the node being visited by Rewrite and lets pre and post
+// change it. The same Cursor is reused for every node.
+type Cursor struct {
+	parent Node
+	name   string
+	iter   *iterator // set if the node is an element of a list
+	node   Node
+	set    func(Node)
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current node, or nil at the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the parent's field holding the current node,
+// such as "Statements" or "Left".
+func (c *Cursor) Name() string { return c.name }
+
+// Index returns the index of the current node in its list, or -1 if it is
+// not part of a list.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// Replace puts n in place of the current node. If called from pre, Rewrite
+// goes on with the children of n.
+func (c *Cursor) Replace(n Node) {
+	if n == nil {
+		panic("ast.Cursor.Replace: nil node; use Delete to remove a list element")
+	}
+	c.set(n)
+	c.node = n
+}
+
+// Delete removes the current node from its list. Its children are not
+// visited, nor is post called for it.
+func (c *Cursor) Delete() {
+	it := c.list("Delete")
+	it.list.delete(it.index)
+	it.index--
+	c.node = nil
+}
+
+// InsertBefore inserts n before the current node in its list. Rewrite does
+// not visit n.
+func (c *Cursor) InsertBefore(n Node) {
+	it := c.list("InsertBefore")
+	it.list.insert(it.index, n)
+	it.index++
+}
+
+// InsertAfter inserts n after the current node in its list. Rewrite does not
+// visit n.
+func (c *Cursor) InsertAfter(n Node) {
+	it := c.list("InsertAfter")
+	it.list.insert(it.index+1, n)
+	it.step++
+}
+
+func (c *Cursor) list(method string) *iterator {
+	if c.iter == nil {
+		panic(fmt.Sprintf("ast.Cursor.%s: %T is not in a list", method, c.node))
+	}
+	return c.iter
+}
+
+type iterator struct {
+	index, step int
+	list        nodeList
+}
+
+type nodeList interface {
+	set(i int, n Node)
+	delete(i int)
+	insert(i int, n Node)
+}
+
+// slice adapts a field such as []Statement to nodeList. Elements of the
+// wrong type panic on insertion, as an invalid Replace does.
+type slice[N Node] struct{ s *[]N }
+
+func (l slice[N]) set(i int, n Node) { (*l.s)[i] = n.(N) }
+
+func (l slice[N]) delete(i int) {
+	*l.s = append((*l.s)[:i], (*l.s)[i+1:]...)
+}
+
+func (l slice[N]) insert(i int, n Node) {
+	var zero N
+	*l.s = append(*l.s, zero)
+	copy((*l.s)[i+1:], (*l.s)[i:])
+	(*l.s)[i] = n.(N)
+}
+
+type application struct {
+	pre, post func(*Cursor) bool
+	cursor    Cursor
+}
+
+func (a *application) apply(parent Node, name string, iter *iterator, set func(Node), n Node) {
+	if n == nil {
+		return
+	}
+
+	saved := a.cursor
+	a.cursor = Cursor{parent: parent, name: name, iter: iter, node: n, set: set}
+	defer func() { a.cursor = saved }()
+
+	if a.pre != nil && !a.pre(&a.cursor) {
+		return
+	}
+	if n = a.cursor.node; n == nil {
+		return
+	}
+	a.children(n)
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(errAbort)
+	}
+}
+
+func applyList[N Node](a *application, parent Node, name string, s *[]N) {
+	it := &iterator{list: slice[N]{s}}
+	for it.index = 0; it.index < len(*s); it.index += it.step {
+		it.step = 1
+		a.apply(parent, name, it, func(n Node) { it.list.set(it.index, n) }, (*s)[it.index])
+	}
+}
+
+func (a *application) children(node Node) {
+	switch n := node.(type) {
+	case *Program:
+		applyList(a, n, "Statements", &n.Statements)
+	case *ExpressionStatement:
+		a.apply(n, "Expression", nil, func(x Node) { n.Expression = x.(Expression) }, n.Expression)
+	case *LetStatement:
+		if n.Name != nil {
+			a.apply(n, "Name", nil, func(x Node) { n.Name = x.(*Identifier) }, n.Name)
+		}
+		a.apply(n, "Type", nil, func(x Node) { n.Type = x.(TypeExpression) }, n.Type)
+		a.apply(n, "Value", nil, func(x Node) { n.Value = x.(Expression) }, n.Value)
+	case *ReturnStatement:
+		a.apply(n, "Value", nil, func(x Node) { n.Value = x.(Expression) }, n.Value)
+	case *BlockStatement:
+		applyList(a, n, "Statements", &n.Statements)
+	case *IfStatement:
+		a.apply(n, "Condition", nil, func(x Node) { n.Condition = x.(Expression) }, n.Condition)
+		a.apply(n, "Consequence", nil, func(x Node) { n.Consequence = x.(Statement) }, n.Consequence)
+		a.apply(n, "Alternative", nil, func(x Node) { n.Alternative = x.(Statement) }, n.Alternative)
+	case *UnaryExpression:
+		a.apply(n, "Right", nil, func(x Node) { n.Right = x.(Expression) }, n.Right)
+	case *BinaryExpression:
+		a.apply(n, "Left", nil, func(x Node) { n.Left = x.(Expression) }, n.Left)
+		a.apply(n, "Right", nil, func(x Node) { n.Right = x.(Expression) }, n.Right)
+	case *AssignExpression:
+		if n.Name != nil {
+			a.apply(n, "Name", nil, func(x Node) { n.Name = x.(*Identifier) }, n.Name)
+		}
+		a.apply(n, "Value", nil, func(x Node) { n.Value = x.(Expression) }, n.Value)
+	case *PipeExpression:
+		a.apply(n, "Left", nil, func(x Node) { n.Left = x.(Expression) }, n.Left)
+		a.apply(n, "Right", nil, func(x Node) { n.Right = x.(Expression) }, n.Right)
+	case *FunctionInvokeExpression:
+		applyList(a, n, "Arguments", &n.Arguments)
+	case *FunctionExpression:
+		for i := range n.Parameters {
+			a.apply(n, "Parameters", nil, func(x Node) { n.Parameters[i] = *x.(*Identifier) }, &n.Parameters[i])
+			if typ := n.ParameterType(i); typ != nil {
+				a.apply(n, "ParameterTypes", nil, func(x Node) { n.ParameterTypes[i] = x.(TypeExpression) }, typ)
+			}
+		}
+		a.apply(n, "Body", nil, func(x Node) { n.Body = x.(Statement) }, n.ParseBody())
+	case *NamedType:
+		applyList(a, n, "Arguments", &n.Arguments)
+	case *FunctionType:
+		applyList(a, n, "Parameters", &n.Parameters)
+		a.apply(n, "Result", nil, func(x Node) { n.Result = x.(TypeExpression) }, n.Result)
+	case *Identifier, *IntegerLiteral, *BooleanLiteral,
+		*MissingExpression, *MissingStatement, *MissingType:
+		// no children
+	default:
+		// Nodes from other packages are left as they are; Walk reaches
+		// into them through ChildWalker, but they offer no way to replace
+		// their children.
+	}
+}
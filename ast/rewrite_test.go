@@ -0,0 +1,172 @@
+package ast_test
+
+import (
+	"mcompiler/ast"
+	"mcompiler/parser"
+	"mcompiler/token"
+	"strings"
+	"testing"
+)
+
+func TestRewrite_BinaryExpressions(t *testing.T) {
+	program := parse(t, "let a = 1 + 2 * 3; let f = fn(x) { x * (4 - 1) };", parser.Options{})
+
+	// Fold integer arithmetic bottom-up, so folded operands fold again.
+	ast.Rewrite(program, nil, func(c *ast.Cursor) bool {
+		be, ok := c.Node().(*ast.BinaryExpression)
+		if !ok {
+			return true
+		}
+		left, lok := be.Left.(*ast.IntegerLiteral)
+		right, rok := be.Right.(*ast.IntegerLiteral)
+		if !lok || !rok {
+			return true
+		}
+		var value int64
+		switch be.Token.Type {
+		case token.PLUS:
+			value = left.Value + right.Value
+		case token.MINUS:
+			value = left.Value - right.Value
+		case token.ASTERISK:
+			value = left.Value * right.Value
+		default:
+			return true
+		}
+		c.Replace(&ast.IntegerLiteral{Token: left.Token, Value: value})
+		return true
+	})
+
+	if got, want := program.String(), "let a = 7;let f = fn(x){(x * 3);};"; got != want {
+		t.Errorf("program = %q, want %q", got, want)
+	}
+}
+
+func TestRewrite_ReplaceInPre(t *testing.T) {
+	program := parse(t, "a * 2; b * c;", parser.Options{})
+
+	// x * 2 becomes x + x; the replacement's children are visited.
+	var idents []string
+	ast.Rewrite(program, func(c *ast.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.BinaryExpression:
+			if two, ok := n.Right.(*ast.IntegerLiteral); ok && two.Value == 2 {
+				c.Replace(&ast.BinaryExpression{
+					Token: token.Token{Type: token.PLUS, Literal: "+"},
+					Left:  n.Left,
+					Right: n.Left,
+				})
+			}
+		case *ast.Identifier:
+			idents = append(idents, n.Value)
+		}
+		return true
+	}, nil)
+
+	if got, want := program.String(), "(a + a);(b * c);"; got != want {
+		t.Errorf("program = %q, want %q", got, want)
+	}
+	if got := strings.Join(idents, " "); got != "a a b c" {
+		t.Errorf("visited identifiers %q, want %q", got, "a a b c")
+	}
+}
+
+func TestRewrite_Statements(t *testing.T) {
+	program := parse(t, `let f = fn(x) { log(x); let y = x; log(y); return y; };`, parser.Options{})
+
+	logged := &ast.ExpressionStatement{Expression: &ast.Identifier{Value: "traced"}}
+	ast.Rewrite(program, func(c *ast.Cursor) bool {
+		if _, ok := c.Parent().(*ast.BlockStatement); !ok {
+			return true
+		}
+		switch n := c.Node().(type) {
+		case *ast.ExpressionStatement:
+			if call, ok := n.Expression.(*ast.FunctionInvokeExpression); ok && call.Token.Literal == "log" {
+				c.Delete()
+				return false
+			}
+		case *ast.ReturnStatement:
+			c.InsertBefore(logged)
+			c.InsertAfter(&ast.ExpressionStatement{Expression: &ast.Identifier{Value: "unreachable"}})
+		case *ast.LetStatement:
+			if c.Name() != "Statements" || c.Index() != 0 {
+				t.Errorf("let is %s[%d], want Statements[0]", c.Name(), c.Index())
+			}
+		}
+		return true
+	}, nil)
+
+	if got, want := program.String(), "let f = fn(x){let y = x;traced;return y;unreachable;};"; got != want {
+		t.Errorf("program = %q, want %q", got, want)
+	}
+}
+
+func TestRewrite_Program(t *testing.T) {
+	program := parse(t, "1; 2; 3; 4;", parser.Options{})
+
+	var visited []string
+	ast.Rewrite(program, func(c *ast.Cursor) bool {
+		if _, ok := c.Node().(*ast.ExpressionStatement); !ok {
+			return true
+		}
+		visited = append(visited, c.Node().String())
+		switch c.Node().String() {
+		case "1;":
+			c.Delete()
+		case "2;":
+			if c.Index() != 0 {
+				t.Errorf("2 is at index %d after deleting 1, want 0", c.Index())
+			}
+			c.InsertAfter(&ast.ExpressionStatement{Expression: &ast.Identifier{Value: "x"}})
+		}
+		return false
+	}, nil)
+
+	if got, want := program.String(), "2;x;3;4;"; got != want {
+		t.Errorf("program = %q, want %q", got, want)
+	}
+	if got, want := strings.Join(visited, " "), "1; 2; 3; 4;"; got != want {
+		t.Errorf("visited %q, want %q", got, want)
+	}
+}
+
+func TestRewrite_RootAndAbort(t *testing.T) {
+	root := ast.Rewrite(&ast.IntegerLiteral{Value: 1}, func(c *ast.Cursor) bool {
+		if c.Parent() != nil || c.Index() != -1 {
+			t.Errorf("root has parent %v at index %d", c.Parent(), c.Index())
+		}
+		c.Replace(&ast.BooleanLiteral{Token: token.Token{Literal: "true"}, Value: true})
+		return true
+	}, nil)
+	if root.String() != "true" {
+		t.Errorf("root = %s, want true", root)
+	}
+
+	program := parse(t, "1; 2; 3;", parser.Options{})
+	seen := 0
+	ast.Rewrite(program, nil, func(c *ast.Cursor) bool {
+		if _, ok := c.Node().(*ast.IntegerLiteral); ok {
+			seen++
+			return seen < 2
+		}
+		return true
+	})
+	if seen != 2 {
+		t.Errorf("post ran on %d literals after aborting at the second", seen)
+	}
+}
+
+func TestRewrite_DeleteOutsideList(t *testing.T) {
+	program := parse(t, "-1;", parser.Options{})
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Delete of a field did not panic")
+		}
+	}()
+	ast.Rewrite(program, func(c *ast.Cursor) bool {
+		if _, ok := c.Node().(*ast.IntegerLiteral); ok {
+			c.Delete()
+		}
+		return true
+	}, nil)
+}
@@ -0,0 +1,129 @@
+package ast
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// ChildWalker is implemented by nodes defined outside this package, such as
+// those built by parser extensions, to let Walk reach their children. Walk
+// treats a node that does not implement it as a leaf.
+type ChildWalker interface {
+	WalkChildren(v Visitor)
+}
+
+// Walk traverses an AST in depth-first order: it starts by calling
+// v.Visit(node); node must not be nil. If the visitor returned is not nil,
+// Walk is invoked recursively with it for each of the non-nil children of
+// node, followed by a call of w.Visit(nil). Function bodies skipped by a lazy
+// parser are parsed on the way.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkList(v, n.Statements)
+	case *ExpressionStatement:
+		walkIf(v, n.Expression)
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		walkIf(v, n.Type)
+		walkIf(v, n.Value)
+	case *ReturnStatement:
+		walkIf(v, n.Value)
+	case *BlockStatement:
+		walkList(v, n.Statements)
+	case *IfStatement:
+		walkIf(v, n.Condition)
+		walkIf(v, n.Consequence)
+		walkIf(v, n.Alternative)
+	case *UnaryExpression:
+		walkIf(v, n.Right)
+	case *BinaryExpression:
+		walkIf(v, n.Left)
+		walkIf(v, n.Right)
+	case *AssignExpression:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		walkIf(v, n.Value)
+	case *PipeExpression:
+		walkIf(v, n.Left)
+		walkIf(v, n.Right)
+	case *FunctionInvokeExpression:
+		walkList(v, n.Arguments)
+	case *FunctionExpression:
+		for i := range n.Parameters {
+			Walk(v, &n.Parameters[i])
+			walkIf(v, n.ParameterType(i))
+		}
+		walkIf(v, n.ParseBody())
+	case *NamedType:
+		walkList(v, n.Arguments)
+	case *FunctionType:
+		walkList(v, n.Parameters)
+		walkIf(v, n.Result)
+	case *Identifier, *IntegerLiteral, *BooleanLiteral,
+		*MissingExpression, *MissingStatement, *MissingType:
+		// no children
+	case ChildWalker:
+		n.WalkChildren(v)
+	default:
+		// A node from another package that does not implement
+		// ChildWalker is a leaf, as it is to Rewrite.
+	}
+
+	v.Visit(nil)
+}
+
+func walkIf(v Visitor, node Node) {
+	if node != nil {
+		Walk(v, node)
+	}
+}
+
+func walkList[N Node](v Visitor, list []N) {
+	for _, node := range list {
+		walkIf(v, node)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: it starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a call
+// of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// InspectType calls f for every node of type T in the AST rooted at node, in
+// depth-first order. If f returns false the children of that node are not
+// visited; nodes of other types are always descended into.
+//
+//	ast.InspectType(program, func(call *ast.FunctionInvokeExpression) bool {
+//		calls = append(calls, call.Token.Literal)
+//		return true
+//	})
+func InspectType[T Node](node Node, f func(T) bool) {
+	Inspect(node, func(n Node) bool {
+		if t, ok := n.(T); ok {
+			return f(t)
+		}
+		return true
+	})
+}
@@ -0,0 +1,188 @@
+package ast_test
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"strings"
+	"testing"
+)
+
+const walkSource = `let add = fn(a, b) { return a + b; };
+if (add(1, 2) > 2) { x = !true; } else { -3 ** 2; }
+xs |> map(fn(x) => x * 2);`
+
+func parse(t *testing.T, input string, opts parser.Options) *ast.Program {
+	t.Helper()
+	p := parser.NewWithOptions(lexer.New(input), opts)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+	return program
+}
+
+// tracer records the order of Visit calls, nil included.
+type tracer struct{ out *[]string }
+
+func (t tracer) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*t.out = append(*t.out, "end")
+		return nil
+	}
+	*t.out = append(*t.out, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+	return t
+}
+
+func TestWalk(t *testing.T) {
+	program := parse(t, "let f = fn(a) { -a }; if (f(1)) { 2 }", parser.Options{})
+
+	var visits []string
+	ast.Walk(tracer{&visits}, program)
+
+	expected := []string{
+		"Program",
+		"LetStatement", "Identifier", "end",
+		"FunctionExpression", "Identifier", "end",
+		"BlockStatement", "ExpressionStatement", "UnaryExpression", "Identifier", "end", "end", "end", "end",
+		"end", "end",
+		"IfStatement",
+		"FunctionInvokeExpression", "IntegerLiteral", "end", "end",
+		"BlockStatement", "ExpressionStatement", "IntegerLiteral", "end", "end", "end",
+		"end",
+		"end",
+	}
+	if got, want := strings.Join(visits, " "), strings.Join(expected, " "); got != want {
+		t.Errorf("visits:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	program := parse(t, walkSource, parser.Options{})
+
+	counts := map[string]int{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			counts[strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")]++
+		}
+		return true
+	})
+
+	expected := map[string]int{
+		"Program":                  1,
+		"LetStatement":             1,
+		"FunctionExpression":       2,
+		"BlockStatement":           4,
+		"ReturnStatement":          2,
+		"BinaryExpression":         4,
+		"IfStatement":              1,
+		"FunctionInvokeExpression": 2,
+		"AssignExpression":         1,
+		"UnaryExpression":          2,
+		"PipeExpression":           1,
+		"ExpressionStatement":      3,
+		"BooleanLiteral":           1,
+		"IntegerLiteral":           6,
+		"Identifier":               9,
+	}
+	for typ, n := range expected {
+		if counts[typ] != n {
+			t.Errorf("%d %s nodes, want %d", counts[typ], typ, n)
+		}
+	}
+	if len(counts) != len(expected) {
+		t.Errorf("visited node types %v, want %v", counts, expected)
+	}
+}
+
+func TestInspect_Prune(t *testing.T) {
+	program := parse(t, walkSource, parser.Options{})
+
+	var idents []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FunctionExpression); ok {
+			return false
+		}
+		if ident, ok := n.(*ast.Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		return true
+	})
+	if got := strings.Join(idents, " "); got != "add x xs" {
+		t.Errorf("identifiers outside functions = %q, want %q", got, "add x xs")
+	}
+}
+
+func TestInspectType(t *testing.T) {
+	program := parse(t, walkSource, parser.Options{LazyBodies: true})
+
+	var ops []string
+	ast.InspectType(program, func(be *ast.BinaryExpression) bool {
+		ops = append(ops, be.Token.Literal)
+		return be.Token.Literal != "**"
+	})
+	if got := strings.Join(ops, " "); got != "+ > ** *" {
+		t.Errorf("operators = %q, want %q", got, "+ > ** *")
+	}
+
+	var calls []string
+	ast.InspectType(program, func(call *ast.FunctionInvokeExpression) bool {
+		calls = append(calls, call.Token.Literal)
+		return true
+	})
+	if got := strings.Join(calls, " "); got != "add map" {
+		t.Errorf("calls = %q, want %q", got, "add map")
+	}
+}
+
+type custom struct {
+	ast.CustomExpression
+	Inner ast.Expression
+}
+
+func (c *custom) TokenLiteral() string { return "custom" }
+func (c *custom) String() string       { return "custom(" + c.Inner.String() + ")" }
+func (c *custom) WalkChildren(v ast.Visitor) {
+	ast.Walk(v, c.Inner)
+}
+
+func TestWalk_Custom(t *testing.T) {
+	var visits []string
+	ast.Walk(tracer{&visits}, &custom{Inner: &ast.IntegerLiteral{Value: 1}})
+	if got := strings.Join(visits, " "); got != "*ast_test.custom IntegerLiteral end end" {
+		t.Errorf("visits = %q", got)
+	}
+}
+
+// leaf is an extension node without WalkChildren.
+type leaf struct {
+	ast.CustomExpression
+}
+
+func (*leaf) TokenLiteral() string { return "leaf" }
+func (*leaf) String() string       { return "leaf" }
+
+func TestWalk_CustomLeaf(t *testing.T) {
+	var visits []string
+	ast.Walk(tracer{&visits}, &ast.ExpressionStatement{Expression: &leaf{}})
+	if got := strings.Join(visits, " "); got != "ExpressionStatement *ast_test.leaf end end" {
+		t.Errorf("visits = %q", got)
+	}
+}
+
+func TestWalk_Missing(t *testing.T) {
+	program := parser.NewWithOptions(lexer.New("let x = ; if x"), parser.Options{Tolerant: true}).ParseProgram()
+
+	missing := 0
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.MissingExpression, *ast.MissingStatement:
+			missing++
+		}
+		return true
+	})
+	if missing != 3 {
+		t.Errorf("found %d missing nodes, want 3", missing)
+	}
+}
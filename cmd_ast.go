@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"os"
+)
+
+var dumpers = map[string]func(io.Writer, ast.Node) error{
+	"tree":  ast.DumpTree,
+	"sexpr": ast.DumpSExpr,
+	"dot":   ast.DumpDot,
+}
+
+func runAST(args []string) error {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	format := flags.String("format", "tree", "output `format`: tree, sexpr or dot")
+	flags.Parse(args)
+
+	dump, ok := dumpers[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q; want tree, sexpr or dot", *format)
+	}
+	name, src, err := readSource(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	// Tolerant like parse, so the tree of a broken program can be looked at.
+	p := parser.NewWithOptions(lexer.New(src), parser.Options{Tolerant: true})
+	program := p.ParseProgram()
+	for _, d := range p.Diagnostics() {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+	}
+	if err := dump(os.Stdout, program); err != nil {
+		return err
+	}
+	if len(p.Diagnostics()) > 0 {
+		return errors.New("syntax errors")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"mcompiler/types"
+	"os"
+)
+
+func runCheck(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	printTypes := flags.Bool("types", false, "print the type of every global")
+	flags.Parse(args)
+
+	name, src, err := readSource(flags.Args())
+	if err != nil {
+		return err
+	}
+	p := parser.NewWithOptions(lexer.New(src), parser.Options{Tolerant: true})
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) > 0 {
+		for _, d := range p.Diagnostics() {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+		}
+		return errors.New("syntax errors")
+	}
+
+	info := types.Check(program, types.Options{})
+	for _, d := range info.Names.Diagnostics {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+	}
+	for _, d := range info.Diagnostics {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+	}
+	if *printTypes {
+		for _, sym := range info.Names.Global.Symbols {
+			fmt.Printf("%s: %s\n", sym.Name, info.Defs[sym])
+		}
+	}
+	if info.Names.Errors() || len(info.Diagnostics) > 0 {
+		return errors.New("type errors")
+	}
+	return nil
+}
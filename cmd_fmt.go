@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mcompiler/format"
+	"os"
+)
+
+func runFmt(args []string) error {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of standard output")
+	diff := flags.Bool("d", false, "print a diff instead of the formatted source")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			return errors.New("cannot use -w with standard input")
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		return formatFile("<stdin>", src, false, *diff)
+	}
+
+	failed := false
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err == nil {
+			err = formatFile(path, src, *write, *diff)
+		}
+		if err != nil {
+			failed = true
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if failed {
+		return errors.New("some files could not be formatted")
+	}
+	return nil
+}
+
+func formatFile(name string, src []byte, write, diff bool) error {
+	out, err := format.Source(src)
+	var syntax *format.SyntaxError
+	if errors.As(err, &syntax) {
+		for _, d := range syntax.Diagnostics {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+		}
+		return fmt.Errorf("%s: syntax errors", name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	if diff {
+		if !bytes.Equal(src, out) {
+			os.Stdout.Write(unifiedDiff(name, src, out))
+		}
+	}
+	if write {
+		if bytes.Equal(src, out) {
+			return nil
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(name, out, info.Mode().Perm())
+	}
+	if !diff {
+		os.Stdout.Write(out)
+	}
+	return nil
+}
+
+// unifiedDiff returns the changes from a to b in unified format with three
+// lines of context.
+func unifiedDiff(name string, a, b []byte) []byte {
+	x, y := splitLines(a), splitLines(b)
+	ops := diffLines(x, y)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+	const context = 3
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// A hunk runs from context lines before the first change to context
+		// lines after the last change that is not followed by a longer
+		// unchanged stretch.
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(ops))
+
+		ax, ay := ops[start].ax, ops[start].ay
+		var nx, ny int
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				nx++
+			}
+			if op.kind != '-' {
+				ny++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(ax, nx), hunkRange(ay, ny))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if len(op.line) == 0 || op.line[len(op.line)-1] != '\n' {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+// hunkRange formats a range of n lines from index start as diff -u does.
+func hunkRange(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+type diffOp struct {
+	kind   byte // ' ', '-' or '+'
+	line   string
+	ax, ay int // index of the line in a and b, or where it would be
+}
+
+func splitLines(b []byte) []string {
+	var lines []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		lines = append(lines, string(b[:i]))
+		b = b[i:]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script from x to y with Myers'
+// algorithm in linear space. Within a run of changes, removed lines come
+// before added ones.
+func diffLines(x, y []string) []diffOp {
+	d := &differ{
+		x:       x,
+		y:       y,
+		removed: make([]bool, len(x)),
+		added:   make([]bool, len(y)),
+		off:     2*(len(x)+len(y)) + 2,
+	}
+	d.vf = make([]int, 2*d.off+1)
+	d.vb = make([]int, 2*d.off+1)
+	d.compare(0, len(x), 0, len(y))
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && d.removed[i]:
+			ops = append(ops, diffOp{'-', x[i], i, j})
+			i++
+		case j < len(y) && d.added[j]:
+			ops = append(ops, diffOp{'+', y[j], i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{' ', x[i], i, j})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// differ marks the lines of x and y that are not part of a longest common
+// subsequence.
+type differ struct {
+	x, y           []string
+	removed, added []bool
+
+	// vf and vb hold, for each diagonal k = x - y offset by off, the
+	// furthest x reached from the start and from the end.
+	vf, vb []int
+	off    int
+}
+
+func (d *differ) compare(xlo, xhi, ylo, yhi int) {
+	for xlo < xhi && ylo < yhi && d.x[xlo] == d.y[ylo] {
+		xlo++
+		ylo++
+	}
+	for xlo < xhi && ylo < yhi && d.x[xhi-1] == d.y[yhi-1] {
+		xhi--
+		yhi--
+	}
+	switch {
+	case xlo == xhi:
+		for j := ylo; j < yhi; j++ {
+			d.added[j] = true
+		}
+	case ylo == yhi:
+		for i := xlo; i < xhi; i++ {
+			d.removed[i] = true
+		}
+	default:
+		xmid, ymid := d.split(xlo, xhi, ylo, yhi)
+		d.compare(xlo, xmid, ylo, ymid)
+		d.compare(xmid, xhi, ymid, yhi)
+	}
+}
+
+// split returns a point on a shortest edit path from (xlo, ylo) to (xhi,
+// yhi), found where the paths searched from both ends meet. The ranges are
+// non-empty and differ in their first and last lines, so the point is
+// strictly inside and both halves are smaller.
+func (d *differ) split(xlo, xhi, ylo, yhi int) (int, int) {
+	n, m := xhi-xlo, yhi-ylo
+	delta := n - m
+	odd := delta&1 != 0
+	vf, vb, off := d.vf, d.vb, d.off
+	vf[off+1] = 0
+	vb[off+delta-1] = n
+	for D := 0; D <= (n+m+1)/2; D++ {
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || k != D && vf[off+k-1] < vf[off+k+1] {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.x[xlo+x] == d.y[ylo+y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if odd && k >= delta-(D-1) && k <= delta+(D-1) && x >= vb[off+k] {
+				return xlo + x, ylo + y
+			}
+		}
+		for k := -D; k <= D; k += 2 {
+			kk := k + delta
+			var x int
+			if k == D || k != -D && vb[off+kk-1] < vb[off+kk+1] {
+				x = vb[off+kk-1]
+			} else {
+				x = vb[off+kk+1] - 1
+			}
+			y := x - kk
+			for x > 0 && y > 0 && d.x[xlo+x-1] == d.y[ylo+y-1] {
+				x--
+				y--
+			}
+			vb[off+kk] = x
+			if !odd && kk >= -D && kk <= D && x <= vf[off+kk] {
+				return xlo + x, ylo + y
+			}
+		}
+	}
+	panic("diffLines: no middle snake")
+}
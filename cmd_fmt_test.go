@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"empty", "", "", ""},
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"all added", "", "a\nb\n", "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"all removed", "a\nb\n", "", "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"replaced", "a\nb\nc\n", "a\nx\nc\n", "@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"no newline at end", "a\nb", "a\nb\n",
+			"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{"newline removed at end", "a\n", "a",
+			"@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n"},
+		// Changes six lines apart share the context between them.
+		{"merged hunks", "1\n2\nx\n3\n4\n5\n6\n7\n8\ny\n9\n", "1\n2\nX\n3\n4\n5\n6\n7\n8\nY\n9\n",
+			"@@ -1,11 +1,11 @@\n 1\n 2\n-x\n+X\n 3\n 4\n 5\n 6\n 7\n 8\n-y\n+Y\n 9\n"},
+		// Seven lines apart, they do not.
+		{"separate hunks", "x\n1\n2\n3\n4\n5\n6\n7\ny\n", "X\n1\n2\n3\n4\n5\n6\n7\nY\n",
+			"@@ -1,4 +1,4 @@\n-x\n+X\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-y\n+Y\n"},
+	}
+	for _, tt := range tests {
+		got := string(unifiedDiff("f", []byte(tt.a), []byte(tt.b)))
+		got = strings.TrimPrefix(got, "--- f.orig\n+++ f\n")
+		if got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// diffLines finds a shortest edit script: as many lines kept as a longest
+// common subsequence has, and the kept lines equal.
+func TestDiffLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		out := make([]string, r.Intn(12))
+		for i := range out {
+			out[i] = string(rune('a' + r.Intn(3)))
+		}
+		return out
+	}
+	for range 2000 {
+		x, y := lines(), lines()
+		var kept, i, j int
+		for _, op := range diffLines(x, y) {
+			switch op.kind {
+			case ' ':
+				if x[i] != y[j] {
+					t.Fatalf("%q -> %q: kept %q against %q", x, y, x[i], y[j])
+				}
+				kept++
+				i++
+				j++
+			case '-':
+				i++
+			case '+':
+				j++
+			}
+		}
+		if i != len(x) || j != len(y) || kept != lcs(x, y) {
+			t.Fatalf("%q -> %q: kept %d lines, want %d", x, y, kept, lcs(x, y))
+		}
+	}
+}
+
+func lcs(x, y []string) int {
+	table := make([][]int, len(x)+1)
+	for i := range table {
+		table[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+	return table[0][0]
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mcompiler/lexer"
+	"mcompiler/lint"
+	"mcompiler/parser"
+	"mcompiler/resolve"
+	"os"
+	"path/filepath"
+)
+
+func runLint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	configPath := flags.String("config", "", "read the rule settings from `file` instead of the nearest "+lint.ConfigFile)
+	fix := flags.Bool("fix", false, "apply the fixes the rules offer to the files")
+	list := flags.Bool("rules", false, "list the rules and exit")
+	flags.Parse(args)
+
+	if *list {
+		for _, rule := range lint.DefaultRules {
+			fmt.Printf("%-20s %s\n", rule.Name, rule.Doc)
+		}
+		return nil
+	}
+
+	var config *lint.Config
+	if *configPath != "" {
+		var err error
+		if config, err = lint.LoadConfig(*configPath); err != nil {
+			return err
+		}
+	}
+
+	if flags.NArg() == 0 {
+		if *fix {
+			return errors.New("cannot use -fix with standard input")
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		return lintFile("<stdin>", ".", string(src), config, false)
+	}
+
+	failed := false
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err == nil {
+			err = lintFile(path, filepath.Dir(path), string(src), config, *fix)
+		}
+		if err != nil {
+			failed = true
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if failed {
+		return errors.New("lint errors")
+	}
+	return nil
+}
+
+// lintFile lints the source of the file name, with config or else the
+// config found from dir up.
+func lintFile(name, dir, src string, config *lint.Config, fix bool) error {
+	if config == nil {
+		path, err := lint.FindConfig(dir)
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			if config, err = lint.LoadConfig(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	p := parser.NewWithOptions(lexer.New(src), parser.Options{Tolerant: true})
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) > 0 {
+		for _, d := range p.Diagnostics() {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+		}
+		return fmt.Errorf("%s: syntax errors", name)
+	}
+
+	// Names that do not resolve are errors whatever the config says. The
+	// resolver's warnings about shadowing are left to the rules.
+	names := resolve.Program(program, resolve.Options{})
+	for _, d := range names.Diagnostics {
+		if d.Severity == resolve.Error {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+		}
+	}
+	diagnostics, err := lint.Run(program, lint.Options{Config: config, Names: names})
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	for _, d := range diagnostics {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+	}
+	if fix {
+		if out := lint.ApplyFixes(src, diagnostics); out != src {
+			info, err := os.Stat(name)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(name, []byte(out), info.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+	}
+	if names.Errors() {
+		return fmt.Errorf("%s: name errors", name)
+	}
+	if lint.Errors(diagnostics) {
+		return fmt.Errorf("%s: lint errors", name)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"os"
+)
+
+func runParse(args []string) error {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	trace := flags.Bool("trace", false, "log every parse function entered and left to stderr")
+	flags.Parse(args)
+
+	name, src, err := readSource(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	// Tolerant, so a tree with errors can still be printed.
+	opts := parser.Options{Tolerant: true}
+	if *trace {
+		opts.Trace = os.Stderr
+	}
+	p := parser.NewWithOptions(lexer.New(src), opts)
+	program := p.ParseProgram()
+	for _, d := range p.Diagnostics() {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", name, d)
+	}
+	fmt.Println(program)
+	if len(p.Diagnostics()) > 0 {
+		return errors.New("syntax errors")
+	}
+	return nil
+}
+
+// readSource reads the single file named in args, or standard input if
+// there is none or it is "-".
+func readSource(args []string) (string, string, error) {
+	switch {
+	case len(args) > 1:
+		return "", "", errors.New("too many files")
+	case len(args) == 0 || args[0] == "-":
+		src, err := io.ReadAll(os.Stdin)
+		return "<stdin>", string(src), err
+	default:
+		src, err := os.ReadFile(args[0])
+		return args[0], string(src), err
+	}
+}
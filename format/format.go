@@ -0,0 +1,376 @@
+// Package format prints Monkey syntax trees as canonical source: one
+// statement per line, blocks indented by two spaces, and only the
+// parentheses that operator precedence requires. Formatting its own output
+// again changes nothing.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"mcompiler/token"
+	"strconv"
+	"strings"
+)
+
+const indent = "  "
+
+// SyntaxError is returned by Source for input that does not parse; there
+// is no canonical form of a broken program.
+type SyntaxError struct {
+	Diagnostics []parser.Diagnostic
+}
+
+func (e *SyntaxError) Error() string {
+	lines := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Source formats a program, keeping its comments.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	l.KeepComments()
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) > 0 {
+		return nil, &SyntaxError{Diagnostics: p.Diagnostics()}
+	}
+
+	var buf bytes.Buffer
+	if err := Node(&buf, program, l.Comments()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Node writes node to w in canonical form. Comments, as collected by a
+// lexer with KeepComments, are placed by their positions: a comment before
+// a statement goes on its own line above it, and one after a statement on
+// the same line stays there. Comments inside an expression move to the
+// next line where a comment can stand. Nodes built by parser extensions are
+// reported as an error.
+func Node(w io.Writer, node ast.Node, comments []token.Token) error {
+	pr := &printer{comments: comments}
+	pr.node(node)
+	if pr.err != nil {
+		return pr.err
+	}
+	_, err := w.Write(pr.buf.Bytes())
+	return err
+}
+
+type printer struct {
+	buf      bytes.Buffer
+	depth    int
+	comments []token.Token // not yet printed
+	err      error
+}
+
+func (pr *printer) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Program:
+		pr.statements(n.Statements, math.MaxInt)
+		if pr.buf.Len() > 0 {
+			pr.buf.WriteByte('\n')
+		}
+	case ast.Statement:
+		pr.statement(n)
+	case ast.Expression:
+		pr.expression(n, parser.LOWEST)
+	case ast.TypeExpression:
+		pr.typeExpression(n)
+	default:
+		pr.unsupported(n)
+	}
+}
+
+func (pr *printer) unsupported(node ast.Node) {
+	if pr.err == nil {
+		pr.err = fmt.Errorf("format: cannot format %T", node)
+	}
+}
+
+func (pr *printer) newline() {
+	pr.buf.WriteByte('\n')
+	for i := 0; i < pr.depth; i++ {
+		pr.buf.WriteString(indent)
+	}
+}
+
+// statements prints a list of statements on lines of their own, with the
+// comments before end that go with them. A blank line between two
+// statements or comments in the source is kept; several are collapsed into
+// one.
+func (pr *printer) statements(stmts []ast.Statement, end int) {
+	prev := 0 // source line of what was printed last
+	for i, stmt := range stmts {
+		start := stmt.Pos()
+		for pr.commentBefore(start.Offset) {
+			pr.comment(&prev)
+		}
+		pr.line(&prev, start.Line)
+		pr.statement(stmt)
+		prev = stmt.End().Line
+
+		limit := end
+		if i+1 < len(stmts) {
+			limit = stmts[i+1].Pos().Offset
+		}
+		if pr.commentBefore(limit) && pr.comments[0].Pos.Line == prev {
+			pr.buf.WriteByte(' ')
+			pr.buf.WriteString(pr.comments[0].Literal)
+			pr.comments = pr.comments[1:]
+		}
+	}
+	for pr.commentBefore(end) {
+		pr.comment(&prev)
+	}
+}
+
+func (pr *printer) commentBefore(offset int) bool {
+	return len(pr.comments) > 0 && pr.comments[0].Pos.Offset < offset
+}
+
+// comment prints the next comment on a line of its own.
+func (pr *printer) comment(prev *int) {
+	c := pr.comments[0]
+	pr.comments = pr.comments[1:]
+	pr.line(prev, c.Pos.Line)
+	pr.buf.WriteString(c.Literal)
+	*prev = c.Pos.Line
+}
+
+// line starts the line for something from source line n, unless nothing
+// has been printed yet.
+func (pr *printer) line(prev *int, n int) {
+	if *prev == 0 {
+		*prev = n
+		return
+	}
+	if n > *prev+1 {
+		pr.buf.WriteByte('\n')
+	}
+	pr.newline()
+}
+
+func (pr *printer) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		pr.buf.WriteString("let ")
+		pr.identifier(s.Name)
+		pr.annotation(s.Type)
+		pr.buf.WriteString(" = ")
+		pr.expression(s.Value, parser.LOWEST)
+		pr.buf.WriteByte(';')
+	case *ast.ReturnStatement:
+		pr.buf.WriteString("return")
+		if s.Value != nil {
+			pr.buf.WriteByte(' ')
+			pr.expression(s.Value, parser.LOWEST)
+		}
+		pr.buf.WriteByte(';')
+	case *ast.ExpressionStatement:
+		pr.expression(s.Expression, parser.LOWEST)
+		pr.buf.WriteByte(';')
+	case *ast.BlockStatement:
+		pr.block(s)
+	case *ast.IfStatement:
+		pr.buf.WriteString("if (")
+		pr.expression(s.Condition, parser.LOWEST)
+		pr.buf.WriteString(") ")
+		pr.statement(s.Consequence)
+		if s.Alternative != nil {
+			pr.buf.WriteString(" else ")
+			pr.statement(s.Alternative)
+		}
+	default:
+		pr.unsupported(stmt)
+	}
+}
+
+func (pr *printer) block(b *ast.BlockStatement) {
+	end := b.End().Offset
+	if len(b.Statements) == 0 && !pr.commentBefore(end) {
+		pr.buf.WriteString("{}")
+		return
+	}
+	pr.buf.WriteByte('{')
+	pr.depth++
+	pr.newline()
+	pr.statements(b.Statements, end)
+	pr.depth--
+	pr.newline()
+	pr.buf.WriteByte('}')
+}
+
+func (pr *printer) identifier(ident *ast.Identifier) {
+	if ident == nil {
+		pr.unsupported(ident)
+		return
+	}
+	pr.buf.WriteString(ident.Value)
+}
+
+// precedence is how tightly an expression binds: an operand that binds
+// less tightly than its context needs parentheses.
+func precedence(expr ast.Expression) parser.Precedence {
+	switch e := expr.(type) {
+	case *ast.BinaryExpression:
+		if prec, _, ok := parser.Operator(e.Token.Type); ok {
+			return prec
+		}
+		return parser.LOWEST
+	case *ast.AssignExpression:
+		return parser.ASSIGNMENT
+	case *ast.PipeExpression:
+		return parser.PIPELINE
+	case *ast.UnaryExpression:
+		return parser.PREFIX
+	case *ast.FunctionExpression:
+		// An arrow body extends as far as it can, so it has to be
+		// closed off before anything that follows.
+		if _, ok := arrowResult(e); ok {
+			return parser.LOWEST
+		}
+	}
+	return parser.POWER + 1
+}
+
+// expression prints expr, in parentheses if it binds less tightly than
+// min.
+func (pr *printer) expression(expr ast.Expression, min parser.Precedence) {
+	if precedence(expr) < min {
+		pr.buf.WriteByte('(')
+		defer pr.buf.WriteByte(')')
+	}
+
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		pr.buf.WriteString(e.Value)
+	case *ast.IntegerLiteral:
+		if e.Token.Literal != "" {
+			pr.buf.WriteString(e.Token.Literal)
+		} else {
+			pr.buf.WriteString(strconv.FormatInt(e.Value, 10))
+		}
+	case *ast.BooleanLiteral:
+		pr.buf.WriteString(strconv.FormatBool(e.Value))
+	case *ast.UnaryExpression:
+		pr.buf.WriteString(e.Token.Literal)
+		pr.expression(e.Right, parser.PREFIX)
+	case *ast.BinaryExpression:
+		prec, assoc, ok := parser.Operator(e.Token.Type)
+		if !ok {
+			prec, assoc = parser.POWER+1, parser.LeftAssoc
+		}
+		pr.operands(e.Left, e.Token.Literal, e.Right, prec, assoc)
+	case *ast.PipeExpression:
+		pr.operands(e.Left, "|>", e.Right, parser.PIPELINE, parser.LeftAssoc)
+	case *ast.AssignExpression:
+		pr.identifier(e.Name)
+		pr.buf.WriteString(" = ")
+		pr.expression(e.Value, parser.ASSIGNMENT)
+	case *ast.FunctionInvokeExpression:
+		pr.buf.WriteString(e.Token.Literal)
+		pr.buf.WriteByte('(')
+		for i, arg := range e.Arguments {
+			if i > 0 {
+				pr.buf.WriteString(", ")
+			}
+			pr.expression(arg, parser.LOWEST)
+		}
+		pr.buf.WriteByte(')')
+	case *ast.FunctionExpression:
+		pr.buf.WriteString("fn(")
+		for i := range e.Parameters {
+			if i > 0 {
+				pr.buf.WriteString(", ")
+			}
+			pr.buf.WriteString(e.Parameters[i].Value)
+			pr.annotation(e.ParameterType(i))
+		}
+		pr.buf.WriteString(") ")
+		if result, ok := arrowResult(e); ok {
+			// The parser reads an arrow body above pipeline precedence.
+			pr.buf.WriteString("=> ")
+			pr.expression(result, parser.PIPELINE+1)
+			return
+		}
+		pr.statement(e.ParseBody())
+	default:
+		pr.unsupported(expr)
+	}
+}
+
+// operands prints a binary operation. The operand on the side the operator
+// does not associate to needs parentheses even at equal precedence:
+// a - (b - c), (a ** b) ** c.
+func (pr *printer) operands(left ast.Expression, op string, right ast.Expression, prec parser.Precedence, assoc parser.Associativity) {
+	leftMin, rightMin := prec, prec+1
+	if assoc == parser.RightAssoc {
+		leftMin, rightMin = prec+1, prec
+	}
+	pr.expression(left, leftMin)
+	pr.buf.WriteString(" " + op + " ")
+	pr.expression(right, rightMin)
+}
+
+// arrowResult returns the expression of a function written with `=>`.
+func arrowResult(fn *ast.FunctionExpression) (ast.Expression, bool) {
+	if !fn.Arrow {
+		return nil, false
+	}
+	body, ok := fn.ParseBody().(*ast.BlockStatement)
+	if !ok || len(body.Statements) != 1 {
+		return nil, false
+	}
+	ret, ok := body.Statements[0].(*ast.ReturnStatement)
+	if !ok || ret.Value == nil {
+		return nil, false
+	}
+	return ret.Value, true
+}
+
+// annotation prints the `: type` of a let or a parameter, if it has one.
+func (pr *printer) annotation(t ast.TypeExpression) {
+	if t != nil {
+		pr.buf.WriteString(": ")
+		pr.typeExpression(t)
+	}
+}
+
+func (pr *printer) typeExpression(t ast.TypeExpression) {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		pr.buf.WriteString(t.Name)
+		if t.Arguments != nil {
+			pr.buf.WriteByte('(')
+			for i, arg := range t.Arguments {
+				if i > 0 {
+					pr.buf.WriteString(", ")
+				}
+				pr.typeExpression(arg)
+			}
+			pr.buf.WriteByte(')')
+		}
+	case *ast.FunctionType:
+		pr.buf.WriteString("fn(")
+		for i, param := range t.Parameters {
+			if i > 0 {
+				pr.buf.WriteString(", ")
+			}
+			pr.typeExpression(param)
+		}
+		pr.buf.WriteString(") => ")
+		pr.typeExpression(t.Result)
+	default:
+		pr.unsupported(t)
+	}
+}
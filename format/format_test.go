@@ -0,0 +1,131 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(input, ".input")
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Source(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := name + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("formatted %s:\n%s\nwant:\n%s", input, got, want)
+			}
+
+			again, err := Source(got)
+			if err != nil {
+				t.Fatalf("formatted output does not parse: %v", err)
+			}
+			if !bytes.Equal(again, got) {
+				t.Errorf("formatting is not idempotent:\n%s\nthen:\n%s", got, again)
+			}
+			if parse(t, string(got)) != parse(t, string(src)) {
+				t.Errorf("formatting changed the program:\n%s\nwant:\n%s", parse(t, string(got)), parse(t, string(src)))
+			}
+		})
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors parsing %q: %s", input, p.Errors())
+	}
+	return program.String()
+}
+
+// Every pairing of operators, grouped either way, keeps its meaning with
+// the parentheses the formatter leaves.
+func TestParentheses(t *testing.T) {
+	ops := []string{"=", "|>", "==", "<", "+", "-", "*", "/", "**"}
+	operand := func(op, x string) string {
+		if op == "|>" {
+			return x + "(z)"
+		}
+		return x
+	}
+	for _, outer := range ops {
+		for _, inner := range ops {
+			for _, src := range []string{
+				"(a " + inner + " " + operand(inner, "b") + ") " + outer + " " + operand(outer, "c") + ";",
+				"a " + outer + " (b " + inner + " " + operand(inner, "c") + ");",
+				"-(a " + inner + " " + operand(inner, "b") + ");",
+			} {
+				p := parser.New(lexer.New(src))
+				program := p.ParseProgram()
+				if len(p.Errors()) > 0 {
+					continue // such as an assignment to a sum
+				}
+				got, err := Source([]byte(src))
+				if err != nil {
+					t.Errorf("Source(%q): %v", src, err)
+					continue
+				}
+				if parse(t, string(got)) != program.String() {
+					t.Errorf("%q formatted as %q, which means %s", src, got, parse(t, string(got)))
+				}
+			}
+		}
+	}
+}
+
+func TestSource_SyntaxError(t *testing.T) {
+	_, err := Source([]byte("let x = ;"))
+	var syntax *SyntaxError
+	if !errors.As(err, &syntax) || len(syntax.Diagnostics) == 0 {
+		t.Fatalf("Source of a broken program: %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "1:9: ") {
+		t.Errorf("error = %q", err)
+	}
+}
+
+func TestNode(t *testing.T) {
+	var buf bytes.Buffer
+	typ := parser.New(lexer.New("fn(int,fn(bool)=>int)=>bool")).ParseType()
+	if err := Node(&buf, typ, nil); err != nil || buf.String() != "fn(int, fn(bool) => int) => bool" {
+		t.Errorf("Node(type) = %q, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	expr := &ast.UnaryExpression{Right: &ast.MissingExpression{}}
+	if err := Node(&buf, expr, nil); err == nil || !strings.Contains(err.Error(), "*ast.MissingExpression") {
+		t.Errorf("Node of a missing expression: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+// Package intern maps names to small integer symbols, so each distinct name
+// is stored once and names compare as integers.
+package intern
+
+import "strings"
+
+// Symbol identifies an interned name. The zero Symbol is never handed out
+// and stands for "not interned".
+type Symbol uint32
+
+const NoSymbol Symbol = 0
+
+// Table is a symbol table of unique names. It is not safe for concurrent use.
+type Table struct {
+	names []string
+	ids   map[string]Symbol
+}
+
+func NewTable() *Table {
+	return &Table{
+		names: []string{""},
+		ids:   make(map[string]Symbol),
+	}
+}
+
+// Intern returns the symbol of s and the table's copy of it, adding s if it
+// is new. The copy does not share memory with s, so interning a slice of a
+// large source does not keep the source alive.
+func (t *Table) Intern(s string) (Symbol, string) {
+	if sym, ok := t.ids[s]; ok {
+		return sym, t.names[sym]
+	}
+	name := strings.Clone(s)
+	sym := Symbol(len(t.names))
+	t.names = append(t.names, name)
+	t.ids[name] = sym
+	return sym, name
+}
+
+// Lookup returns the symbol of s without adding it.
+func (t *Table) Lookup(s string) (Symbol, bool) {
+	sym, ok := t.ids[s]
+	return sym, ok
+}
+
+// Name returns the name of sym, or "" for NoSymbol.
+func (t *Table) Name(sym Symbol) string {
+	return t.names[sym]
+}
+
+// Len returns the number of interned names.
+func (t *Table) Len() int {
+	return len(t.names) - 1
+}
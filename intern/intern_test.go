@@ -0,0 +1,52 @@
+package intern
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestTable_Intern(t *testing.T) {
+	table := NewTable()
+
+	source := "foo bar foo"
+	fooSym, foo := table.Intern(source[0:3])
+	barSym, _ := table.Intern(source[4:7])
+	againSym, again := table.Intern(source[8:11])
+
+	if fooSym == NoSymbol || barSym == NoSymbol {
+		t.Fatalf("Intern returned NoSymbol")
+	}
+	if fooSym != againSym {
+		t.Errorf("same name got different symbols. %d != %d", fooSym, againSym)
+	}
+	if fooSym == barSym {
+		t.Errorf("different names got the same symbol %d", fooSym)
+	}
+	if unsafe.StringData(foo) != unsafe.StringData(again) {
+		t.Errorf("same name returned different copies")
+	}
+	if unsafe.StringData(foo) == unsafe.StringData(source) {
+		t.Errorf("interned name shares memory with the source")
+	}
+	if table.Name(fooSym) != "foo" || table.Name(barSym) != "bar" {
+		t.Errorf("Name wrong. got=%q, %q", table.Name(fooSym), table.Name(barSym))
+	}
+	if table.Len() != 2 {
+		t.Errorf("Len wrong. expected=2, got=%d", table.Len())
+	}
+}
+
+func TestTable_Lookup(t *testing.T) {
+	table := NewTable()
+	sym, _ := table.Intern("x")
+
+	if got, ok := table.Lookup("x"); !ok || got != sym {
+		t.Errorf("Lookup(x) = %d, %t. expected=%d, true", got, ok, sym)
+	}
+	if _, ok := table.Lookup("y"); ok {
+		t.Errorf("Lookup(y) found a symbol that was never interned")
+	}
+	if table.Len() != 1 {
+		t.Errorf("Lookup added a name")
+	}
+}
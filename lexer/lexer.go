@@ -1,7 +1,9 @@
 package lexer
 
 import (
+	"mcompiler/intern"
 	"mcompiler/token"
+	"strings"
 )
 
 type Lexer struct {
@@ -9,15 +11,99 @@ type Lexer struct {
 	position     int  //current pos
 	readPosition int  //next pos
 	ch           byte //char at current pos
+	line         int  //line of current pos
+	lineStart    int  //offset of the first byte of line
+
+	keywords  map[string]token.TokenType
+	operators []operator
+	symbols   *intern.Table
+
+	keepComments bool
+	comments     []token.Token
+}
+
+type operator struct {
+	symbol    string
+	tokenType token.TokenType
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// AddKeyword makes word lex as t instead of an identifier.
+func (l *Lexer) AddKeyword(word string, t token.TokenType) {
+	if l.keywords == nil {
+		l.keywords = make(map[string]token.TokenType)
+	}
+	l.keywords[word] = t
+}
+
+// AddOperator makes symbol lex as t. Added operators are matched before the
+// built-in ones, longest first, so an added symbol that is a prefix of a
+// built-in operator shadows it.
+func (l *Lexer) AddOperator(symbol string, t token.TokenType) {
+	i := 0
+	for i < len(l.operators) && len(l.operators[i].symbol) >= len(symbol) {
+		i++
+	}
+	l.operators = append(l.operators, operator{})
+	copy(l.operators[i+1:], l.operators[i:])
+	l.operators[i] = operator{symbol: symbol, tokenType: t}
+}
+
+// UseInterner makes identifier and integer literals come from t: every
+// occurrence of a name shares one string and carries its symbol.
+func (l *Lexer) UseInterner(t *intern.Table) {
+	l.symbols = t
+}
+
+// KeepComments makes the lexer record the comments it skips, so tools such
+// as the formatter can put them back. It must be called before the first
+// token is read.
+func (l *Lexer) KeepComments() {
+	l.keepComments = true
+}
+
+// Comments returns the comments skipped so far, in order, as COMMENT tokens
+// whose literal runs from the `//` to the end of the line. It is empty
+// unless KeepComments was called.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// Input returns the source being lexed.
+func (l *Lexer) Input() string {
+	return l.input
+}
+
+// Seek continues lexing at pos, which must be the position of a byte of the
+// input or of its end. Comments recorded from pos on are dropped, since they
+// are read again.
+func (l *Lexer) Seek(pos token.Pos) {
+	for n := len(l.comments); n > 0 && l.comments[n-1].Pos.Offset >= pos.Offset; n-- {
+		l.comments = l.comments[:n-1]
+	}
+	l.ch = 0
+	l.readPosition = pos.Offset
+	l.line = pos.Line
+	l.lineStart = pos.Offset - (pos.Column - 1)
+	l.readChar()
+}
+
+func (l *Lexer) intern(tok *token.Token) {
+	if l.symbols != nil {
+		tok.Sym, tok.Literal = l.symbols.Intern(tok.Literal)
+	}
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -50,48 +136,89 @@ func (l *Lexer) readNumber() string {
 	return l.input[currentPos:l.position]
 }
 
+func (l *Lexer) pos() token.Pos {
+	return token.Pos{Offset: l.position, Line: l.line, Column: l.position - l.lineStart + 1}
+}
+
+func (l *Lexer) readOperator() (token.Token, bool) {
+	rest := l.input[l.position:]
+	for _, op := range l.operators {
+		if strings.HasPrefix(rest, op.symbol) {
+			for i := 0; i < len(op.symbol); i++ {
+				l.readChar()
+			}
+			return token.Token{Type: op.tokenType, Literal: op.symbol}, true
+		}
+	}
+	return token.Token{}, false
+}
+
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhitespace()
+	pos := l.pos()
+	if len(l.operators) > 0 && l.position < len(l.input) {
+		if tok, ok := l.readOperator(); ok {
+			tok.Pos = pos
+			return tok
+		}
+	}
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			tok = token.Token{Type: token.EQUAL, Literal: "=="}
 			l.readChar()
+		} else if l.peekChar() == '>' {
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
+			l.readChar()
 		} else {
-			tok = newToken(token.ASSIGN, l.ch)
+			tok = l.newToken(token.ASSIGN)
 		}
 	case ',':
-		tok = newToken(token.COMMA, l.ch)
+		tok = l.newToken(token.COMMA)
 	case ';':
-		tok = newToken(token.SEMICOLON, l.ch)
+		tok = l.newToken(token.SEMICOLON)
+	case ':':
+		tok = l.newToken(token.COLON)
 	case '(':
-		tok = newToken(token.LPAREN, l.ch)
+		tok = l.newToken(token.LPAREN)
 	case ')':
-		tok = newToken(token.RPAREN, l.ch)
+		tok = l.newToken(token.RPAREN)
 	case '{':
-		tok = newToken(token.LBRACE, l.ch)
+		tok = l.newToken(token.LBRACE)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		tok = l.newToken(token.RBRACE)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newToken(token.PLUS)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newToken(token.MINUS)
 	case '!':
 		if l.peekChar() == '=' {
 			tok = token.Token{Type: token.NOTEQUAL, Literal: "!="}
 			l.readChar()
 		} else {
-			tok = newToken(token.BANG, l.ch)
+			tok = l.newToken(token.BANG)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok = token.Token{Type: token.POWER, Literal: "**"}
+			l.readChar()
+		} else {
+			tok = l.newToken(token.ASTERISK)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.newToken(token.SLASH)
+	case '|':
+		if l.peekChar() == '>' {
+			tok = token.Token{Type: token.PIPE, Literal: "|>"}
+			l.readChar()
+		} else {
+			tok = l.newToken(token.ILLEGAL)
+		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		tok = l.newToken(token.LT)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		tok = l.newToken(token.GT)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -99,16 +226,23 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = l.lookupIdent(tok.Literal)
+			if tok.Type == token.IDENT {
+				l.intern(&tok)
+			}
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.INT
+			l.intern(&tok)
+			tok.Pos = pos
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = l.newToken(token.ILLEGAL)
 		}
 	}
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
@@ -127,25 +261,50 @@ var keywords = map[string]token.TokenType{
 }
 
 func (l *Lexer) lookupIdent(literal string) token.TokenType {
+	if tok, ok := l.keywords[literal]; ok {
+		return tok
+	}
 	if tok, ok := keywords[literal]; ok {
 		return tok
 	}
 	return token.IDENT
 }
 
+// skipWhitespace skips whitespace and `//` comments, which run to the end
+// of the line.
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) skipComment() {
+	pos := l.pos()
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	if l.keepComments {
+		literal := strings.TrimRight(l.input[pos.Offset:l.position], " \t\r")
+		l.comments = append(l.comments, token.Token{Type: token.COMMENT, Literal: literal, Pos: pos})
+	}
 }
 
 func isLetter(b byte) bool {
 	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || b == '_'
 }
 
-func newToken(t token.TokenType, ch byte) token.Token {
+// newToken makes a token of the current byte. The literal slices the input
+// rather than converting the byte, which would allocate.
+func (l *Lexer) newToken(t token.TokenType) token.Token {
 	return token.Token{
 		Type:    t,
-		Literal: string(ch),
+		Literal: l.input[l.position : l.position+1],
 	}
 }
@@ -1,7 +1,9 @@
 package lexer
 
 import (
+	"mcompiler/intern"
 	"mcompiler/token"
+	"strings"
 	"testing"
 )
 
@@ -20,7 +22,10 @@ return true;
 return false;
 }
 10 == 10;
-10 != 9;`
+10 != 9;
+xs |> map(fn(x) => x);
+2 ** 3;
+let n: int = 1;`
 
 	tests := []struct {
 		expectedType    token.TokenType
@@ -99,6 +104,29 @@ return false;
 		{token.NOTEQUAL, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.IDENT, "xs"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "map"},
+		{token.LPAREN, "("},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "n"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -128,3 +156,138 @@ func TestReadString(t *testing.T) {
 		t.Fatalf("readString failed. expected=%q, got=%q", expected, got)
 	}
 }
+
+func TestNextTokenPosition(t *testing.T) {
+	input := "let x = 5;\n  x +\n\ty;"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Pos
+	}{
+		{"let", token.Pos{Offset: 0, Line: 1, Column: 1}},
+		{"x", token.Pos{Offset: 4, Line: 1, Column: 5}},
+		{"=", token.Pos{Offset: 6, Line: 1, Column: 7}},
+		{"5", token.Pos{Offset: 8, Line: 1, Column: 9}},
+		{";", token.Pos{Offset: 9, Line: 1, Column: 10}},
+		{"x", token.Pos{Offset: 13, Line: 2, Column: 3}},
+		{"+", token.Pos{Offset: 15, Line: 2, Column: 5}},
+		{"y", token.Pos{Offset: 18, Line: 3, Column: 2}},
+		{";", token.Pos{Offset: 19, Line: 3, Column: 3}},
+		{"", token.Pos{Offset: 20, Line: 3, Column: 4}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - pos wrong. expected=%+v, got=%+v",
+				i, tt.expectedPos, tok.Pos)
+		}
+	}
+}
+
+func TestAddKeywordAndOperator(t *testing.T) {
+	input := "unless a % b <> c |> d"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"UNLESS", "unless"},
+		{token.IDENT, "a"},
+		{"MOD", "%"},
+		{token.IDENT, "b"},
+		{"NE", "<>"},
+		{token.IDENT, "c"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "d"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	l.AddKeyword("unless", "UNLESS")
+	l.AddOperator("%", "MOD")
+	l.AddOperator("<>", "NE")
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUseInterner(t *testing.T) {
+	table := intern.NewTable()
+	l := New("let x = y + x + 10 + 10;")
+	l.UseInterner(table)
+
+	symbols := map[string]intern.Symbol{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.IDENT, token.INT:
+			if tok.Sym == intern.NoSymbol {
+				t.Fatalf("%s %q was not interned", tok.Type, tok.Literal)
+			}
+			if sym, ok := symbols[tok.Literal]; ok && sym != tok.Sym {
+				t.Errorf("%q got symbols %d and %d", tok.Literal, sym, tok.Sym)
+			}
+			symbols[tok.Literal] = tok.Sym
+			if table.Name(tok.Sym) != tok.Literal {
+				t.Errorf("symbol %d names %q, token has %q", tok.Sym, table.Name(tok.Sym), tok.Literal)
+			}
+		default:
+			if tok.Sym != intern.NoSymbol {
+				t.Errorf("%s %q was interned", tok.Type, tok.Literal)
+			}
+		}
+	}
+	if table.Len() != 3 {
+		t.Errorf("expected 3 interned names, got=%d", table.Len())
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// leading\nlet x = 6 // six  \n  / 2; //\n// last"
+
+	l := New(input)
+	l.KeepComments()
+	var literals []string
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		literals = append(literals, tok.Literal)
+	}
+	if got := strings.Join(literals, " "); got != "let x = 6 / 2 ;" {
+		t.Errorf("tokens = %q, want %q", got, "let x = 6 / 2 ;")
+	}
+
+	expected := []token.Token{
+		{Type: token.COMMENT, Literal: "// leading", Pos: token.Pos{Offset: 0, Line: 1, Column: 1}},
+		{Type: token.COMMENT, Literal: "// six", Pos: token.Pos{Offset: 21, Line: 2, Column: 11}},
+		{Type: token.COMMENT, Literal: "//", Pos: token.Pos{Offset: 37, Line: 3, Column: 8}},
+		{Type: token.COMMENT, Literal: "// last", Pos: token.Pos{Offset: 40, Line: 4, Column: 1}},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("comments = %+v, want %+v", comments, expected)
+	}
+	for i := range expected {
+		if comments[i] != expected[i] {
+			t.Errorf("comments[%d] = %+v, want %+v", i, comments[i], expected[i])
+		}
+	}
+
+	l = New(input)
+	for l.NextToken().Type != token.EOF {
+	}
+	if len(l.Comments()) != 0 {
+		t.Errorf("comments recorded without KeepComments: %v", l.Comments())
+	}
+}
@@ -0,0 +1,292 @@
+// Package lint runs rules over a program that look for code which is legal
+// but likely wrong, such as a local that is never used or a statement after
+// a return.
+//
+// A rule is a named function given the program and its resolved names
+// through a Pass. DefaultRules are the rules that come with the package;
+// callers can run their own alongside them through Options.Rules. Which
+// rules run, and whether they report warnings or errors, is set by a Config,
+// usually read from a ConfigFile at the root of the project.
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mcompiler/ast"
+	"mcompiler/resolve"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Severity uint8
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", s)
+}
+
+// Rule is a check run over a whole program. Run reports what it finds
+// through the pass it is given.
+type Rule struct {
+	Name     string // such as "unused-let"; used in configs and diagnostics
+	Doc      string // one line on what the rule reports
+	Severity Severity
+	Run      func(p *Pass)
+}
+
+// Edit replaces the source from byte offset Start up to End with New.
+type Edit struct {
+	Start, End int
+	New        string
+}
+
+// Fix is a change to the source that makes a diagnostic go away.
+type Fix struct {
+	Msg   string
+	Edits []Edit
+}
+
+type Diagnostic struct {
+	Rule     string
+	Severity Severity
+	Span     ast.Span
+	Msg      string
+	Fix      *Fix // nil if the rule has none to offer
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", d.Span.Start, d.Severity, d.Msg, d.Rule)
+}
+
+// Pass is what a rule is given to look at and report to.
+type Pass struct {
+	Rule    *Rule
+	Program *ast.Program
+	Names   *resolve.Info
+
+	severity    Severity
+	diagnostics *[]Diagnostic
+}
+
+// Source returns the source the program was parsed from, which fixes
+// refer to by offset. It is empty for a tree that was not parsed.
+func (p *Pass) Source() string { return p.Program.Source }
+
+// Reportf reports a diagnostic spanning node.
+func (p *Pass) Reportf(node ast.Node, format string, args ...any) {
+	p.ReportFix(node, nil, format, args...)
+}
+
+// ReportFix reports a diagnostic spanning node with a fix for it. The fix
+// is dropped if the program has no source for it to apply to.
+func (p *Pass) ReportFix(node ast.Node, fix *Fix, format string, args ...any) {
+	if p.Source() == "" {
+		fix = nil
+	}
+	*p.diagnostics = append(*p.diagnostics, Diagnostic{
+		Rule:     p.Rule.Name,
+		Severity: p.severity,
+		Span:     ast.Span{Start: node.Pos(), End: node.End()},
+		Msg:      fmt.Sprintf(format, args...),
+		Fix:      fix,
+	})
+}
+
+type Options struct {
+	// Rules are the rules to run. Nil means DefaultRules.
+	Rules []*Rule
+
+	// Config turns rules off and sets their severities. Nil runs every
+	// rule at its own severity.
+	Config *Config
+
+	// Names are the resolved names of program, as from resolve.Program.
+	// Nil resolves them with Builtins.
+	Names *resolve.Info
+
+	// Builtins are passed on to resolve.Program.
+	Builtins []string
+}
+
+// Run resolves the names in program unless given, and runs the rules over
+// it. Errors in resolving are not reported again; see resolve.Info. The
+// diagnostics are sorted by position, then by rule. Run fails only if the
+// config names a rule that is not among those given.
+func Run(program *ast.Program, opts Options) ([]Diagnostic, error) {
+	rules := opts.Rules
+	if rules == nil {
+		rules = DefaultRules
+	}
+	var settings map[string]string
+	if opts.Config != nil {
+		settings = opts.Config.Rules
+		for name := range settings {
+			if !hasRule(rules, name) {
+				return nil, fmt.Errorf("lint: config: unknown rule %q", name)
+			}
+		}
+	}
+
+	names := opts.Names
+	if names == nil {
+		names = resolve.Program(program, resolve.Options{Builtins: opts.Builtins})
+	}
+	var diagnostics []Diagnostic
+	for _, rule := range rules {
+		severity := rule.Severity
+		switch settings[rule.Name] {
+		case "off":
+			continue
+		case "warning":
+			severity = Warning
+		case "error":
+			severity = Error
+		}
+		rule.Run(&Pass{Rule: rule, Program: program, Names: names, severity: severity, diagnostics: &diagnostics})
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Span.Start.Offset != b.Span.Start.Offset {
+			return a.Span.Start.Offset < b.Span.Start.Offset
+		}
+		return a.Rule < b.Rule
+	})
+	return diagnostics, nil
+}
+
+func hasRule(rules []*Rule, name string) bool {
+	for _, rule := range rules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors reports whether any diagnostic is an error.
+func Errors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyFixes returns src with the fixes of diagnostics applied. A fix that
+// overlaps one applied before it, in source order, is left out.
+func ApplyFixes(src string, diagnostics []Diagnostic) string {
+	var fixes [][]Edit
+	for _, d := range diagnostics {
+		if d.Fix == nil || len(d.Fix.Edits) == 0 {
+			continue
+		}
+		fix := append([]Edit(nil), d.Fix.Edits...)
+		sort.Slice(fix, func(i, j int) bool { return fix[i].Start < fix[j].Start })
+		fixes = append(fixes, fix)
+	}
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i][0].Start < fixes[j][0].Start })
+
+	var edits []Edit
+	end := 0
+	for _, fix := range fixes {
+		if fix[0].Start < end {
+			continue
+		}
+		for _, e := range fix {
+			end = max(end, e.End)
+		}
+		edits = append(edits, fix...)
+	}
+
+	var b strings.Builder
+	at := 0
+	for _, e := range edits {
+		b.WriteString(src[at:e.Start])
+		b.WriteString(e.New)
+		at = e.End
+	}
+	b.WriteString(src[at:])
+	return b.String()
+}
+
+// ConfigFile is the name of the file FindConfig looks for.
+const ConfigFile = ".mlint.json"
+
+// Config is read from JSON such as
+//
+//	{"rules": {"unused-let": "off", "self-compare": "error"}}
+//
+// Rules maps rule names to "off", "warning" or "error". A rule not named
+// runs at its own severity.
+type Config struct {
+	Rules map[string]string `json:"rules"`
+}
+
+// ParseConfig parses and checks a config. Rule names are checked by Run,
+// against the rules it is given.
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("lint: config: %v", err)
+	}
+	for name, setting := range config.Rules {
+		switch setting {
+		case "off", "warning", "error":
+		default:
+			return nil, fmt.Errorf("lint: config: rule %q: want \"off\", \"warning\" or \"error\", not %q", name, setting)
+		}
+	}
+	return &config, nil
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// FindConfig returns the path of the ConfigFile in dir or the nearest of
+// its parents, or "" if there is none.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, ConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,154 @@
+package lint
+
+import (
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+	return program
+}
+
+func run(t *testing.T, input string, opts Options) []Diagnostic {
+	t.Helper()
+	diagnostics, err := Run(parse(t, input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return diagnostics
+}
+
+func strs(diagnostics []Diagnostic) []string {
+	var out []string
+	for _, d := range diagnostics {
+		out = append(out, d.String())
+	}
+	return out
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule  *Rule
+		input string
+		want  []string
+	}{
+		{UnusedLet, "let g = 1; let f = fn() { let a = 1; let _b = 2; let c = 3; c };", []string{
+			"1:31: warning: a declared and not used (unused-let)",
+		}},
+		{UnusedLet, "let f = fn() { let a = 1; a = 2; };", []string{
+			"1:20: warning: a declared and not used (unused-let)",
+		}},
+		{Unreachable, "let f = fn() { return 1; puts(2); 3 };", []string{
+			"1:26: warning: unreachable code (unreachable)",
+		}},
+		{Unreachable, "let f = fn() { if (true) { return 1; } 2 };", nil},
+		{ShadowedParam, "let f = fn(x) { if (x) { let x = 2; } fn(x) { x } };", []string{
+			"1:30: warning: x shadows the parameter declared at 1:12 (shadowed-param)",
+			"1:42: warning: x shadows the parameter declared at 1:12 (shadowed-param)",
+		}},
+		{ShadowedParam, "let f = fn(a) { let a = 2; a };", []string{
+			"1:21: warning: a shadows the parameter declared at 1:12 (shadowed-param)",
+		}},
+		{ShadowedParam, "let x = 1; let f = fn() { let x = 2; x };", nil},
+		{SelfCompare, "let x = 1; x == x; x != x; x < (x); x == 1; f() == f();", []string{
+			"1:12: warning: (x == x) is always true (self-compare)",
+			"1:20: warning: (x != x) is always false (self-compare)",
+			"1:28: warning: (x < x) is always false (self-compare)",
+		}},
+		{EmptyBlock, "let f = fn() {}; if (f()) {} else {}", []string{
+			"1:27: warning: empty block (empty-block)",
+			"1:35: warning: empty block (empty-block)",
+		}},
+		{ConstantCondition, "let d = false; if (d) { 1 } if (1 < 2) { 2 } if (!true) { 3 } if (1 / 0 > 1) { 4 }", []string{
+			"1:33: warning: condition is always true (constant-condition)",
+			"1:50: warning: condition is always false (constant-condition)",
+		}},
+	}
+	for _, tt := range tests {
+		got := strs(run(t, tt.input, Options{Rules: []*Rule{tt.rule}}))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %q:\ngot  %q\nwant %q", tt.rule.Name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestApplyFixes(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"let f = fn() {\n\tlet a = 1;\n\t2\n};\n", "let f = fn() {\n\t2\n};\n"},
+		{"let f = fn() {\n\tlet a = g();\n\t2\n};\n", "let f = fn() {\n\tlet a = g();\n\t2\n};\n"},
+		{"let f = fn() {\n\treturn 1;\n\tputs(1);\n\t2\n};\n", "let f = fn() {\n\treturn 1;\n};\n"},
+		{"let f = fn() { return 1; 2 };", "let f = fn() { return 1; };"},
+		{"let x = 1;\nif (x == x) { x } else {}\n", "let x = 1;\nif (true) { x }\n"},
+	}
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		diagnostics, err := Run(program, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ApplyFixes(program.Source, diagnostics); got != tt.want {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"rules": {"unused-let": "off", "self-compare": "error"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "let f = fn(x) { let a = 1; x == x };"
+	got := strs(run(t, input, Options{Config: config}))
+	want := []string{"1:28: error: (x == x) is always true (self-compare)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !Errors(run(t, input, Options{Config: config})) {
+		t.Errorf("Errors = false, want true")
+	}
+
+	for _, bad := range []string{
+		`{"rules": {"unused-let": "loud"}}`,
+		`{"rule": {}}`,
+		`{"rules": `,
+	} {
+		if _, err := ParseConfig([]byte(bad)); err == nil {
+			t.Errorf("ParseConfig(%s): no error", bad)
+		}
+	}
+	config, _ = ParseConfig([]byte(`{"rules": {"no-such-rule": "off"}}`))
+	if _, err := Run(parse(t, "1;"), Options{Config: config}); err == nil {
+		t.Errorf("Run with an unknown rule: no error")
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := FindConfig(dir); err != nil || path != "" {
+		t.Fatalf("FindConfig = %q, %v; want none", path, err)
+	}
+	want := filepath.Join(root, "a", ConfigFile)
+	if err := os.WriteFile(want, []byte(`{"rules": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := FindConfig(dir); err != nil || path != want {
+		t.Errorf("FindConfig = %q, %v; want %q", path, err, want)
+	}
+}
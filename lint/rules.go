@@ -0,0 +1,275 @@
+package lint
+
+import (
+	"mcompiler/ast"
+	"mcompiler/optimize"
+	"mcompiler/resolve"
+	"mcompiler/token"
+	"strings"
+)
+
+// DefaultRules are the rules Run uses when Options.Rules is nil.
+var DefaultRules = []*Rule{
+	UnusedLet,
+	Unreachable,
+	ShadowedParam,
+	SelfCompare,
+	EmptyBlock,
+	ConstantCondition,
+}
+
+var UnusedLet = &Rule{
+	Name: "unused-let",
+	Doc:  "reports locals that are never read; globals may be used by other files",
+	Run:  unusedLet,
+}
+
+var Unreachable = &Rule{
+	Name: "unreachable",
+	Doc:  "reports statements after a return in the same block",
+	Run:  unreachable,
+}
+
+var ShadowedParam = &Rule{
+	Name: "shadowed-param",
+	Doc:  "reports declarations that hide a parameter of an enclosing function",
+	Run:  shadowedParam,
+}
+
+var SelfCompare = &Rule{
+	Name: "self-compare",
+	Doc:  "reports comparisons of an expression with itself, such as x == x",
+	Run:  selfCompare,
+}
+
+var EmptyBlock = &Rule{
+	Name: "empty-block",
+	Doc:  "reports empty blocks other than function bodies",
+	Run:  emptyBlock,
+}
+
+var ConstantCondition = &Rule{
+	Name: "constant-condition",
+	Doc:  "reports if statements whose condition is always true or always false",
+	Run:  constantCondition,
+}
+
+func unusedLet(p *Pass) {
+	lets := make(map[*ast.Identifier]*ast.LetStatement)
+	ast.InspectType(p.Program, func(let *ast.LetStatement) bool {
+		lets[let.Name] = let
+		return true
+	})
+	assigned := make(map[*resolve.Symbol]bool)
+	ast.InspectType(p.Program, func(assign *ast.AssignExpression) bool {
+		if b, ok := p.Names.Bindings[assign.Name]; ok {
+			assigned[b.Symbol] = true
+		}
+		return true
+	})
+
+	var visit func(s *resolve.Scope)
+	visit = func(s *resolve.Scope) {
+		for _, sym := range s.Symbols {
+			if sym.Kind != resolve.Local || sym.Uses > 0 || strings.HasPrefix(sym.Name, "_") {
+				continue
+			}
+			let := lets[sym.Decl]
+			if let == nil {
+				continue
+			}
+			// Removing the let is only safe if nothing assigns to the
+			// name and its value has no effects.
+			var fix *Fix
+			if !assigned[sym] && effectFree(let.Value) {
+				fix = &Fix{Msg: "remove the declaration", Edits: []Edit{deleteStatement(p.Source(), let)}}
+			}
+			p.ReportFix(sym.Decl, fix, "%s declared and not used", sym.Name)
+		}
+		for _, child := range s.Children {
+			visit(child)
+		}
+	}
+	visit(p.Names.Global)
+}
+
+func unreachable(p *Pass) {
+	check := func(list []ast.Statement) {
+		for i, stmt := range list[:max(len(list)-1, 0)] {
+			if _, ok := stmt.(*ast.ReturnStatement); !ok {
+				continue
+			}
+			first, last := list[i+1], list[len(list)-1]
+			edit := deleteSpan(p.Source(), first.Pos().Offset, last.End().Offset)
+			p.ReportFix(first, &Fix{Msg: "remove the unreachable code", Edits: []Edit{edit}}, "unreachable code")
+			return
+		}
+	}
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Program:
+			check(n.Statements)
+		case *ast.BlockStatement:
+			check(n.Statements)
+		}
+		return true
+	})
+}
+
+func shadowedParam(p *Pass) {
+	var visit func(s *resolve.Scope)
+	visit = func(s *resolve.Scope) {
+		// A function body shares the scope of the parameters, so a let
+		// there hides a parameter declared before it in the same scope.
+		var params map[string]*resolve.Symbol
+		for _, sym := range s.Symbols {
+			if sym.Decl == nil || s.Parent == nil {
+				continue
+			}
+			var param *resolve.Symbol
+			if sym.Kind == resolve.Parameter {
+				if params == nil {
+					params = make(map[string]*resolve.Symbol)
+				}
+				params[sym.Name] = sym
+			} else {
+				param = params[sym.Name]
+			}
+			if outer := s.Parent.Lookup(sym.Name); param == nil && outer != nil && outer.Kind == resolve.Parameter {
+				param = outer
+			}
+			if param != nil {
+				p.Reportf(sym.Decl, "%s shadows the parameter declared at %s", sym.Name, param.Decl.Pos())
+			}
+		}
+		for _, child := range s.Children {
+			visit(child)
+		}
+	}
+	visit(p.Names.Global)
+}
+
+func selfCompare(p *Pass) {
+	ast.InspectType(p.Program, func(be *ast.BinaryExpression) bool {
+		var result bool
+		switch be.Token.Type {
+		case token.EQUAL:
+			result = true
+		case token.NOTEQUAL, token.LT, token.GT:
+			result = false
+		default:
+			return true
+		}
+		if be.Left == nil || be.Right == nil || !effectFree(be.Left) || !ast.Equal(be.Left, be.Right) {
+			return true
+		}
+		lit := "false"
+		if result {
+			lit = "true"
+		}
+		fix := &Fix{Msg: "replace with " + lit, Edits: []Edit{{Start: be.Pos().Offset, End: be.End().Offset, New: lit}}}
+		p.ReportFix(be, fix, "%s is always %s", be, lit)
+		return true
+	})
+}
+
+func emptyBlock(p *Pass) {
+	bodies := make(map[ast.Node]bool)
+	elses := make(map[ast.Node]*ast.IfStatement)
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionExpression:
+			bodies[n.ParseBody()] = true
+		case *ast.IfStatement:
+			if n.Alternative != nil {
+				elses[n.Alternative] = n
+			}
+		}
+		return true
+	})
+	ast.InspectType(p.Program, func(block *ast.BlockStatement) bool {
+		if len(block.Statements) > 0 || bodies[block] {
+			return true
+		}
+		var fix *Fix
+		if ifStmt := elses[block]; ifStmt != nil && ifStmt.Consequence != nil {
+			fix = &Fix{Msg: "remove the else", Edits: []Edit{{Start: ifStmt.Consequence.End().Offset, End: block.End().Offset}}}
+		}
+		p.ReportFix(block, fix, "empty block")
+		return true
+	})
+}
+
+func constantCondition(p *Pass) {
+	ast.InspectType(p.Program, func(is *ast.IfStatement) bool {
+		if is.Condition == nil || !literalsOnly(is.Condition) {
+			return true
+		}
+		// Fold a copy of the condition on its own, so that constants bound
+		// by let, such as a debug flag, do not count.
+		stmt := &ast.ExpressionStatement{Expression: ast.Clone(is.Condition, nil)}
+		optimize.Program(&ast.Program{Statements: []ast.Statement{stmt}}, optimize.Options{})
+		if cond, ok := stmt.Expression.(*ast.BooleanLiteral); ok {
+			p.Reportf(is.Condition, "condition is always %t", cond.Value)
+		}
+		return true
+	})
+}
+
+// literalsOnly reports whether e is built of literals and operators alone.
+func literalsOnly(e ast.Expression) bool {
+	ok := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil, *ast.IntegerLiteral, *ast.BooleanLiteral, *ast.UnaryExpression, *ast.BinaryExpression:
+		default:
+			ok = false
+		}
+		return ok
+	})
+	return ok
+}
+
+// effectFree reports whether evaluating e does nothing but compute a value:
+// e calls nothing and assigns nothing.
+func effectFree(e ast.Expression) bool {
+	if e == nil {
+		return true
+	}
+	ok := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FunctionInvokeExpression, *ast.PipeExpression, *ast.AssignExpression:
+			ok = false
+		case *ast.FunctionExpression:
+			// Defining a function runs none of it.
+			return false
+		}
+		return ok
+	})
+	return ok
+}
+
+func deleteStatement(src string, stmt ast.Statement) Edit {
+	return deleteSpan(src, stmt.Pos().Offset, stmt.End().Offset)
+}
+
+// deleteSpan returns an edit removing src[start:end] with the semicolon
+// and blanks after it, and the whole line if nothing else is on it.
+func deleteSpan(src string, start, end int) Edit {
+	if end < len(src) && src[end] == ';' {
+		end++
+	}
+	lineStart := strings.LastIndexByte(src[:start], '\n') + 1
+	lineEnd := len(src)
+	if i := strings.IndexByte(src[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	if strings.TrimSpace(src[lineStart:start]) == "" && strings.TrimSpace(src[end:lineEnd]) == "" {
+		return Edit{Start: lineStart, End: lineEnd}
+	}
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	return Edit{Start: start, End: end}
+}
@@ -7,12 +7,56 @@ import (
 	"os/user"
 )
 
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"ast", "ast [-format=tree|sexpr|dot] [file]  print the syntax tree of a program", runAST},
+	{"check", "check [-types] [file]                type-check a program and report type errors", runCheck},
+	{"fmt", "fmt [-w] [-d] [files]                format programs in canonical style", runFmt},
+	{"lint", "lint [-config=file] [-fix] [files]   report likely mistakes, configured by .mlint.json", runLint},
+	{"parse", "parse [-trace] [file]                parse a program and print it back", runParse},
+	{"repl", "repl                                 start the interactive prompt (the default)", runRepl},
+}
+
 func main() {
+	if len(os.Args) < 2 {
+		exit(runRepl(nil))
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			exit(cmd.run(os.Args[2:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "mcompiler: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mcompiler <command> [arguments]")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\tmcompiler %s\n", cmd.usage)
+	}
+}
+
+func exit(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mcompiler: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func runRepl(args []string) error {
 	user, err := user.Current()
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 	fmt.Printf("Hello %s\n", user.Name)
 	repl.Start(os.Stdin, os.Stdout)
+	return nil
 }
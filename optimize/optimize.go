@@ -0,0 +1,337 @@
+// Package optimize simplifies a program before it runs. It folds integer
+// and boolean constants, drops arithmetic identities such as `x * 1` and
+// `x + 0`, prunes the dead branch of an if whose condition is constant and
+// puts the value of a let bound to a constant in place of its uses.
+//
+// Integers are int64 and wrap around on overflow, at compile time as at run
+// time. Identities are applied only where the operand is known to be an
+// int: a literal, or an expression types.Check found to be one.
+package optimize
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/resolve"
+	"mcompiler/token"
+	"mcompiler/types"
+	"strconv"
+)
+
+type Options struct {
+	// Builtins are passed on to resolve.Program, which binds the names
+	// whose constants are propagated.
+	Builtins []string
+
+	// Types are the types of the program, from types.Check. Without them,
+	// identities such as x + 0 are left alone unless x is a literal.
+	Types *types.Info
+}
+
+// Diagnostic is an error found while folding, such as a division by zero,
+// which the program would fail with when it reached it. Code in a pruned
+// branch is never reached, so nothing is reported for it.
+type Diagnostic struct {
+	Span ast.Span
+	Msg  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Span.Start, d.Msg)
+}
+
+// Program optimizes program in place and reports the errors found on the
+// way. Function bodies skipped by a lazy parser are parsed first.
+//
+// Like ast.Rewrite, which it is built on, Program puts new nodes on the Go
+// heap, so a tree allocated in an arena must not be optimized while the
+// arena is in use.
+func Program(program *ast.Program, opts Options) []Diagnostic {
+	o := &optimizer{
+		names:     resolve.Program(program, resolve.Options{Builtins: opts.Builtins}),
+		assigned:  make(map[*resolve.Symbol]bool),
+		constants: make(map[*resolve.Symbol]ast.Expression),
+		types:     opts.Types,
+	}
+	ast.InspectType(program, func(assign *ast.AssignExpression) bool {
+		if b, ok := o.names.Bindings[assign.Name]; ok {
+			o.assigned[b.Symbol] = true
+		}
+		return true
+	})
+	// Children are simplified before their parents, so a folded operand
+	// folds again, and in source order, so a constant is known by the
+	// time its uses after the let are reached. A function body above the
+	// let of a global it uses keeps the name, since it could be called
+	// before the let runs.
+	ast.Rewrite(program, nil, o.post)
+
+	var diagnostics []Diagnostic
+	ast.InspectType(program, func(be *ast.BinaryExpression) bool {
+		if msg, ok := o.errors[be]; ok {
+			diagnostics = append(diagnostics, Diagnostic{Span: ast.Span{Start: be.Pos(), End: be.End()}, Msg: msg})
+		}
+		return true
+	})
+	return diagnostics
+}
+
+type optimizer struct {
+	names     *resolve.Info
+	types     *types.Info
+	assigned  map[*resolve.Symbol]bool
+	constants map[*resolve.Symbol]ast.Expression // literals bound by let
+
+	// errors are kept by node until the rewrite is done, and reported for
+	// the nodes still in the tree.
+	errors map[*ast.BinaryExpression]string
+}
+
+func (o *optimizer) errorf(node *ast.BinaryExpression, format string, args ...any) {
+	if o.errors == nil {
+		o.errors = make(map[*ast.BinaryExpression]string)
+	}
+	o.errors[node] = fmt.Sprintf(format, args...)
+}
+
+func (o *optimizer) post(c *ast.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ast.Identifier:
+		// Declarations and assignment targets are not uses.
+		if c.Name() == "Name" || c.Name() == "Parameters" {
+			break
+		}
+		if b, ok := o.names.Bindings[n]; ok {
+			if value, ok := o.constants[b.Symbol]; ok {
+				c.Replace(relocate(value, n.Pos()))
+			}
+		}
+	case *ast.LetStatement:
+		if b, ok := o.names.Bindings[n.Name]; ok && n.Name != nil && !o.assigned[b.Symbol] && constant(n.Value) {
+			o.constants[b.Symbol] = n.Value
+		}
+	case *ast.UnaryExpression:
+		if e := o.unary(n); e != nil {
+			c.Replace(e)
+		}
+	case *ast.BinaryExpression:
+		if e := o.binary(n); e != nil {
+			c.Replace(e)
+		}
+	case *ast.IfStatement:
+		o.prune(c, n)
+	}
+	return true
+}
+
+func constant(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.BooleanLiteral:
+		return true
+	}
+	return false
+}
+
+// isInt reports whether e is known to be an int.
+func (o *optimizer) isInt(e ast.Expression) bool {
+	if _, ok := e.(*ast.IntegerLiteral); ok {
+		return true
+	}
+	if o.types == nil {
+		return false
+	}
+	t, ok := o.types.Types[e]
+	return ok && types.Resolve(t) == types.Int
+}
+
+// pure reports whether evaluating e can be skipped without changing what
+// the program does.
+func pure(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.BooleanLiteral, *ast.Identifier:
+		return true
+	}
+	return false
+}
+
+func intLiteral(v int64, pos token.Pos) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(v, 10), Pos: pos}, Value: v}
+}
+
+func boolLiteral(v bool, pos token.Pos) *ast.BooleanLiteral {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+	if v {
+		tok.Type, tok.Literal = token.TRUE, "true"
+	}
+	return &ast.BooleanLiteral{Token: tok, Value: v}
+}
+
+// relocate copies the literal e to pos, so that each use of a propagated
+// constant is a node of its own.
+func relocate(e ast.Expression, pos token.Pos) ast.Expression {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return intLiteral(e.Value, pos)
+	case *ast.BooleanLiteral:
+		return boolLiteral(e.Value, pos)
+	}
+	return e
+}
+
+func (o *optimizer) unary(n *ast.UnaryExpression) ast.Expression {
+	switch right := n.Right.(type) {
+	case *ast.BooleanLiteral:
+		if n.Token.Type == token.BANG {
+			return boolLiteral(!right.Value, n.Pos())
+		}
+	case *ast.IntegerLiteral:
+		if n.Token.Type == token.MINUS {
+			return intLiteral(-right.Value, n.Pos())
+		}
+	}
+	return nil
+}
+
+// binary returns what n simplifies to, or nil if it stays as it is.
+func (o *optimizer) binary(n *ast.BinaryExpression) ast.Expression {
+	op := n.Token.Type
+	left, lok := n.Left.(*ast.IntegerLiteral)
+	right, rok := n.Right.(*ast.IntegerLiteral)
+
+	if op == token.SLASH && rok && right.Value == 0 {
+		o.errorf(n, "division by zero")
+		return nil
+	}
+	if lok && rok {
+		return foldInts(n, left.Value, right.Value)
+	}
+	if lb, ok := n.Left.(*ast.BooleanLiteral); ok {
+		if rb, ok := n.Right.(*ast.BooleanLiteral); ok {
+			switch op {
+			case token.EQUAL:
+				return boolLiteral(lb.Value == rb.Value, n.Pos())
+			case token.NOTEQUAL:
+				return boolLiteral(lb.Value != rb.Value, n.Pos())
+			}
+			return nil
+		}
+	}
+
+	// An identity holds only if the operand it keeps is an int: true + 0
+	// is an error at run time, not true.
+	if !o.isInt(n.Left) || !o.isInt(n.Right) {
+		return nil
+	}
+	is := func(lit *ast.IntegerLiteral, ok bool, v int64) bool { return ok && lit.Value == v }
+	switch op {
+	case token.PLUS:
+		switch {
+		case is(right, rok, 0):
+			return n.Left
+		case is(left, lok, 0):
+			return n.Right
+		}
+	case token.MINUS:
+		if is(right, rok, 0) {
+			return n.Left
+		}
+	case token.SLASH:
+		if is(right, rok, 1) {
+			return n.Left
+		}
+	case token.ASTERISK:
+		switch {
+		case is(right, rok, 1):
+			return n.Left
+		case is(left, lok, 1):
+			return n.Right
+		case is(right, rok, 0) && pure(n.Left), is(left, lok, 0) && pure(n.Right):
+			return intLiteral(0, n.Pos())
+		}
+	case token.POWER:
+		switch {
+		case is(right, rok, 1):
+			return n.Left
+		case is(right, rok, 0) && pure(n.Left):
+			return intLiteral(1, n.Pos())
+		}
+	}
+	return nil
+}
+
+func foldInts(n *ast.BinaryExpression, l, r int64) ast.Expression {
+	switch n.Token.Type {
+	case token.PLUS:
+		return intLiteral(l+r, n.Pos())
+	case token.MINUS:
+		return intLiteral(l-r, n.Pos())
+	case token.ASTERISK:
+		return intLiteral(l*r, n.Pos())
+	case token.SLASH:
+		return intLiteral(l/r, n.Pos())
+	case token.POWER:
+		if r < 0 {
+			// Left for the run time to decide.
+			return nil
+		}
+		return intLiteral(power(l, r), n.Pos())
+	case token.LT:
+		return boolLiteral(l < r, n.Pos())
+	case token.GT:
+		return boolLiteral(l > r, n.Pos())
+	case token.EQUAL:
+		return boolLiteral(l == r, n.Pos())
+	case token.NOTEQUAL:
+		return boolLiteral(l != r, n.Pos())
+	}
+	return nil
+}
+
+// power returns base**exp, wrapping around like repeated multiplication.
+func power(base, exp int64) int64 {
+	result := int64(1)
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+	}
+	return result
+}
+
+// prune replaces an if whose condition is constant with the branch taken.
+// The branch stays a block, so that its lets stay local to it. An if with
+// nothing to run is deleted, unless it is the last statement of its list,
+// whose value it gives; then it becomes an empty block.
+func (o *optimizer) prune(c *ast.Cursor, n *ast.IfStatement) {
+	cond, ok := n.Condition.(*ast.BooleanLiteral)
+	if !ok || n.Consequence == nil {
+		return
+	}
+	if cond.Value {
+		c.Replace(n.Consequence)
+		return
+	}
+	if n.Alternative != nil {
+		c.Replace(n.Alternative)
+		return
+	}
+	if c.Index() >= 0 && !last(c) {
+		c.Delete()
+		return
+	}
+	empty := &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{", Pos: n.Pos()}}
+	if block, ok := n.Consequence.(*ast.BlockStatement); ok {
+		empty.Token, empty.Rbrace = block.Token, block.Rbrace
+	}
+	c.Replace(empty)
+}
+
+func last(c *ast.Cursor) bool {
+	switch p := c.Parent().(type) {
+	case *ast.Program:
+		return c.Index() == len(p.Statements)-1
+	case *ast.BlockStatement:
+		return c.Index() == len(p.Statements)-1
+	}
+	return true
+}
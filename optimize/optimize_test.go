@@ -0,0 +1,130 @@
+package optimize
+
+import (
+	"math"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"mcompiler/types"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+	return program
+}
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		// Folding.
+		{"1 + 2 * 3;", "7;"},
+		{"(10 - 4) / 3;", "2;"},
+		{"2 ** 10;", "1024;"},
+		{"-(3 - 5);", "2;"},
+		{"!true;", "false;"},
+		{"1 < 2 == true;", "true;"},
+		{"true != false;", "true;"},
+		{"2 ** -1;", "(2 ** -1);"},
+		// Identities.
+		{"x + 0;", "x;"},
+		{"0 + x;", "x;"},
+		{"x - 0;", "x;"},
+		{"x * 1;", "x;"},
+		{"1 * (x + 0);", "x;"},
+		{"x / 1;", "x;"},
+		{"x ** 1;", "x;"},
+		{"x * 0;", "0;"},
+		{"x ** 0;", "1;"},
+		{"f() * 0;", "(f() * 0);"},
+		{"let b = true; b + 0;", "let b = true;(true + 0);"},
+		{"let f = fn() { 1 }; f - 0;", "let f = fn(){1;};(f - 0);"},
+		// Branches.
+		{"if (1 < 2) { a } else { b }", "{a;}"},
+		{"if (!true) { a } else { b }", "{b;}"},
+		{"if (false) { a } c;", "c;"},
+		{"c; if (false) { a }", "c;{}"},
+		{"let f = fn() { if (true) { return 1; } 2 };", "let f = fn(){{return 1;}2;};"},
+		// Propagation.
+		{"let x = 2 * 3; x + 1;", "let x = 6;7;"},
+		{"let t = !false; if (t) { a }", "let t = true;{a;}"},
+		{"let x = 1; x = 2; x + 1;", "let x = 1;(x = 2);(x + 1);"},
+		{"let f = fn() { x }; let x = 1; x;", "let f = fn(){x;};let x = 1;1;"},
+		{"let x = 1; let f = fn(x) { x };", "let x = 1;let f = fn(x){x;};"},
+		{"let x = y; x;", "let x = y;x;"},
+	}
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if d := Program(program, Options{Types: types.Check(program, types.Options{})}); len(d) != 0 {
+			t.Errorf("%q: diagnostics: %v", tt.input, d)
+		}
+		if got := program.String(); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// Without types, only literals are known to be ints.
+func TestProgram_Untyped(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"x + 0;", "(x + 0);"},
+		{"x * 1;", "(x * 1);"},
+		{"x + 2 * 0;", "(x + 0);"},
+		{"2 * 3 + 1;", "7;"},
+	}
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		Program(program, Options{})
+		if got := program.String(); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestProgram_Overflow(t *testing.T) {
+	max := strconv.FormatInt(math.MaxInt64, 10)
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{max + " + 1;", math.MinInt64},
+		{"-" + max + " - 2;", math.MaxInt64},
+		{"2 ** 64;", 0},
+		{"3 ** 41;", -420491770248316829}, // 3**41 mod 2**64
+		{"(-" + max + " - 1) / -1;", math.MinInt64},
+	}
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		Program(program, Options{})
+		lit, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+		if !ok || lit.Value != tt.want {
+			t.Errorf("%q: got %s, want %d", tt.input, program, tt.want)
+		}
+	}
+}
+
+func TestProgram_Diagnostics(t *testing.T) {
+	program := parse(t, "let z = 0;\nlet a = 1 / (2 - 2);\nlet f = fn(x) { x / z };\nif (false) { 1 / 0 }")
+	var got []string
+	for _, d := range Program(program, Options{}) {
+		got = append(got, d.String())
+	}
+	want := []string{"2:9: division by zero", "3:17: division by zero"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// The division is left for the program to fail on.
+	if got, want := program.Statements[1].String(), "let a = (1 / 0);"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
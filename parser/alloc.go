@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"mcompiler/arena"
+	"mcompiler/intern"
+	"mcompiler/token"
+)
+
+// Alloc returns a pointer to a copy of v, carved from the parser's arena when
+// Options.Arena is set and from the Go heap otherwise. Extensions must build
+// their nodes with it: arena memory is not scanned by the garbage collector,
+// so a heap node referenced only from an arena-allocated parent could be
+// freed while still in use.
+func Alloc[T any](p *Parser, v T) *T {
+	var n *T
+	if p.arena == nil {
+		n = new(T)
+	} else {
+		n = arena.Alloc[T](p.arena)
+	}
+	*n = v
+	return n
+}
+
+// collect moves the items pushed onto scratch since base into a slice of
+// their own and pops them. The scratch stacks are shared by every nesting
+// level, so building a list costs one allocation, or none with an arena.
+func collect[T any](p *Parser, scratch *[]T, base int) []T {
+	items := (*scratch)[base:]
+	var out []T
+	if p.arena == nil {
+		out = make([]T, len(items))
+	} else {
+		out = arena.AllocSlice[T](p.arena, len(items))
+	}
+	copy(out, items)
+	clear(items)
+	*scratch = (*scratch)[:base]
+	return out
+}
+
+// own moves a token's literal into the arena, so nodes never point into the
+// source text or other heap memory the arena does not keep alive. An
+// interned literal is copied once per parse and the copy shared.
+func (p *Parser) own(tok token.Token) token.Token {
+	if p.arena == nil {
+		return tok
+	}
+	if tok.Sym == intern.NoSymbol {
+		tok.Literal = p.arena.String(tok.Literal)
+		return tok
+	}
+	if int(tok.Sym) >= len(p.symbols) {
+		p.symbols = append(p.symbols, make([]string, int(tok.Sym)+1-len(p.symbols))...)
+	}
+	if p.symbols[tok.Sym] == "" {
+		p.symbols[tok.Sym] = p.arena.String(tok.Literal)
+	}
+	tok.Literal = p.symbols[tok.Sym]
+	return tok
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"mcompiler/arena"
+	"mcompiler/ast"
+	"mcompiler/intern"
+	"mcompiler/lexer"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestAlloc_ArenaMatchesHeap(t *testing.T) {
+	input := generateSource(50) + "xs |> map(fn(x) => x * 2) |> sum; a = b = c;"
+
+	heap := New(lexer.New(input))
+	expected := heap.ParseProgram().String()
+	if len(heap.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", heap.Errors())
+	}
+
+	a := arena.NewBestArena()
+	for round := 0; round < 3; round++ {
+		a.Reset()
+		// Neither the source copy nor the parser survive the collection, so
+		// a node still pointing into either would be read after free.
+		program, errors := parseInArena(a, strings.Clone(input))
+		runtime.GC()
+
+		if len(errors) > 0 {
+			t.Fatalf("round %d: errors during parsing: %s", round, errors)
+		}
+		if got := program.String(); got != expected {
+			t.Fatalf("round %d: arena parse differs from heap parse", round)
+		}
+	}
+}
+
+func parseInArena(a *arena.BestArena, input string) (*ast.Program, []string) {
+	p := NewWithOptions(lexer.New(input), Options{Arena: a})
+	return p.ParseProgram(), p.Errors()
+}
+
+func TestAlloc_ArenaAllocations(t *testing.T) {
+	input := generateSource(500)
+	a := arena.NewBestArena()
+
+	allocs := testing.AllocsPerRun(5, func() {
+		a.Reset()
+		p := NewWithOptions(lexer.New(input), Options{Arena: a})
+		p.ParseProgram()
+	})
+	// Only the parser, its tables and the amortised growth of the scratch
+	// stacks are left on the heap.
+	if allocs > 100 {
+		t.Errorf("arena parse made %v heap allocations", allocs)
+	}
+}
+
+func TestAlloc_InternedIdentifiers(t *testing.T) {
+	input := "let total = fn(total, step) { return total + step; };"
+	table := intern.NewTable()
+
+	for _, a := range []*arena.BestArena{nil, arena.NewBestArena()} {
+		p := NewWithOptions(lexer.New(input), Options{Arena: a, Interner: table})
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("errors during parsing: %s", p.Errors())
+		}
+
+		let := program.Statements[0].(*ast.LetStatement)
+		fn := let.Value.(*ast.FunctionExpression)
+		ret := fn.Body.(*ast.BlockStatement).Statements[0].(*ast.ReturnStatement)
+		use := ret.Value.(*ast.BinaryExpression).Left.(*ast.Identifier)
+
+		sym, ok := table.Lookup("total")
+		if !ok {
+			t.Fatalf("total was not interned")
+		}
+		for _, ident := range []*ast.Identifier{let.Name, &fn.Parameters[0], use} {
+			if ident.Symbol != sym {
+				t.Errorf("%s has symbol %d, expected %d", ident.Value, ident.Symbol, sym)
+			}
+		}
+		if unsafe.StringData(let.Name.Value) != unsafe.StringData(use.Value) {
+			t.Errorf("uses of %q do not share one string", use.Value)
+		}
+	}
+}
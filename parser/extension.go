@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/token"
+)
+
+// Extension adds syntax to a parser through the Syntax it is given. Nodes
+// built by an extension may be any type embedding ast.CustomExpression or
+// ast.CustomStatement.
+type Extension func(s *Syntax)
+
+type (
+	PrefixParseFn    func(p *Parser) ast.Expression
+	StatementParseFn func(p *Parser) ast.Statement
+)
+
+// Syntax registers keywords, operators and parse functions on the parser
+// being constructed.
+type Syntax struct {
+	p *Parser
+}
+
+// Keyword makes word lex as t instead of an identifier.
+func (s *Syntax) Keyword(word string, t token.TokenType) {
+	s.p.l.AddKeyword(word, t)
+}
+
+// Operator makes symbol lex as t.
+func (s *Syntax) Operator(symbol string, t token.TokenType) {
+	s.p.l.AddOperator(symbol, t)
+}
+
+// Prefix parses expressions starting with t. fn is called with t as the
+// current token and must leave the expression's last token current.
+func (s *Syntax) Prefix(t token.TokenType, fn PrefixParseFn) {
+	p := s.p
+	p.registerPrefix(t, func() ast.Expression { return fn(p) })
+}
+
+// Infix adds t to the operator table; build constructs the node from the
+// operands.
+func (s *Syntax) Infix(t token.TokenType, precedence Precedence, assoc Associativity, build BuildFn) {
+	s.p.registerOperator(t, operator{precedence: precedence, assoc: assoc, build: build})
+}
+
+// Statement parses statements starting with t, which must not be one of the
+// built-in statement keywords. fn follows the same token contract as Prefix.
+func (s *Syntax) Statement(t token.TokenType, fn StatementParseFn) {
+	p := s.p
+	p.statementParseFns[t] = func() ast.Statement { return fn(p) }
+}
+
+// The methods below are for parse functions registered by extensions.
+
+func (p *Parser) CurToken() token.Token  { return p.curToken }
+func (p *Parser) PeekToken() token.Token { return p.peekToken }
+func (p *Parser) NextToken()             { p.nextToken() }
+
+func (p *Parser) CurTokenIs(t token.TokenType) bool  { return p.curTokenIs(t) }
+func (p *Parser) PeekTokenIs(t token.TokenType) bool { return p.peekTokenIs(t) }
+
+// ExpectPeek advances if the next token is t and reports an error otherwise.
+func (p *Parser) ExpectPeek(t token.TokenType) bool { return p.expectPeek(t) }
+
+// ParseSubExpression parses an expression starting at the current token that
+// binds tighter than precedence.
+func (p *Parser) ParseSubExpression(precedence Precedence) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
+// ParseSubBlock parses a `{ ... }` block starting at the current token.
+func (p *Parser) ParseSubBlock() *ast.BlockStatement {
+	if !p.curTokenIs(token.LBRACE) {
+		p.currError(token.LBRACE)
+		return nil
+	}
+	block, _ := p.parseBlockStatement().(*ast.BlockStatement)
+	return block
+}
+
+// Errorf reports an error at the current token.
+func (p *Parser) Errorf(format string, args ...any) {
+	p.errorAt(p.curToken.Pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/token"
+	"testing"
+)
+
+type unlessStatement struct {
+	ast.CustomStatement
+	Token     token.Token
+	Condition ast.Expression
+	Body      *ast.BlockStatement
+}
+
+func (us *unlessStatement) TokenLiteral() string { return us.Token.Literal }
+func (us *unlessStatement) String() string {
+	return "unless " + us.Condition.String() + " " + us.Body.String()
+}
+
+type moduloExpression struct {
+	ast.CustomExpression
+	Token       token.Token
+	Left, Right ast.Expression
+}
+
+func (me *moduloExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *moduloExpression) String() string {
+	return "(" + me.Left.String() + " mod " + me.Right.String() + ")"
+}
+
+type nilLiteral struct {
+	ast.CustomExpression
+	Token token.Token
+}
+
+func (nl *nilLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *nilLiteral) String() string       { return "nil" }
+
+func dslExtension(s *Syntax) {
+	s.Keyword("unless", "UNLESS")
+	s.Statement("UNLESS", func(p *Parser) ast.Statement {
+		stmt := Alloc(p, unlessStatement{Token: p.CurToken()})
+		if !p.ExpectPeek(token.LPAREN) {
+			return nil
+		}
+		p.NextToken()
+		stmt.Condition = p.ParseSubExpression(LOWEST)
+		if !p.ExpectPeek(token.RPAREN) || !p.ExpectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Body = p.ParseSubBlock()
+		return stmt
+	})
+
+	s.Operator("%", "MOD")
+	s.Infix("MOD", PRODUCT, LeftAssoc, func(p *Parser, tok token.Token, left, right ast.Expression) ast.Expression {
+		return Alloc(p, moduloExpression{Token: tok, Left: left, Right: right})
+	})
+
+	s.Keyword("nil", "NIL")
+	s.Prefix("NIL", func(p *Parser) ast.Expression {
+		return Alloc(p, nilLiteral{Token: p.CurToken()})
+	})
+}
+
+func TestExtension_CustomSyntax(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + b % c;", "(a + (b mod c));"},
+		{"a % b * c;", "((a mod b) * c);"},
+		{"unless (x % 2 == 0) { f(nil); }", "unless ((x mod 2) == 0) {f(nil);}"},
+		{"let unlessed = nil;", "let unlessed = nil;"},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{Extensions: []Extension{dslExtension}})
+		program := p.ParseProgram()
+
+		if len(p.Errors()) > 0 {
+			t.Errorf("%q: errors during parsing: %s", tt.input, p.Errors())
+			continue
+		}
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestExtension_Errorf(t *testing.T) {
+	reject := func(s *Syntax) {
+		s.Keyword("todo", "TODO")
+		s.Prefix("TODO", func(p *Parser) ast.Expression {
+			p.Errorf("%s is not implemented", p.CurToken().Literal)
+			return nil
+		})
+	}
+
+	p := NewWithOptions(lexer.New("let x =\n  todo;"), Options{Extensions: []Extension{reject}})
+	p.ParseProgram()
+
+	diags := p.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got=%v", diags)
+	}
+	if diags[0].String() != "2:3: todo is not implemented" {
+		t.Errorf("wrong diagnostic. got=%q", diags[0].String())
+	}
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcompiler/arena"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// File is one parsed source file.
+type File struct {
+	Path        string
+	Program     *ast.Program
+	Diagnostics []Diagnostic
+}
+
+// FileDiagnostic is a diagnostic together with the file it was reported in.
+type FileDiagnostic struct {
+	Path string
+	Diagnostic
+}
+
+func (d FileDiagnostic) String() string {
+	return fmt.Sprintf("%s:%s", d.Path, d.Diagnostic)
+}
+
+// ParsedFiles holds the result of ParseFiles. The trees live in the arenas of
+// the workers that parsed them and stay valid until Release.
+type ParsedFiles struct {
+	// Files are in the order of the paths given to ParseFiles.
+	Files []File
+
+	// Diagnostics of all files, sorted by path and position.
+	Diagnostics []FileDiagnostic
+
+	arenas []*arena.BestArena
+}
+
+// Release frees the memory of every tree. Neither the trees nor anything
+// taken from them may be used afterwards.
+func (pf *ParsedFiles) Release() {
+	for _, a := range pf.arenas {
+		a.Reset()
+	}
+	pf.Files = nil
+	pf.arenas = nil
+}
+
+// ParseFiles reads and parses the files at paths on workers goroutines, or
+// one per CPU if workers is not positive. Each worker allocates the trees it
+// builds from an arena of its own.
+//
+// Syntax errors are reported as diagnostics; a file that cannot be read makes
+// ParseFiles fail. If ctx is cancelled, ParseFiles stops handing out files
+// and returns ctx.Err() once the files already being parsed are done.
+func ParseFiles(ctx context.Context, paths []string, workers int) (*ParsedFiles, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(paths))
+
+	files := make([]File, len(paths))
+	errs := make([]error, len(paths))
+	arenas := make([]*arena.BestArena, workers)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := range arenas {
+		a := arena.NewBestArena()
+		arenas[w] = a
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				files[i], errs[i] = parseFile(a, paths[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var diagnostics []FileDiagnostic
+	for _, f := range files {
+		for _, d := range f.Diagnostics {
+			diagnostics = append(diagnostics, FileDiagnostic{Path: f.Path, Diagnostic: d})
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Path != diagnostics[j].Path {
+			return diagnostics[i].Path < diagnostics[j].Path
+		}
+		return diagnostics[i].Pos.Offset < diagnostics[j].Pos.Offset
+	})
+
+	return &ParsedFiles{Files: files, Diagnostics: diagnostics, arenas: arenas}, nil
+}
+
+func parseFile(a *arena.BestArena, path string) (File, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	p := NewWithOptions(lexer.New(string(src)), Options{Arena: a})
+	program := p.ParseProgram()
+	return File{Path: path, Program: program, Diagnostics: p.Diagnostics()}, nil
+}
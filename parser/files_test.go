@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcompiler/lexer"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeSources writes n generated files of the given size into dir. Every
+// broken-th file, if broken is positive, has two syntax errors.
+func writeSources(t testing.TB, dir string, n, functions, broken int) []string {
+	t.Helper()
+	paths := make([]string, n)
+	for i := range paths {
+		src := generateSource(functions)
+		if broken > 0 && i%broken == 0 {
+			src = "let y 2;\n" + src + "let a = 1 +;\n"
+		}
+		paths[i] = filepath.Join(dir, fmt.Sprintf("file%03d.mk", i))
+		if err := os.WriteFile(paths[i], []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func TestParseFiles(t *testing.T) {
+	paths := writeSources(t, t.TempDir(), 20, 10, 3)
+	// Hand the files over out of order: results follow paths, diagnostics
+	// are sorted.
+	paths[0], paths[7] = paths[7], paths[0]
+
+	for _, workers := range []int{1, 4, 0} {
+		parsed, err := ParseFiles(context.Background(), paths, workers)
+		if err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+
+		var diagnostics []FileDiagnostic
+		for i, f := range parsed.Files {
+			if f.Path != paths[i] {
+				t.Fatalf("workers=%d: file %d is %s, want %s", workers, i, f.Path, paths[i])
+			}
+			src, _ := os.ReadFile(f.Path)
+			p := New(lexer.New(string(src)))
+			if got, want := f.Program.String(), p.ParseProgram().String(); got != want {
+				t.Errorf("workers=%d: %s parsed differently than alone", workers, f.Path)
+			}
+			if len(f.Diagnostics) != len(p.Diagnostics()) {
+				t.Errorf("workers=%d: %s has %d diagnostics, want %d",
+					workers, f.Path, len(f.Diagnostics), len(p.Diagnostics()))
+			}
+			for _, d := range f.Diagnostics {
+				diagnostics = append(diagnostics, FileDiagnostic{Path: f.Path, Diagnostic: d})
+			}
+		}
+
+		if len(parsed.Diagnostics) != len(diagnostics) || len(diagnostics) == 0 {
+			t.Fatalf("workers=%d: got %d diagnostics, want %d", workers, len(parsed.Diagnostics), len(diagnostics))
+		}
+		for i := 1; i < len(parsed.Diagnostics); i++ {
+			prev, d := parsed.Diagnostics[i-1], parsed.Diagnostics[i]
+			if prev.Path > d.Path || prev.Path == d.Path && prev.Pos.Offset > d.Pos.Offset {
+				t.Fatalf("workers=%d: diagnostics not sorted: %s before %s", workers, prev, d)
+			}
+		}
+
+		runtime.GC()
+		for _, f := range parsed.Files {
+			if f.Program.String() == "" {
+				t.Fatalf("workers=%d: %s lost its tree", workers, f.Path)
+			}
+		}
+		parsed.Release()
+	}
+}
+
+func TestParseFiles_Cancel(t *testing.T) {
+	paths := writeSources(t, t.TempDir(), 50, 10, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ParseFiles(ctx, paths, 2); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestParseFiles_ReadError(t *testing.T) {
+	dir := t.TempDir()
+	paths := append(writeSources(t, dir, 2, 1, 0), filepath.Join(dir, "missing.mk"))
+
+	if _, err := ParseFiles(context.Background(), paths, 2); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("err = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+// BenchmarkParseFiles parses the same directory with more and more workers;
+// MB/s should grow close to linearly up to the number of CPUs.
+func BenchmarkParseFiles(b *testing.B) {
+	paths := writeSources(b, b.TempDir(), 64, 200, 0)
+	var size int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size += info.Size()
+	}
+
+	for workers := 1; workers <= runtime.GOMAXPROCS(0); workers *= 2 {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(size)
+			for b.Loop() {
+				parsed, err := ParseFiles(context.Background(), paths, workers)
+				if err != nil {
+					b.Fatal(err)
+				}
+				parsed.Release()
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/token"
+)
+
+// skipBody moves from the `{` of a function body to its matching `}` and
+// leaves the parsing of the body to fn.Lazy. Brackets in between must
+// balance; if they do not, skipBody reports nothing and returns false, and
+// the caller parses the body after all, so that the errors are those of an
+// eager parse.
+//
+// A body that starts before the point where a scan failed is not scanned
+// again: it either closes before that point, and parses as cheaply eagerly,
+// or fails there too. Without that, every body nested in an unclosed one
+// would rescan the rest of the file.
+func (p *Parser) skipBody(fn *ast.FunctionExpression) bool {
+	lbrace := p.curToken
+	if lbrace.Pos.Offset < p.unbalanced {
+		return false
+	}
+	var stack [32]token.TokenType
+	open := stack[:0]
+	for {
+		p.nextToken()
+		switch p.curToken.Type {
+		case token.LPAREN, token.LBRACE:
+			open = append(open, p.curToken.Type)
+			continue
+		case token.RPAREN, token.RBRACE:
+		case token.EOF:
+			p.unbalanced = p.curToken.Pos.Offset
+			return false
+		default:
+			continue
+		}
+
+		var want token.TokenType = token.RBRACE
+		if n := len(open); n > 0 && open[n-1] == token.LPAREN {
+			want = token.RPAREN
+		}
+		if p.curToken.Type != want {
+			p.unbalanced = p.curToken.Pos.Offset
+			return false
+		}
+		if len(open) == 0 {
+			break
+		}
+		open = open[:len(open)-1]
+	}
+
+	src := p.l.Input()[lbrace.Pos.Offset:p.curToken.End().Offset]
+	if p.arena != nil {
+		src = p.arena.String(src)
+	}
+	fn.Lazy = p.lazyBody(lbrace)
+	fn.LazySource, fn.LazyRbrace = src, p.curToken.Pos
+	return true
+}
+
+// rewind goes back to tok, which must have been read by this parser, and
+// makes it the current token again.
+func (p *Parser) rewind(tok token.Token) {
+	p.l.Seek(tok.Pos)
+	p.l.NextToken()
+	p.curToken = tok
+	p.peekToken = p.own(p.l.NextToken())
+}
+
+// lazyBody returns a function that parses the block at lbrace with a parser
+// of its own, as deeply nested as this one is now. The errors it finds are
+// reported by this parser.
+func (p *Parser) lazyBody(lbrace token.Token) func() ast.Statement {
+	input, opts, depth := p.l.Input(), p.opts, p.depth
+	parse := func() ast.Statement {
+		// Bodies skipped by the body's parser report through it in turn.
+		opts := opts
+		opts.Diagnostics = func(d Diagnostic) { p.errorAt(d.Pos, d.Msg) }
+		l := lexer.New(input)
+		l.Seek(lbrace.Pos)
+		body := NewWithOptions(l, opts)
+		body.depth = depth
+		return body.parseBlockStatement()
+	}
+	if p.arena != nil {
+		p.arena.Keep(parse)
+	}
+	return parse
+}
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/arena"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLazyBodies(t *testing.T) {
+	input := generateSource(20) + "let k = fn(x) { fn(y) { x + y } };"
+
+	eager := New(lexer.New(input)).ParseProgram()
+
+	p := NewWithOptions(lexer.New(input), Options{LazyBodies: true})
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionExpression)
+	if fn.Body != nil || fn.Lazy == nil {
+		t.Fatalf("body of %s was parsed eagerly", fn.Token.Literal)
+	}
+	body, ok := fn.ParseBody().(*ast.BlockStatement)
+	if !ok || len(body.Statements) != 4 {
+		t.Fatalf("ParseBody() = %v", fn.Body)
+	}
+	if fn.Lazy != nil || fn.ParseBody() != body {
+		t.Fatalf("body was not kept after parsing it")
+	}
+
+	// Nested bodies are skipped in turn.
+	last := program.Statements[len(program.Statements)-1].(*ast.LetStatement)
+	outer := last.Value.(*ast.FunctionExpression).ParseBody().(*ast.BlockStatement)
+	inner := outer.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionExpression)
+	if inner.Body != nil || inner.Lazy == nil {
+		t.Fatalf("nested body was parsed eagerly")
+	}
+
+	assertSameTree(t, program, eager)
+}
+
+// Lazy parsing reports the errors of an eager parse, at once for bodies whose
+// brackets do not balance and when ParseBody is called for the others.
+func TestLazyBodies_Errors(t *testing.T) {
+	tests := []string{
+		"let f = fn() { (1 + 2 };",
+		"let f = fn() { g(1)) };",
+		"let f = fn() { if (x) { 1 }",
+		"let f = fn() {",
+		"let f = fn() { let = 1; }; let g = fn() { ) ;",
+		"let f = fn() { fn() { let x 1; } };",
+	}
+
+	for _, input := range tests {
+		eager := New(lexer.New(input))
+		eager.ParseProgram()
+
+		p := NewWithOptions(lexer.New(input), Options{LazyBodies: true})
+		ast.Inspect(p.ParseProgram(), func(ast.Node) bool { return true })
+		if got, want := sortedDiagnostics(p), sortedDiagnostics(eager); got != want {
+			t.Errorf("%q: lazy diagnostics = %s, eager = %s", input, got, want)
+		}
+	}
+}
+
+// Bodies inside an unbalanced one are parsed eagerly without scanning each
+// to the end of the file again; bodies past it are still skipped.
+func TestLazyBodies_Unbalanced(t *testing.T) {
+	input := strings.Repeat("fn() { ", 100000)
+	start := time.Now()
+	NewWithOptions(lexer.New(input), Options{LazyBodies: true}).ParseProgram()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("parsing %d unclosed bodies took %s", 100000, elapsed)
+	}
+
+	p := NewWithOptions(lexer.New("let f = fn() { let g = fn() { 1 }; (1 }; let h = fn() { 2 };"), Options{LazyBodies: true})
+	program := p.ParseProgram()
+	f := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionExpression)
+	h := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionExpression)
+	if f.Lazy != nil || h.Lazy == nil {
+		t.Errorf("f lazy = %t, h lazy = %t; want only h", f.Lazy != nil, h.Lazy != nil)
+	}
+	if len(p.Errors()) == 0 {
+		t.Errorf("no errors for the unbalanced body")
+	}
+}
+
+func sortedDiagnostics(p *Parser) string {
+	diagnostics := slices.Clone(p.Diagnostics())
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int { return a.Pos.Offset - b.Pos.Offset })
+	return fmt.Sprint(diagnostics)
+}
+
+// End and String of a function whose body was skipped leave it skipped.
+func TestLazyBodies_NoSideEffects(t *testing.T) {
+	p := NewWithOptions(lexer.New("let f = fn(x) {\n  x +  1\n};"), Options{LazyBodies: true})
+	fn := p.ParseProgram().Statements[0].(*ast.LetStatement).Value.(*ast.FunctionExpression)
+
+	if got := fn.End().String(); got != "3:2" {
+		t.Errorf("End() = %s, want 3:2", got)
+	}
+	if got, want := fn.String(), "fn(x){\n  x +  1\n}"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if fn.Lazy == nil {
+		t.Fatalf("End or String parsed the body")
+	}
+	if got := fn.ParseBody(); fn.End() != got.End() {
+		t.Errorf("End() = %s once parsed, want %s", fn.End(), got.End())
+	}
+}
+
+func TestLazyBodies_DeferredDiagnostics(t *testing.T) {
+	var reported []Diagnostic
+	p := NewWithOptions(lexer.New("let f = fn() {\n  let = 1;\n};"), Options{
+		LazyBodies:  true,
+		Diagnostics: func(d Diagnostic) { reported = append(reported, d) },
+	})
+	program := p.ParseProgram()
+	if len(reported) > 0 {
+		t.Fatalf("diagnostics before the body was parsed: %v", reported)
+	}
+
+	program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionExpression).ParseBody()
+	if len(reported) == 0 || reported[0].Pos.Line != 2 {
+		t.Fatalf("diagnostics = %v, want one on line 2", reported)
+	}
+}
+
+func TestLazyBodies_Arena(t *testing.T) {
+	input := generateSource(20)
+	expected := New(lexer.New(input)).ParseProgram().String()
+
+	a := arena.NewBestArena()
+	program := parseLazyInArena(a, strings.Clone(input))
+	// The lazy parsers are only reachable through the arena now.
+	runtime.GC()
+	ast.Inspect(program, func(ast.Node) bool { return true })
+	if got := program.String(); got != expected {
+		t.Fatalf("lazy arena parse differs from eager heap parse")
+	}
+	runtime.KeepAlive(a)
+}
+
+func parseLazyInArena(a *arena.BestArena, input string) *ast.Program {
+	return NewWithOptions(lexer.New(input), Options{Arena: a, LazyBodies: true}).ParseProgram()
+}
+
+func TestLazyBodies_Reparse(t *testing.T) {
+	opts := Options{LazyBodies: true}
+	old := NewWithOptions(lexer.New(reparseSource), opts).ParseProgram()
+
+	offset := strings.Index(reparseSource, "let one")
+	edit := Edit{Offset: offset, Len: len("let one"), Text: "let\n\n  uno"}
+	program, _, err := Reparse(old, edit, opts)
+	if err != nil {
+		t.Fatalf("Reparse: %v", err)
+	}
+
+	source := reparseSource[:offset] + edit.Text + reparseSource[offset+edit.Len:]
+	assertSameTree(t, program, New(lexer.New(source)).ParseProgram())
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/token"
+)
+
+type Precedence int
+
+const (
+	_ Precedence = iota
+	LOWEST
+	ASSIGNMENT // =
+	PIPELINE   // |>
+	EQUALITY   // == !=
+	COMPARISON // < >
+	SUM        // + -
+	PRODUCT    // * /
+	PREFIX     // -x !x
+	POWER      // **
+)
+
+type Associativity int
+
+const (
+	LeftAssoc Associativity = iota
+	RightAssoc
+)
+
+// operator describes how an infix token binds and which node it builds.
+// build returns nil when the operands cannot form that node, e.g. an
+// assignment to something other than an identifier.
+// An operator whose feature is disabled in Options is left out of the
+// parser's table.
+type operator struct {
+	precedence Precedence
+	assoc      Associativity
+	build      BuildFn
+	feature    Feature
+}
+
+// BuildFn constructs the node for an infix operator from its operands.
+// Nodes should be allocated with Alloc.
+type BuildFn func(p *Parser, tok token.Token, left, right ast.Expression) ast.Expression
+
+var operators = map[token.TokenType]operator{
+	token.ASSIGN:   {ASSIGNMENT, RightAssoc, newAssignExpression, AssignmentExpressions},
+	token.PIPE:     {PIPELINE, LeftAssoc, newPipeExpression, PipeOperator},
+	token.EQUAL:    {EQUALITY, LeftAssoc, newBinaryExpression, 0},
+	token.NOTEQUAL: {EQUALITY, LeftAssoc, newBinaryExpression, 0},
+	token.LT:       {COMPARISON, LeftAssoc, newBinaryExpression, 0},
+	token.GT:       {COMPARISON, LeftAssoc, newBinaryExpression, 0},
+	token.PLUS:     {SUM, LeftAssoc, newBinaryExpression, 0},
+	token.MINUS:    {SUM, LeftAssoc, newBinaryExpression, 0},
+	token.ASTERISK: {PRODUCT, LeftAssoc, newBinaryExpression, 0},
+	token.SLASH:    {PRODUCT, LeftAssoc, newBinaryExpression, 0},
+	token.POWER:    {POWER, RightAssoc, newBinaryExpression, PowerOperator},
+}
+
+// Operator reports how a built-in infix operator binds, for tools such as
+// the formatter that print expressions back. Operators added by extensions
+// are not included.
+func Operator(t token.TokenType) (Precedence, Associativity, bool) {
+	op, ok := operators[t]
+	return op.precedence, op.assoc, ok
+}
+
+func (p *Parser) precedence(tokenType token.TokenType) Precedence {
+	if op, ok := p.operators[tokenType]; ok {
+		return op.precedence
+	}
+	return LOWEST
+}
+
+func (p *Parser) registerOperator(tokenType token.TokenType, op operator) {
+	p.operators[tokenType] = op
+	p.registerInfix(tokenType, p.parseOperatorExpression)
+}
+
+func newBinaryExpression(p *Parser, tok token.Token, left, right ast.Expression) ast.Expression {
+	return Alloc(p, ast.BinaryExpression{Token: tok, Left: left, Right: right})
+}
+
+func newPipeExpression(p *Parser, tok token.Token, left, right ast.Expression) ast.Expression {
+	return Alloc(p, ast.PipeExpression{Token: tok, Left: left, Right: right})
+}
+
+func newAssignExpression(p *Parser, tok token.Token, left, right ast.Expression) ast.Expression {
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	return Alloc(p, ast.AssignExpression{Token: tok, Name: name, Value: right})
+}
+
+func (p *Parser) parseOperatorExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseOperatorExpression"))
+	tok := p.curToken
+	op := p.operators[tok.Type]
+
+	// A right-associative operator parses its right operand one level lower,
+	// so another operator of the same precedence is absorbed into it.
+	precedence := op.precedence
+	if op.assoc == RightAssoc {
+		precedence--
+	}
+
+	p.nextToken()
+	right := p.parseExpression(precedence)
+
+	expression := op.build(p, tok, left, right)
+	if expression == nil {
+		// The tree keeps no hole where the expression was: tolerant mode
+		// puts a placeholder over it, otherwise the left operand stays.
+		p.errorAt(tok.Pos, fmt.Sprintf("invalid left-hand side of %s: %s", tok.Literal, left))
+		if p.opts.Tolerant {
+			return Alloc(p, ast.MissingExpression{Token: tok, Span: ast.Span{Start: left.Pos(), End: p.curToken.End()}})
+		}
+		return left
+	}
+	return expression
+}
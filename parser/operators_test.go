@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/lexer"
+	"mcompiler/token"
+	"testing"
+)
+
+// The expected binding of every binary operator, written out independently of
+// the operators table so a table edit that changes precedence fails here.
+var precedenceMatrix = []struct {
+	op    string
+	level int
+	right bool
+}{
+	{"=", 1, true},
+	{"|>", 2, false},
+	{"==", 3, false},
+	{"!=", 3, false},
+	{"<", 4, false},
+	{">", 4, false},
+	{"+", 5, false},
+	{"-", 5, false},
+	{"*", 6, false},
+	{"/", 6, false},
+	{"**", 7, true},
+}
+
+func TestOperators_PrecedenceMatrix(t *testing.T) {
+	if len(precedenceMatrix) != len(operators) {
+		t.Fatalf("matrix covers %d operators, table has %d", len(precedenceMatrix), len(operators))
+	}
+
+	for _, first := range precedenceMatrix {
+		for _, second := range precedenceMatrix {
+			input := fmt.Sprintf("a %s b %s c;", first.op, second.op)
+
+			groupLeft := first.level > second.level ||
+				(first.level == second.level && !first.right)
+
+			var expected string
+			if groupLeft {
+				expected = fmt.Sprintf("((a %s b) %s c);", first.op, second.op)
+			} else {
+				expected = fmt.Sprintf("(a %s (b %s c));", first.op, second.op)
+			}
+			// Grouping `(a op b) = c` produces an invalid assignment target.
+			wantErr := groupLeft && second.op == "=" && first.op != "="
+
+			p := New(lexer.New(input))
+			program := p.ParseProgram()
+
+			if wantErr {
+				if len(p.Errors()) == 0 {
+					t.Errorf("%q: expected an invalid assignment error", input)
+				}
+				continue
+			}
+			if len(p.Errors()) > 0 {
+				t.Errorf("%q: errors during parsing: %s", input, p.Errors())
+				continue
+			}
+			if got := program.String(); got != expected {
+				t.Errorf("%q: expected=%q, got=%q", input, expected, got)
+			}
+		}
+	}
+}
+
+func TestOperators_Associativity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a - b - c;", "((a - b) - c);"},
+		{"a / b / c;", "((a / b) / c);"},
+		{"a ** b ** c;", "(a ** (b ** c));"},
+		{"a = b = c;", "(a = (b = c));"},
+		{"a = b + c * d ** e;", "(a = (b + (c * (d ** e))));"},
+		{"-a ** b;", "(- (a ** b));"},
+		{"-a * b;", "((- a) * b);"},
+		{"a + b < c;", "((a + b) < c);"},
+		{"a < b == c > d;", "((a < b) == (c > d));"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) > 0 {
+			t.Errorf("%q: errors during parsing: %s", tt.input, p.Errors())
+			continue
+		}
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestOperator(t *testing.T) {
+	if prec, assoc, ok := Operator(token.POWER); !ok || prec != POWER || assoc != RightAssoc {
+		t.Errorf("Operator(**) = %s, %s, %t", prec, assoc, ok)
+	}
+	if prec, assoc, ok := Operator(token.MINUS); !ok || prec != SUM || assoc != LeftAssoc {
+		t.Errorf("Operator(-) = %s, %s, %t", prec, assoc, ok)
+	}
+	if _, _, ok := Operator(token.BANG); ok {
+		t.Errorf("Operator(!) found a prefix operator")
+	}
+}
+
+func TestOperators_InvalidAssignment(t *testing.T) {
+	p := New(lexer.New("5 = x;"))
+	program := p.ParseProgram()
+
+	if got := program.String(); got != "5;" {
+		t.Errorf("program.String() = %q, want %q", got, "5;")
+	}
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 error, got=%v", p.Errors())
+	}
+	expected := "invalid left-hand side of =: 5"
+	if p.Errors()[0] != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, p.Errors()[0])
+	}
+}
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"mcompiler/arena"
+	"mcompiler/intern"
+	"mcompiler/token"
+)
+
+// DefaultMaxDepth bounds how deeply expressions and blocks may nest when
+// Options.MaxDepth is not set. It is far below the point where the recursive
+// descent would exhaust a goroutine stack.
+const DefaultMaxDepth = 1000
+
+// Feature is a syntax extension over plain Monkey that can be switched off.
+type Feature uint
+
+const (
+	PipeOperator Feature = 1 << iota
+	ArrowFunctions
+	AssignmentExpressions
+	PowerOperator
+	TypeAnnotations
+)
+
+type Options struct {
+	// Disable lists features the parser rejects. The zero value accepts
+	// the whole language.
+	Disable Feature
+
+	// MaxDepth is the deepest nesting of expressions and blocks accepted
+	// before parsing stops with a diagnostic.
+	MaxDepth int
+
+	// MaxErrors stops parsing once that many diagnostics have been
+	// reported. Zero means no limit.
+	MaxErrors int
+
+	// Diagnostics, when set, is called with every diagnostic as it is
+	// reported.
+	Diagnostics func(Diagnostic)
+
+	// Arena, when set, holds every node, node slice and literal string of
+	// the parse instead of the Go heap. The tree stays valid until the
+	// arena is Reset.
+	Arena *arena.BestArena
+
+	// Interner, when set, interns identifier and integer literals so every
+	// ast.Identifier carries its Symbol.
+	Interner *intern.Table
+
+	// Tolerant never leaves a node out of the tree: syntax that is missing
+	// or malformed becomes an ast.MissingExpression, ast.MissingStatement or
+	// ast.MissingType, and a missing name an Identifier with an empty Value.
+	// Parsing goes on after each error as far as the input allows.
+	Tolerant bool
+
+	// LazyBodies skips the bodies of `fn(...) { ... }` literals after
+	// checking that their brackets balance; a body whose brackets do not is
+	// parsed at once. A skipped body is parsed the first time
+	// FunctionExpression.ParseBody is called, with these same options, and
+	// the diagnostics found then are added to the parser's. Bodies skipped
+	// by one parser must not be parsed concurrently.
+	LazyBodies bool
+
+	// Trace, when set, receives an indented log of every parse function
+	// entered and left, which prefix and infix functions parseExpression
+	// picked and at what precedence, with the current and peek tokens.
+	Trace io.Writer
+
+	// Extensions add syntax to the parser and its lexer. They run in order
+	// before the first token is read.
+	Extensions []Extension
+}
+
+type Diagnostic struct {
+	Pos token.Pos
+	Msg string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Msg)
+}
+
+// enter records one more level of nesting. Past the limit it reports a single
+// diagnostic and halts the parser.
+func (p *Parser) enter() bool {
+	p.depth++
+	if p.depth <= p.maxDepth {
+		return true
+	}
+	p.depth--
+	if !p.halted {
+		p.errorAt(p.curToken.Pos, fmt.Sprintf("maximum nesting depth of %d exceeded", p.maxDepth))
+		p.halt()
+	}
+	return false
+}
+
+func (p *Parser) leave() {
+	p.depth--
+}
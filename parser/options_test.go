@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/lexer"
+	"strings"
+	"testing"
+)
+
+func TestOptions_MaxDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxDepth int
+		err      string
+	}{
+		{"parens within limit", "((((1))));", 10, ""},
+		{"parens over limit", "((((1))));", 3, "maximum nesting depth of 3 exceeded"},
+		{"blocks over limit", "{{{{ x; }}}}", 3, "maximum nesting depth of 3 exceeded"},
+		{"prefix over limit", "----x;", 3, "maximum nesting depth of 3 exceeded"},
+		{"right assoc over limit", "a = b = c = d = e;", 3, "maximum nesting depth of 3 exceeded"},
+		{"default limit", strings.Repeat("(", 10000), 0,
+			fmt.Sprintf("maximum nesting depth of %d exceeded", DefaultMaxDepth)},
+		{"default limit blocks", strings.Repeat("{", 10000), 0,
+			fmt.Sprintf("maximum nesting depth of %d exceeded", DefaultMaxDepth)},
+		{"default limit functions", strings.Repeat("fn(){", 10000), 0,
+			fmt.Sprintf("maximum nesting depth of %d exceeded", DefaultMaxDepth)},
+		{"unterminated call", "f(a, " + strings.Repeat("f(", 5000), 0,
+			fmt.Sprintf("maximum nesting depth of %d exceeded", DefaultMaxDepth)},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{MaxDepth: tt.maxDepth})
+		p.ParseProgram()
+
+		if tt.err == "" {
+			if len(p.Errors()) > 0 {
+				t.Errorf("%s: errors during parsing: %s", tt.name, p.Errors())
+			}
+			continue
+		}
+		if len(p.Errors()) != 1 || p.Errors()[0] != tt.err {
+			t.Errorf("%s: wrong errors. expected=[%q], got=%q", tt.name, tt.err, p.Errors())
+		}
+	}
+}
+
+func TestOptions_MaxDepthTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxDepth int
+		err      string
+	}{
+		{"within limit", "fn(fn() => int) => fn() => bool", 10, ""},
+		{"parameters over limit", "fn(fn(fn(fn() => int) => int) => int) => int", 3, "maximum nesting depth of 3 exceeded"},
+		{"results over limit", "fn() => fn() => fn() => fn() => int", 3, "maximum nesting depth of 3 exceeded"},
+		{"unterminated", strings.Repeat("fn(", 5000), 10, "maximum nesting depth of 10 exceeded"},
+		{"default limit", strings.Repeat("fn(", 10000), 0,
+			fmt.Sprintf("maximum nesting depth of %d exceeded", DefaultMaxDepth)},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{MaxDepth: tt.maxDepth})
+		p.ParseType()
+
+		if tt.err == "" {
+			if len(p.Errors()) > 0 {
+				t.Errorf("%s: errors during parsing: %s", tt.name, p.Errors())
+			}
+			continue
+		}
+		if len(p.Errors()) != 1 || p.Errors()[0] != tt.err {
+			t.Errorf("%s: wrong errors. expected=[%q], got=%q", tt.name, tt.err, p.Errors())
+		}
+	}
+}
+
+func TestOptions_Disable(t *testing.T) {
+	tests := []struct {
+		input   string
+		disable Feature
+		err     string
+	}{
+		{"xs |> f;", PipeOperator, "no prefix parse function for |> found"},
+		{"a ** b;", PowerOperator, "no prefix parse function for ** found"},
+		{"a = b;", AssignmentExpressions, "no prefix parse function for = found"},
+		{"fn(x) => x;", ArrowFunctions, "expected next token to be {, got => instead"},
+		{"let n: int = 1;", TypeAnnotations, "expected next token to be =, got : instead"},
+		{"fn(x: int) { x };", TypeAnnotations, "expected next token to be ), got : instead"},
+		{"xs |> f;", ArrowFunctions, ""},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{Disable: tt.disable})
+		p.ParseProgram()
+
+		if tt.err == "" {
+			if len(p.Errors()) > 0 {
+				t.Errorf("%q: errors during parsing: %s", tt.input, p.Errors())
+			}
+			continue
+		}
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.err {
+			t.Errorf("%q: wrong errors. expected=%q, got=%q", tt.input, tt.err, p.Errors())
+		}
+	}
+}
+
+func TestOptions_Diagnostics(t *testing.T) {
+	input := "let = 5;\nlet y 6;\nlet z = 7;"
+
+	var sunk []Diagnostic
+	p := NewWithOptions(lexer.New(input), Options{
+		Diagnostics: func(d Diagnostic) { sunk = append(sunk, d) },
+	})
+	p.ParseProgram()
+
+	if len(sunk) == 0 || len(sunk) != len(p.Diagnostics()) {
+		t.Fatalf("sink got %d diagnostics, parser has %d", len(sunk), len(p.Diagnostics()))
+	}
+	expected := map[string]bool{
+		"1:5: expected next token to be IDENT, got = instead": false,
+		"2:7: expected next token to be =, got INT instead":   false,
+	}
+	for _, d := range sunk {
+		if _, ok := expected[d.String()]; ok {
+			expected[d.String()] = true
+		}
+	}
+	for exp, seen := range expected {
+		if !seen {
+			t.Errorf("diagnostic %q not reported. got=%v", exp, sunk)
+		}
+	}
+}
+
+func TestOptions_MaxErrors(t *testing.T) {
+	input := "let = 1; let = 2; let = 3; let = 4;"
+
+	p := NewWithOptions(lexer.New(input), Options{MaxErrors: 2})
+	p.ParseProgram()
+
+	if len(p.Errors()) != 2 {
+		t.Errorf("expected parsing to stop after 2 errors, got=%q", p.Errors())
+	}
+}
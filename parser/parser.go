@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"mcompiler/arena"
 	"mcompiler/ast"
 	"mcompiler/lexer"
 	"mcompiler/token"
@@ -9,52 +10,55 @@ import (
 )
 
 type Parser struct {
-	l              *lexer.Lexer
-	curToken       token.Token
-	peekToken      token.Token
-	errors         []string
-	prefixParseFns map[token.TokenType]prefixParseFn
-	infixParseFns  map[token.TokenType]infixParseFn
+	l                 *lexer.Lexer
+	curToken          token.Token
+	peekToken         token.Token
+	errors            []string
+	diagnostics       []Diagnostic
+	prefixParseFns    map[token.TokenType]prefixParseFn
+	infixParseFns     map[token.TokenType]infixParseFn
+	statementParseFns map[token.TokenType]statementParseFn
+	operators         map[token.TokenType]operator
+
+	arena   *arena.BestArena
+	symbols []string        // arena copies of interned literals, by symbol
+	stmts   []ast.Statement // scratch stacks, see collect
+	exprs   []ast.Expression
+	params  []ast.Identifier
+	types   []ast.TypeExpression
+	spans   []ast.Span
+
+	reuse *reuseState // set while reparsing
+
+	unbalanced int // where skipBody last failed, see there
+
+	opts       Options
+	depth      int
+	traceDepth int
+	maxDepth   int
+	halted     bool
 }
 
 type (
-	prefixParseFn func() ast.Expression
-	infixParseFn  func(ast.Expression) ast.Expression
+	prefixParseFn    func() ast.Expression
+	infixParseFn     func(ast.Expression) ast.Expression
+	statementParseFn func() ast.Statement
 )
 
-type Precedence int
-
-const (
-	_ = iota
-	LOWEST
-	LOW
-	LOWER
-	MID
-	HIGH
-	HIGHEST
-)
-
-func getPrecedence(tokenType token.TokenType) Precedence {
-	switch tokenType {
-	case token.FUNCTION:
-		return HIGH
-	case token.ASTERISK, token.SLASH:
-		return MID
-	case token.PLUS, token.MINUS:
-		return LOWER
-	case token.EQUAL, token.NOTEQUAL:
-		return LOW
-	case token.GT, token.LT:
-		return LOWER
-	default:
-		return LOWEST
-	}
+func New(l *lexer.Lexer) *Parser {
+	return NewWithOptions(l, Options{})
 }
 
-func New(l *lexer.Lexer) *Parser {
+func NewWithOptions(l *lexer.Lexer, opts Options) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:        l,
+		errors:   []string{},
+		opts:     opts,
+		arena:    opts.Arena,
+		maxDepth: opts.MaxDepth,
+	}
+	if p.maxDepth <= 0 {
+		p.maxDepth = DefaultMaxDepth
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -68,14 +72,24 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
-	p.registerInfix(token.PLUS, p.parseBinaryExpression)
-	p.registerInfix(token.MINUS, p.parseBinaryExpression)
-	p.registerInfix(token.ASTERISK, p.parseBinaryExpression)
-	p.registerInfix(token.SLASH, p.parseBinaryExpression)
-	p.registerInfix(token.EQUAL, p.parseBinaryExpression)
-	p.registerInfix(token.NOTEQUAL, p.parseBinaryExpression)
-	p.registerInfix(token.LT, p.parseBinaryExpression)
-	p.registerInfix(token.GT, p.parseBinaryExpression)
+	p.operators = make(map[token.TokenType]operator, len(operators))
+	for tokenType, op := range operators {
+		if opts.Disable&op.feature != 0 {
+			continue
+		}
+		p.registerOperator(tokenType, op)
+	}
+
+	p.statementParseFns = make(map[token.TokenType]statementParseFn)
+
+	if opts.Interner != nil {
+		l.UseInterner(opts.Interner)
+	}
+
+	syntax := &Syntax{p: p}
+	for _, ext := range opts.Extensions {
+		ext(syntax)
+	}
 
 	p.nextToken()
 	p.nextToken()
@@ -87,93 +101,295 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Diagnostics returns the errors reported so far together with their
+// positions. Errors returns the same messages without positions.
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+func (p *Parser) errorAt(pos token.Pos, msg string) {
+	if p.halted {
+		return
+	}
+	d := Diagnostic{Pos: pos, Msg: msg}
+	p.errors = append(p.errors, msg)
+	p.diagnostics = append(p.diagnostics, d)
+	if p.opts.Diagnostics != nil {
+		p.opts.Diagnostics(d)
+	}
+	if p.opts.MaxErrors > 0 && len(p.diagnostics) >= p.opts.MaxErrors {
+		p.halt()
+	}
+}
+
+// halt stops the parse: every following token reads as EOF, so the recursion
+// unwinds without reporting further errors.
+func (p *Parser) halt() {
+	if p.halted {
+		return
+	}
+	p.halted = true
+	p.nextToken()
+}
+
 func (p *Parser) nextToken() {
+	if p.halted {
+		p.curToken = token.Token{Type: token.EOF, Pos: p.peekToken.Pos}
+		p.peekToken = p.curToken
+		return
+	}
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.peekToken = p.own(p.l.NextToken())
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
-	stmts := []ast.Statement{}
+	base := len(p.stmts)
 	for p.curToken.Type != token.EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			stmts = append(stmts, stmt)
-		}
+		p.parseTopLevel()
+	}
+
+	return Alloc(p, ast.Program{
+		Statements: collect(p, &p.stmts, base),
+		Source:     p.source(),
+		Spans:      collect(p, &p.spans, 0),
+	})
+}
+
+// source is the lexer's input, kept in the arena along with the tree.
+func (p *Parser) source() string {
+	if p.arena != nil {
+		return p.arena.String(p.l.Input())
+	}
+	return p.l.Input()
+}
+
+// parseTopLevel parses one statement of a program, pushes it and its span,
+// and moves past it.
+func (p *Parser) parseTopLevel() {
+	start := p.curToken.Pos
+	stmt := p.parseStatement()
+	if stmt != nil {
+		p.stmts = append(p.stmts, stmt)
+		p.spans = append(p.spans, ast.Span{Start: start, End: stmt.End()})
+	}
+	p.nextToken()
+}
+
+// ParseExpression parses the whole input as a single expression, which may
+// end in a semicolon as an expression statement does.
+func (p *Parser) ParseExpression() ast.Expression {
+	expr := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
+	p.expectEnd("expression")
+	return expr
+}
 
-	return &ast.Program{
-		Statements: stmts,
+// ParseStatement parses the whole input as a single statement.
+func (p *Parser) ParseStatement() ast.Statement {
+	stmt := p.parseStatement()
+	p.expectEnd("statement")
+	return stmt
+}
+
+// ParseBlock parses the whole input as a single `{ ... }` block.
+func (p *Parser) ParseBlock() *ast.BlockStatement {
+	if !p.curTokenIs(token.LBRACE) {
+		p.currError(token.LBRACE)
+		if p.opts.Tolerant {
+			return Alloc(p, ast.BlockStatement{Token: p.curToken})
+		}
+		return nil
+	}
+	block, ok := p.parseBlockStatement().(*ast.BlockStatement)
+	if !ok {
+		return nil
+	}
+	if !p.curTokenIs(token.RBRACE) {
+		p.currError(token.RBRACE)
+		if !p.opts.Tolerant {
+			return nil
+		}
 	}
+	p.expectEnd("block")
+	return block
+}
+
+// ParseType parses the whole input as a single type.
+func (p *Parser) ParseType() ast.TypeExpression {
+	typ := p.parseType()
+	p.expectEnd("type")
+	return typ
+}
+
+// expectEnd reports anything left after a complete construct as trailing
+// garbage. The construct's last token is the current token.
+func (p *Parser) expectEnd(what string) {
+	if p.peekTokenIs(token.EOF) || p.curTokenIs(token.EOF) {
+		return
+	}
+	msg := fmt.Sprintf("unexpected %s %q after %s, expected %s", p.peekToken.Type, p.peekToken.Literal, what, token.EOF)
+	p.errorAt(p.peekToken.Pos, msg)
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.curToken
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		stmt = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
 	case token.LBRACE:
-		return p.parseBlockStatement()
+		stmt = p.parseBlockStatement()
 	case token.IF:
-		return p.parseIfStatement()
+		stmt = p.parseIfStatement()
 	default:
-		return p.parseExpressionStatement()
+		if fn, ok := p.statementParseFns[p.curToken.Type]; ok {
+			stmt = fn()
+		} else {
+			stmt = p.parseExpressionStatement()
+		}
 	}
+	return p.orMissingStatement(stmt, start)
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
-	stmt := &ast.FunctionExpression{Token: p.curToken}
+	defer p.untrace(p.trace("parseFunctionLiteral"))
+	stmt := Alloc(p, ast.FunctionExpression{Token: p.curToken})
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		if !p.opts.Tolerant {
+			return nil
+		}
+		stmt.Body = p.missingStatement(p.peekToken)
+		return stmt
 	}
 
-	stmt.Parameters = []ast.Identifier{}
-
+	base, typesBase := len(p.params), len(p.types)
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
 	} else {
 		p.nextToken()
-		stmt.Parameters = append(stmt.Parameters, ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		ok := p.parseParameter()
 
-		for p.peekTokenIs(token.COMMA) {
+		for ok && p.peekTokenIs(token.COMMA) {
 			p.nextToken()
 			p.nextToken()
-			stmt.Parameters = append(stmt.Parameters, ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+			ok = p.parseParameter()
 		}
 
-		if !p.expectPeek(token.RPAREN) {
+		if !ok || !p.expectPeek(token.RPAREN) && !p.opts.Tolerant {
+			p.params, p.types = p.params[:base], p.types[:typesBase]
 			return nil
 		}
 	}
+	stmt.Parameters = collect(p, &p.params, base)
+	for _, typ := range p.types[typesBase:] {
+		if typ != nil {
+			stmt.ParameterTypes = collect(p, &p.types, typesBase)
+			break
+		}
+	}
+	p.types = p.types[:typesBase]
+
+	if p.peekTokenIs(token.ARROW) && p.opts.Disable&ArrowFunctions == 0 {
+		p.nextToken()
+		return p.parseArrowBody(stmt)
+	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		if !p.opts.Tolerant {
+			return nil
+		}
+		stmt.Body = p.missingStatement(p.peekToken)
+		return stmt
 	}
 
+	if p.reuse != nil {
+		if body := p.reuse.body(p); body != nil {
+			stmt.Body = body
+			return stmt
+		}
+	}
+	if p.opts.LazyBodies {
+		lbrace := p.curToken
+		if p.skipBody(stmt) {
+			return stmt
+		}
+		p.rewind(lbrace)
+	}
 	stmt.Body = p.parseBlockStatement()
 	return stmt
 }
 
+// parseParameter pushes the parameter at the current token and its
+// annotation, or nil, onto the scratch stacks. It reports false if the
+// annotation's type is missing.
+func (p *Parser) parseParameter() bool {
+	p.params = append(p.params, p.identifier())
+	typ, ok := p.parseAnnotation()
+	p.types = append(p.types, typ)
+	return ok
+}
+
+// parseArrowBody desugars `=> expr` into `{ return expr; }`. The body is parsed
+// above pipeline precedence so `xs |> map(fn(x) => x * 2) |> sum` and
+// `xs |> fn(x) => x |> f` keep the pipe outside the lambda.
+func (p *Parser) parseArrowBody(fn *ast.FunctionExpression) ast.Expression {
+	defer p.untrace(p.trace("parseArrowBody"))
+	arrow := p.curToken
+	p.nextToken()
+
+	value := p.parseExpression(PIPELINE)
+	if value == nil {
+		return nil
+	}
+
+	base := len(p.stmts)
+	p.stmts = append(p.stmts, Alloc(p, ast.ReturnStatement{Token: arrow, Value: value}))
+
+	fn.Arrow = true
+	fn.Body = Alloc(p, ast.BlockStatement{
+		Token:      arrow,
+		Statements: collect(p, &p.stmts, base),
+	})
+	return fn
+}
+
 func (p *Parser) parseIfStatement() ast.Statement {
-	stmt := &ast.IfStatement{Token: p.curToken}
+	defer p.untrace(p.trace("parseIfStatement"))
+	stmt := Alloc(p, ast.IfStatement{Token: p.curToken})
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		if !p.opts.Tolerant {
+			return nil
+		}
+		stmt.Condition = p.missingExpression(p.peekToken)
+		stmt.Consequence = p.missingStatement(p.peekToken)
+		return stmt
 	}
 	p.nextToken()
 	stmt.Condition = p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(token.RPAREN) && !p.opts.Tolerant {
 		return nil
 	}
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		if !p.opts.Tolerant {
+			return nil
+		}
+		stmt.Consequence = p.missingStatement(p.peekToken)
+		return stmt
 	}
 	stmt.Consequence = p.parseBlockStatement()
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 		if !p.expectPeek(token.LBRACE) {
-			return nil
+			if !p.opts.Tolerant {
+				return nil
+			}
+			stmt.Alternative = p.missingStatement(p.peekToken)
+			return stmt
 		}
 		stmt.Alternative = p.parseBlockStatement()
 	}
@@ -181,21 +397,32 @@ func (p *Parser) parseIfStatement() ast.Statement {
 }
 
 func (p *Parser) parseBlockStatement() ast.Statement {
-	stmt := &ast.BlockStatement{Token: p.curToken}
-	stmt.Statements = []ast.Statement{}
+	defer p.untrace(p.trace("parseBlockStatement"))
+	if !p.enter() {
+		return p.missingStatement(p.curToken)
+	}
+	defer p.leave()
+
+	stmt := Alloc(p, ast.BlockStatement{Token: p.curToken})
 
 	p.nextToken() // skip the current LBRACE
 
+	base := len(p.stmts)
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt.Statements = append(stmt.Statements, p.parseStatement())
+		p.stmts = append(p.stmts, p.parseStatement())
 		p.nextToken()
 	}
+	stmt.Statements = collect(p, &p.stmts, base)
+	if p.curTokenIs(token.RBRACE) {
+		stmt.Rbrace = p.curToken.Pos
+	}
 
 	return stmt
 }
 
 func (p *Parser) parseExpressionStatement() ast.Statement {
-	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	defer p.untrace(p.trace("parseExpressionStatement"))
+	stmt := Alloc(p, ast.ExpressionStatement{Token: p.curToken})
 	stmt.Expression = p.parseExpression(LOWEST)
 
 	if p.peekTokenIs(token.SEMICOLON) {
@@ -206,16 +433,28 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 }
 
 func (p *Parser) parseLetStatement() ast.Statement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+	defer p.untrace(p.trace("parseLetStatement"))
+	stmt := Alloc(p, ast.LetStatement{Token: p.curToken})
 
-	if !p.expectPeek(token.IDENT) {
+	if p.expectPeek(token.IDENT) {
+		stmt.Name = Alloc(p, p.identifier())
+	} else if p.opts.Tolerant {
+		stmt.Name = p.missingIdentifier(p.peekToken)
+	} else {
 		return nil
 	}
-
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	typ, ok := p.parseAnnotation()
+	if !ok {
+		return nil
+	}
+	stmt.Type = typ
 
 	if !p.expectPeek(token.ASSIGN) {
-		return nil
+		if !p.opts.Tolerant {
+			return nil
+		}
+		stmt.Value = p.missingExpression(p.peekToken)
+		return stmt
 	}
 	p.nextToken()
 
@@ -229,7 +468,8 @@ func (p *Parser) parseLetStatement() ast.Statement {
 }
 
 func (p *Parser) parseReturnStatement() ast.Statement {
-	stmt := &ast.ReturnStatement{Token: p.curToken}
+	defer p.untrace(p.trace("parseReturnStatement"))
+	stmt := Alloc(p, ast.ReturnStatement{Token: p.curToken})
 	p.nextToken() //advance token for skipping return token
 
 	stmt.Value = p.parseExpression(LOWEST)
@@ -250,46 +490,55 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) parseExpression(precedence Precedence) ast.Expression {
+	defer p.untrace(p.trace("parseExpression"))
+	if p.opts.Trace != nil {
+		p.tracef("precedence %s", precedence)
+	}
+	start := p.curToken
+	if !p.enter() {
+		return p.missingExpression(start)
+	}
+	defer p.leave()
 
 	prefixFn := p.prefixParseFns[p.curToken.Type]
 	if prefixFn == nil {
 		p.prefixFnError(p.curToken.Type)
-		return nil
+		return p.missingExpression(start)
 	}
 
-	left := prefixFn()
+	if p.opts.Trace != nil {
+		p.tracef("prefix %s for %s", funcName(prefixFn), p.curToken.Type)
+	}
+	left := p.orMissing(prefixFn(), start)
 
 	for precedence < p.peekPrecedence() && !p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 
-		infixFn := p.infixParseFns[p.curToken.Type]
+		op := p.curToken
+		infixFn := p.infixParseFns[op.Type]
 		if infixFn == nil {
-			p.infixFnError(p.curToken.Type)
+			p.infixFnError(op.Type)
+			if p.opts.Tolerant {
+				return left
+			}
 			return nil
 		}
-		left = infixFn(left)
+		if p.opts.Trace != nil {
+			opr := p.operators[op.Type]
+			p.tracef("infix %s for %s at %s, %s-associative", funcName(infixFn), op.Type, opr.precedence, opr.assoc)
+		}
+		left = p.orMissing(infixFn(left), op)
 	}
 
 	return left
 }
 
-func (p *Parser) parseBinaryExpression(left ast.Expression) ast.Expression {
-	expression := &ast.BinaryExpression{
-		Token: p.curToken,
-		Left:  left,
-	}
-	precedence := p.currPrecedence()
-	p.nextToken()
-	expression.Right = p.parseExpression(precedence)
-	return expression
-}
-
 func (p *Parser) currPrecedence() Precedence {
-	return getPrecedence(p.curToken.Type)
+	return p.precedence(p.curToken.Type)
 }
 
 func (p *Parser) peekPrecedence() Precedence {
-	return getPrecedence(p.peekToken.Type)
+	return p.precedence(p.peekToken.Type)
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -322,38 +571,46 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken.Pos, msg)
 }
 
 func (p *Parser) currError(t token.TokenType) {
 	msg := fmt.Sprintf("expected current token to be %s, got %s instead", t, p.curToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken.Pos, msg)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
 	if p.peekTokenIs(token.LPAREN) {
 		return p.parseFunctionInvokeExpression()
 	}
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return Alloc(p, p.identifier())
+}
+
+func (p *Parser) identifier() ast.Identifier {
+	return ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Symbol: p.curToken.Sym}
 }
 
 func (p *Parser) parseFunctionInvokeExpression() ast.Expression {
-	expr := &ast.FunctionInvokeExpression{Token: p.curToken}
-	expr.Arguments = []ast.Expression{}
+	defer p.untrace(p.trace("parseFunctionInvokeExpression"))
+	expr := Alloc(p, ast.FunctionInvokeExpression{Token: p.curToken})
 
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 
-	for !p.peekTokenIs(token.RPAREN) {
+	base := len(p.exprs)
+	for !p.peekTokenIs(token.RPAREN) && !p.peekTokenIs(token.EOF) {
 		p.nextToken()
 		if p.curTokenIs(token.COMMA) {
 			p.nextToken()
 		}
-		expr.Arguments = append(expr.Arguments, p.parseExpression(LOWEST))
+		p.exprs = append(p.exprs, p.parseExpression(LOWEST))
 	}
+	expr.Arguments = collect(p, &p.exprs, base)
 
-	if !p.expectPeek(token.RPAREN) {
+	if p.expectPeek(token.RPAREN) {
+		expr.Rparen = p.curToken.Pos
+	} else if !p.opts.Tolerant {
 		return nil
 	}
 
@@ -366,24 +623,26 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 		p.currError(token.INT)
 		return nil
 	}
-	return &ast.IntegerLiteral{Token: p.curToken, Value: value}
+	return Alloc(p, ast.IntegerLiteral{Token: p.curToken, Value: value})
 }
 
 func (p *Parser) parseBooleanLiteral() ast.Expression {
-	return &ast.BooleanLiteral{Token: p.curToken, Value: p.curToken.Type == token.TRUE}
+	return Alloc(p, ast.BooleanLiteral{Token: p.curToken, Value: p.curToken.Type == token.TRUE})
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.untrace(p.trace("parsePrefixExpression"))
 	tok := p.curToken
 	p.nextToken()
-	right := p.parseExpression(HIGHEST)
-	return &ast.UnaryExpression{Token: tok, Right: right}
+	right := p.parseExpression(PREFIX)
+	return Alloc(p, ast.UnaryExpression{Token: tok, Right: right})
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("parseGroupedExpression"))
 	p.nextToken()
 	left := p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(token.RPAREN) && !p.opts.Tolerant {
 		return nil
 	}
 	return left
@@ -391,10 +650,10 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 
 func (p *Parser) prefixFnError(tokenType token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", tokenType)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken.Pos, msg)
 }
 
 func (p *Parser) infixFnError(tokenType token.TokenType) {
 	msg := fmt.Sprintf("no infix parse function for %s found", tokenType)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken.Pos, msg)
 }
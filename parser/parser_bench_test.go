@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/arena"
+	"mcompiler/lexer"
+	"strings"
+	"testing"
+)
+
+// name spells i in letters; identifiers cannot contain digits.
+func name(prefix string, i int) string {
+	out := []byte(prefix)
+	for {
+		out = append(out, byte('a'+i%26))
+		i /= 26
+		if i == 0 {
+			return string(out)
+		}
+	}
+}
+
+func generateSource(functions int) string {
+	var sb strings.Builder
+	for i := 0; i < functions; i++ {
+		fmt.Fprintf(&sb, "let %s = fn(a, b, c) {\n", name("fun", i))
+		fmt.Fprintf(&sb, "\tlet x = a * %d + b - (c / 2);\n", i)
+		sb.WriteString("\tif (x > b) { return add(a, b * 2, c); } else { return -x ** 2; }\n")
+		sb.WriteString("\tlet g = fn(y) => y * x |> double;\n")
+		sb.WriteString("\treturn g(x) == !true;\n")
+		sb.WriteString("};\n")
+	}
+	return sb.String()
+}
+
+var largeSource = generateSource(2000)
+
+func BenchmarkParseProgram_Heap(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(largeSource)))
+	for i := 0; i < b.N; i++ {
+		p := New(lexer.New(largeSource))
+		p.ParseProgram()
+	}
+}
+
+func BenchmarkParseProgram_Arena(b *testing.B) {
+	a := arena.NewBestArena()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(largeSource)))
+	for i := 0; i < b.N; i++ {
+		a.Reset()
+		p := NewWithOptions(lexer.New(largeSource), Options{Arena: a})
+		p.ParseProgram()
+	}
+}
+
+func BenchmarkParseProgram_Lazy(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(largeSource)))
+	for i := 0; i < b.N; i++ {
+		p := NewWithOptions(lexer.New(largeSource), Options{LazyBodies: true})
+		p.ParseProgram()
+	}
+}
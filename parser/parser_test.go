@@ -254,3 +254,129 @@ func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	}
 	return true
 }
+
+func TestParser_ParsePipeAndArrow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs |> sum;", "(xs |> sum);"},
+		{"xs |> map(fn(x) => x * 2) |> sum;", "((xs |> map(fn(x) => (x * 2))) |> sum);"},
+		{"a + b |> f;", "((a + b) |> f);"},
+		{"a == b |> f;", "((a == b) |> f);"},
+		{"xs |> fn(x) => x + 1 |> f;", "((xs |> fn(x) => (x + 1)) |> f);"},
+		{"let add = fn(a, b) => a + b;", "let add = fn(a, b) => (a + b);"},
+		{"fn() => 1;", "fn() => 1;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) > 0 {
+			t.Errorf("errors during parsing %q: %s", tt.input, p.Errors())
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		if program.Statements[0].String() != tt.expected {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expected, program.Statements[0].String())
+		}
+	}
+}
+
+func TestParser_ArrowDesugarsToReturn(t *testing.T) {
+	l := lexer.New("fn(x) => x * 2;")
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	fn, ok := stmt.Expression.(*ast.FunctionExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.FunctionExpression. got=%T", stmt.Expression)
+	}
+	if !fn.Arrow {
+		t.Errorf("fn.Arrow is false")
+	}
+
+	body, ok := fn.Body.(*ast.BlockStatement)
+	if !ok || len(body.Statements) != 1 {
+		t.Fatalf("fn.Body is not a single statement block. got=%s", fn.Body)
+	}
+	ret, ok := body.Statements[0].(*ast.ReturnStatement)
+	if !ok {
+		t.Fatalf("body statement is not *ast.ReturnStatement. got=%T", body.Statements[0])
+	}
+	if ret.TokenLiteral() != "=>" {
+		t.Errorf("return token wrong. expected=%q, got=%q", "=>", ret.TokenLiteral())
+	}
+	if ret.Value.String() != "(x * 2)" {
+		t.Errorf("return value wrong. expected=%q, got=%q", "(x * 2)", ret.Value.String())
+	}
+}
+
+func TestParser_EntryPoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		parse    func(p *Parser) ast.Node
+		expected string
+		err      string
+	}{
+		{"expression", "a + b * c", func(p *Parser) ast.Node { return p.ParseExpression() }, "(a + (b * c))", ""},
+		{"expression semicolon", "a + b;", func(p *Parser) ast.Node { return p.ParseExpression() }, "(a + b)", ""},
+		{"expression two semicolons", "a + b;;", func(p *Parser) ast.Node { return p.ParseExpression() }, "",
+			`unexpected ; ";" after expression, expected EOF`},
+		{"expression trailing", "a + b c", func(p *Parser) ast.Node { return p.ParseExpression() }, "",
+			`unexpected IDENT "c" after expression, expected EOF`},
+		{"statement", "let x = 5;", func(p *Parser) ast.Node { return p.ParseStatement() }, "let x = 5;", ""},
+		{"statement trailing", "let x = 5; let y = 6;", func(p *Parser) ast.Node { return p.ParseStatement() }, "",
+			`unexpected LET "let" after statement, expected EOF`},
+		{"block", "{ x; y; }", func(p *Parser) ast.Node { return p.ParseBlock() }, "{x;y;}", ""},
+		{"block unterminated", "{ x; y;", func(p *Parser) ast.Node { return p.ParseBlock() }, "",
+			"expected current token to be }, got EOF instead"},
+		{"block trailing", "{ x; } y", func(p *Parser) ast.Node { return p.ParseBlock() }, "",
+			`unexpected IDENT "y" after block, expected EOF`},
+		{"named type", "int", func(p *Parser) ast.Node { return p.ParseType() }, "int", ""},
+		{"function type", "fn(int, fn() => bool) => int", func(p *Parser) ast.Node { return p.ParseType() },
+			"fn(int, fn() => bool) => int", ""},
+		{"type trailing", "int int", func(p *Parser) ast.Node { return p.ParseType() }, "",
+			`unexpected IDENT "int" after type, expected EOF`},
+		{"type arguments", "hash(string, array(int))", func(p *Parser) ast.Node { return p.ParseType() },
+			"hash(string, array(int))", ""},
+		{"type arguments unterminated", "array(int", func(p *Parser) ast.Node { return p.ParseType() }, "",
+			"expected next token to be ), got EOF instead"},
+		{"annotated let", "let n: int = 5;", func(p *Parser) ast.Node { return p.ParseStatement() }, "let n: int = 5;", ""},
+		{"annotated parameters", "fn(x: int, y) => x", func(p *Parser) ast.Node { return p.ParseExpression() },
+			"fn(x: int, y) => x", ""},
+		{"annotation missing", "let x: = 5;", func(p *Parser) ast.Node { return p.ParseStatement() }, "",
+			"expected type, got = instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		node := tt.parse(p)
+
+		if tt.err != "" {
+			if len(p.Errors()) == 0 || p.Errors()[0] != tt.err {
+				t.Errorf("%s: wrong errors. expected=%q, got=%q", tt.name, tt.err, p.Errors())
+			}
+			continue
+		}
+		if len(p.Errors()) > 0 {
+			t.Errorf("%s: errors during parsing: %s", tt.name, p.Errors())
+			continue
+		}
+		if node.String() != tt.expected {
+			t.Errorf("%s: wrong string. expected=%q, got=%q", tt.name, tt.expected, node.String())
+		}
+	}
+}
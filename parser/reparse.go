@@ -0,0 +1,389 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/arena"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/token"
+	"sort"
+	"strings"
+)
+
+// Edit replaces Len bytes of a program's source at Offset with Text.
+type Edit struct {
+	Offset int
+	Len    int
+	Text   string
+}
+
+// Changes reports what Reparse rebuilt. Replaced are the old top-level
+// statements that are no longer in the program and Added the new ones that
+// took their place; every other statement was reused. ReusedBodies are the
+// function bodies moved from Replaced statements into Added ones without
+// being parsed again.
+type Changes struct {
+	Replaced     []ast.Statement
+	Added        []ast.Statement
+	ReusedBodies []*ast.BlockStatement
+	Diagnostics  []Diagnostic
+}
+
+// Reparse applies edit to the source of old and parses only the damaged
+// region: top-level statements before it are kept as they are, and parsing
+// stops as soon as it reaches the start of an old statement that lies wholly
+// after the edit. Function bodies whose text is unchanged are reused as well.
+//
+// Reused nodes are moved, not copied: their positions are shifted in place,
+// so old must not be used afterwards. Positions inside nodes built by parser
+// extensions are not shifted.
+func Reparse(old *ast.Program, edit Edit, opts Options) (*ast.Program, *Changes, error) {
+	if edit.Offset < 0 || edit.Len < 0 || edit.Offset+edit.Len > len(old.Source) {
+		return nil, nil, fmt.Errorf("parser: edit [%d, %d) is outside the source of length %d",
+			edit.Offset, edit.Offset+edit.Len, len(old.Source))
+	}
+	if len(old.Spans) != len(old.Statements) {
+		return nil, nil, fmt.Errorf("parser: program has no statement spans to reparse")
+	}
+
+	src := old.Source[:edit.Offset] + edit.Text + old.Source[edit.Offset+edit.Len:]
+	r := &reuseState{
+		old:    old,
+		src:    src,
+		edit:   edit,
+		shift:  newShift(old.Source, src, edit),
+		bodies: make(map[int]*ast.BlockStatement),
+	}
+
+	// The statement before the first one touching the edit is parsed again
+	// too: where it ended depended on the token after it, which the edit may
+	// have changed.
+	first := sort.Search(len(old.Spans), func(i int) bool {
+		return old.Spans[i].End.Offset >= edit.Offset
+	})
+	start := token.Pos{Offset: 0, Line: 1, Column: 1}
+	if first > 0 {
+		first--
+		start = old.Spans[first].Start
+	}
+	r.collected = first
+
+	l := lexer.New(src)
+	l.Seek(start)
+	p := NewWithOptions(l, opts)
+	p.reuse = r
+	r.shift.arena = p.arena
+
+	p.stmts = append(p.stmts, old.Statements[:first]...)
+	p.spans = append(p.spans, old.Spans[:first]...)
+
+	added := len(p.stmts)
+	next, resynced := first, false
+	for !p.curTokenIs(token.EOF) && !resynced {
+		next, resynced = r.resync(next, p.curToken.Pos.Offset)
+		if !resynced {
+			p.parseTopLevel()
+		}
+	}
+	if !resynced {
+		next = len(old.Statements)
+	}
+
+	changes := &Changes{
+		Replaced:     old.Statements[first:next],
+		Added:        append([]ast.Statement(nil), p.stmts[added:]...),
+		ReusedBodies: r.reused,
+		Diagnostics:  p.Diagnostics(),
+	}
+
+	for i := next; i < len(old.Statements); i++ {
+		r.shift.node(old.Statements[i])
+		p.stmts = append(p.stmts, old.Statements[i])
+		p.spans = append(p.spans, ast.Span{
+			Start: r.shift.pos(old.Spans[i].Start),
+			End:   r.shift.pos(old.Spans[i].End),
+		})
+	}
+
+	program := Alloc(p, ast.Program{
+		Statements: collect(p, &p.stmts, 0),
+		Source:     p.source(),
+		Spans:      collect(p, &p.spans, 0),
+	})
+	return program, changes, nil
+}
+
+type reuseState struct {
+	old    *ast.Program
+	src    string
+	edit   Edit
+	shift  shift
+	reused []*ast.BlockStatement
+
+	// bodies holds the function bodies of the old statements before
+	// collected, by the offset of their opening brace.
+	bodies    map[int]*ast.BlockStatement
+	collected int
+}
+
+// resync reports whether a statement starting at offset of the new source
+// starts an old statement that lies wholly after the edit; from there on the
+// old statements can be reused. next is the first old statement that could
+// still match.
+func (r *reuseState) resync(next, offset int) (int, bool) {
+	if offset < r.edit.Offset+len(r.edit.Text) {
+		return next, false
+	}
+	spans := r.old.Spans
+	for next < len(spans) &&
+		(spans[next].Start.Offset < r.shift.from || spans[next].Start.Offset+r.shift.delta < offset) {
+		next++
+	}
+	return next, next < len(spans) && spans[next].Start.Offset+r.shift.delta == offset
+}
+
+// oldOffset maps an offset of the new source to the old one, if the byte
+// there was not written by the edit.
+func (r *reuseState) oldOffset(offset int) (int, bool) {
+	switch {
+	case offset < r.edit.Offset:
+		return offset, true
+	case offset >= r.edit.Offset+len(r.edit.Text):
+		return offset - r.shift.delta, true
+	default:
+		return 0, false
+	}
+}
+
+// body reuses the old function body starting at the current `{` if its text
+// is unchanged, and moves the parser past its closing brace.
+func (r *reuseState) body(p *Parser) *ast.BlockStatement {
+	if p.halted {
+		return nil
+	}
+	offset := p.curToken.Pos.Offset
+	oldOffset, ok := r.oldOffset(offset)
+	if !ok {
+		return nil
+	}
+	r.collect(oldOffset)
+
+	body := r.bodies[oldOffset]
+	if body == nil {
+		return nil
+	}
+	end := body.Rbrace.Offset + 1
+	before := end <= r.edit.Offset
+	after := oldOffset >= r.shift.from
+	if !before && !after {
+		return nil
+	}
+	n := end - oldOffset
+	if offset+n > len(r.src) || r.src[offset:offset+n] != r.old.Source[oldOffset:end] {
+		return nil
+	}
+
+	delete(r.bodies, oldOffset)
+	if after {
+		r.shift.node(body)
+	}
+	r.reused = append(r.reused, body)
+
+	rbrace := body.Rbrace
+	p.l.Seek(token.Pos{Offset: rbrace.Offset + 1, Line: rbrace.Line, Column: rbrace.Column + 1})
+	p.curToken = token.Token{Type: token.RBRACE, Literal: "}", Pos: rbrace}
+	p.peekToken = p.own(p.l.NextToken())
+	return body
+}
+
+// collect gathers the function bodies of the old statements starting at or
+// before offset.
+func (r *reuseState) collect(offset int) {
+	for r.collected < len(r.old.Statements) && r.old.Spans[r.collected].Start.Offset <= offset {
+		collectBodies(r.old.Statements[r.collected], r.bodies)
+		r.collected++
+	}
+}
+
+func collectBodies(node ast.Node, bodies map[int]*ast.BlockStatement) {
+	switch n := node.(type) {
+	case *ast.ExpressionStatement:
+		collectBodies(n.Expression, bodies)
+	case *ast.LetStatement:
+		collectBodies(n.Value, bodies)
+	case *ast.ReturnStatement:
+		collectBodies(n.Value, bodies)
+	case *ast.BlockStatement:
+		for _, stmt := range n.Statements {
+			collectBodies(stmt, bodies)
+		}
+	case *ast.IfStatement:
+		collectBodies(n.Condition, bodies)
+		collectBodies(n.Consequence, bodies)
+		collectBodies(n.Alternative, bodies)
+	case *ast.UnaryExpression:
+		collectBodies(n.Right, bodies)
+	case *ast.BinaryExpression:
+		collectBodies(n.Left, bodies)
+		collectBodies(n.Right, bodies)
+	case *ast.PipeExpression:
+		collectBodies(n.Left, bodies)
+		collectBodies(n.Right, bodies)
+	case *ast.AssignExpression:
+		collectBodies(n.Value, bodies)
+	case *ast.FunctionInvokeExpression:
+		for _, arg := range n.Arguments {
+			collectBodies(arg, bodies)
+		}
+	case *ast.FunctionExpression:
+		if body, ok := n.Body.(*ast.BlockStatement); ok && !n.Arrow && body.Rbrace.Line != 0 {
+			bodies[body.Token.Pos.Offset] = body
+		}
+		collectBodies(n.Body, bodies)
+	}
+}
+
+// shift moves positions at or after the end of an edit to where that text
+// sits in the edited source.
+type shift struct {
+	from  int // old offset of the end of the edit
+	delta int
+	line  int // old line of the end of the edit
+	dLine int
+	dCol  int // column change on that line
+
+	arena *arena.BestArena
+}
+
+func newShift(oldSrc, newSrc string, edit Edit) shift {
+	from := edit.Offset + edit.Len
+	oldLine, oldCol := lineColumn(oldSrc, from)
+	newLine, newCol := lineColumn(newSrc, edit.Offset+len(edit.Text))
+	return shift{
+		from:  from,
+		delta: len(edit.Text) - edit.Len,
+		line:  oldLine,
+		dLine: newLine - oldLine,
+		dCol:  newCol - oldCol,
+	}
+}
+
+func lineColumn(src string, offset int) (int, int) {
+	line := 1 + strings.Count(src[:offset], "\n")
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	return line, offset - lineStart + 1
+}
+
+func (s *shift) pos(pos token.Pos) token.Pos {
+	if pos.Line == 0 || pos.Offset < s.from {
+		return pos
+	}
+	if pos.Line == s.line {
+		pos.Column += s.dCol
+	}
+	pos.Line += s.dLine
+	pos.Offset += s.delta
+	return pos
+}
+
+func (s *shift) token(tok *token.Token) {
+	tok.Pos = s.pos(tok.Pos)
+}
+
+func (s *shift) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.ExpressionStatement:
+		s.token(&n.Token)
+		s.node(n.Expression)
+	case *ast.LetStatement:
+		s.token(&n.Token)
+		s.node(n.Name)
+		s.node(n.Type)
+		s.node(n.Value)
+	case *ast.ReturnStatement:
+		s.token(&n.Token)
+		s.node(n.Value)
+	case *ast.BlockStatement:
+		s.token(&n.Token)
+		n.Rbrace = s.pos(n.Rbrace)
+		for _, stmt := range n.Statements {
+			s.node(stmt)
+		}
+	case *ast.IfStatement:
+		s.token(&n.Token)
+		s.node(n.Condition)
+		s.node(n.Consequence)
+		s.node(n.Alternative)
+	case *ast.Identifier:
+		s.token(&n.Token)
+	case *ast.IntegerLiteral:
+		s.token(&n.Token)
+	case *ast.BooleanLiteral:
+		s.token(&n.Token)
+	case *ast.UnaryExpression:
+		s.token(&n.Token)
+		s.node(n.Right)
+	case *ast.BinaryExpression:
+		s.token(&n.Token)
+		s.node(n.Left)
+		s.node(n.Right)
+	case *ast.PipeExpression:
+		s.token(&n.Token)
+		s.node(n.Left)
+		s.node(n.Right)
+	case *ast.AssignExpression:
+		s.token(&n.Token)
+		s.node(n.Name)
+		s.node(n.Value)
+	case *ast.FunctionInvokeExpression:
+		s.token(&n.Token)
+		n.Rparen = s.pos(n.Rparen)
+		for _, arg := range n.Arguments {
+			s.node(arg)
+		}
+	case *ast.MissingExpression:
+		s.token(&n.Token)
+		n.Span = ast.Span{Start: s.pos(n.Span.Start), End: s.pos(n.Span.End)}
+	case *ast.MissingStatement:
+		s.token(&n.Token)
+		n.Span = ast.Span{Start: s.pos(n.Span.Start), End: s.pos(n.Span.End)}
+	case *ast.NamedType:
+		s.token(&n.Token)
+		n.Rparen = s.pos(n.Rparen)
+		for _, arg := range n.Arguments {
+			s.node(arg)
+		}
+	case *ast.FunctionType:
+		s.token(&n.Token)
+		for _, param := range n.Parameters {
+			s.node(param)
+		}
+		s.node(n.Result)
+	case *ast.MissingType:
+		s.token(&n.Token)
+		n.Span = ast.Span{Start: s.pos(n.Span.Start), End: s.pos(n.Span.End)}
+	case *ast.FunctionExpression:
+		s.token(&n.Token)
+		for i := range n.Parameters {
+			s.node(&n.Parameters[i])
+		}
+		for _, typ := range n.ParameterTypes {
+			s.node(typ)
+		}
+		s.node(n.Body)
+		if n.Lazy != nil {
+			n.LazyRbrace = s.pos(n.LazyRbrace)
+			// A skipped body is parsed from the old source, so it is
+			// shifted once it has been parsed.
+			parse, s := n.Lazy, *s
+			n.Lazy = func() ast.Statement {
+				body := parse()
+				s.node(body)
+				return body
+			}
+			if s.arena != nil {
+				s.arena.Keep(n.Lazy)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"mcompiler/ast"
+	"mcompiler/ast/compact"
+	"mcompiler/lexer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const reparseSource = `let add = fn(a, b) {
+  return a + b;
+};
+let one = 1;
+let twice = fn(f, x) { f(f(x)) };
+add(one, 2);
+let sq = fn(x) => x * x;
+`
+
+func TestReparse(t *testing.T) {
+	tests := []struct {
+		name     string
+		find     string // the edit replaces the first occurrence of find
+		text     string
+		replaced int
+		added    int
+		bodies   int
+	}{
+		{"edit inside body", "a + b", "a - b", 1, 1, 0},
+		{"rename let", "let one", "let uno", 2, 2, 1},
+		{"insert lines", "add(one", "let two = 2;\nlet three = 3;\nadd(one", 2, 4, 1},
+		{"delete statement", "let one = 1;\n", "", 2, 1, 1},
+		{"merge statements", "1;\nlet twice = ", "1 + ", 3, 2, 2},
+		{"edit last statement", "x * x", "x ** 2", 2, 2, 0},
+		{"edit first token", "let add", "let plus", 1, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset := strings.Index(reparseSource, tt.find)
+			edit := Edit{Offset: offset, Len: len(tt.find), Text: tt.text}
+			newSource := reparseSource[:offset] + tt.text + reparseSource[offset+len(tt.find):]
+
+			old := New(lexer.New(reparseSource)).ParseProgram()
+			oldStmts := append([]ast.Statement(nil), old.Statements...)
+
+			program, changes, err := Reparse(old, edit, Options{})
+			if err != nil {
+				t.Fatalf("Reparse: %v", err)
+			}
+			full := New(lexer.New(newSource))
+			expected := full.ParseProgram()
+			if len(full.Errors()) > 0 {
+				t.Fatalf("errors during parsing: %s", full.Errors())
+			}
+
+			if program.Source != newSource {
+				t.Fatalf("Source = %q, want %q", program.Source, newSource)
+			}
+			assertSameTree(t, program, expected)
+			if !reflect.DeepEqual(program.Spans, expected.Spans) {
+				t.Errorf("Spans = %v, want %v", program.Spans, expected.Spans)
+			}
+
+			if len(changes.Replaced) != tt.replaced || len(changes.Added) != tt.added {
+				t.Errorf("replaced %d, added %d statements, want %d and %d",
+					len(changes.Replaced), len(changes.Added), tt.replaced, tt.added)
+			}
+			if len(changes.ReusedBodies) != tt.bodies {
+				t.Errorf("reused %d bodies, want %d", len(changes.ReusedBodies), tt.bodies)
+			}
+
+			// Every statement is either new or one of the old ones, and
+			// every old one is either kept or replaced.
+			kept := 0
+			for _, stmt := range program.Statements {
+				if containsStatement(oldStmts, stmt) {
+					kept++
+				} else if !containsStatement(changes.Added, stmt) {
+					t.Errorf("statement %q is neither reused nor added", stmt)
+				}
+			}
+			if kept+len(changes.Replaced) != len(oldStmts) {
+				t.Errorf("kept %d and replaced %d of %d statements",
+					kept, len(changes.Replaced), len(oldStmts))
+			}
+		})
+	}
+}
+
+func TestReparse_Repeated(t *testing.T) {
+	source := reparseSource
+	program := New(lexer.New(source)).ParseProgram()
+
+	offset, prev := strings.Index(source, "1;"), "1"
+	for i, text := range []string{"9", "99", "", "(1 + 2)", "x"} {
+		edit := Edit{Offset: offset, Len: len(prev), Text: text}
+		source = source[:offset] + text + source[offset+len(prev):]
+		prev = text
+
+		var err error
+		program, _, err = Reparse(program, edit, Options{})
+		if err != nil {
+			t.Fatalf("edit %d: %v", i, err)
+		}
+		assertSameTree(t, program, New(lexer.New(source)).ParseProgram())
+		if t.Failed() {
+			t.Fatalf("edit %d: tree differs from a full parse of %q", i, source)
+		}
+	}
+}
+
+func TestReparse_Errors(t *testing.T) {
+	program := New(lexer.New("let a = 1;")).ParseProgram()
+
+	for _, edit := range []Edit{
+		{Offset: -1},
+		{Offset: 5, Len: 6},
+		{Offset: 11},
+	} {
+		if _, _, err := Reparse(program, edit, Options{}); err == nil {
+			t.Errorf("Reparse(%+v) did not fail", edit)
+		}
+	}
+
+	if _, _, err := Reparse(&ast.Program{Statements: program.Statements}, Edit{}, Options{}); err == nil {
+		t.Errorf("Reparse without spans did not fail")
+	}
+}
+
+func TestReparse_Diagnostics(t *testing.T) {
+	program := New(lexer.New(reparseSource)).ParseProgram()
+	offset := strings.Index(reparseSource, "= 1;")
+
+	_, changes, err := Reparse(program, Edit{Offset: offset, Len: 1, Text: "=="}, Options{})
+	if err != nil {
+		t.Fatalf("Reparse: %v", err)
+	}
+	if len(changes.Diagnostics) == 0 {
+		t.Fatalf("no diagnostics for %q", "let one == 1")
+	}
+	if line := changes.Diagnostics[0].Pos.Line; line != 4 {
+		t.Errorf("diagnostic on line %d, want 4", line)
+	}
+}
+
+// assertSameTree compares two programs node by node, positions included.
+func assertSameTree(t *testing.T, got, want *ast.Program) {
+	t.Helper()
+	gotTree, err := compact.FromAST(got)
+	if err != nil {
+		t.Fatalf("FromAST: %v", err)
+	}
+	wantTree, err := compact.FromAST(want)
+	if err != nil {
+		t.Fatalf("FromAST: %v", err)
+	}
+	if !ast.EqualPositions(got, want) {
+		t.Errorf("program = %q, want %q", got.String(), want.String())
+	}
+	if !reflect.DeepEqual(gotTree.Nodes, wantTree.Nodes) || !reflect.DeepEqual(gotTree.Tokens, wantTree.Tokens) {
+		t.Errorf("tree differs from a full parse")
+	}
+	if got, want := extents(got), extents(want); !reflect.DeepEqual(got, want) {
+		t.Errorf("node extents differ from a full parse:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+// A statement's span is its extent, which leaves out the `;` after it.
+func TestProgram_Spans(t *testing.T) {
+	for _, input := range []string{
+		reparseSource,
+		"let f = fn(a) {\n a\n};\nf(1,\n 2);\n(a + b) * c;",
+		"let x = ; if (x { y(1, ; } fn(a) => ;",
+	} {
+		program := NewWithOptions(lexer.New(input), Options{Tolerant: true}).ParseProgram()
+		if len(program.Spans) != len(program.Statements) {
+			t.Fatalf("%q: %d spans for %d statements", input, len(program.Spans), len(program.Statements))
+		}
+		for i, stmt := range program.Statements {
+			if span := program.Spans[i]; span.Start != stmt.Pos() || span.End != stmt.End() {
+				t.Errorf("%q: span of %s is %s-%s, want %s-%s",
+					input, stmt, span.Start, span.End, stmt.Pos(), stmt.End())
+			}
+		}
+	}
+}
+
+func extents(program *ast.Program) []ast.Span {
+	var spans []ast.Span
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			spans = append(spans, ast.Span{Start: n.Pos(), End: n.End()})
+		}
+		return true
+	})
+	return spans
+}
+
+func containsStatement(stmts []ast.Statement, stmt ast.Statement) bool {
+	for _, s := range stmts {
+		if s == stmt {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"mcompiler/ast"
+	"mcompiler/token"
+)
+
+// The helpers below build the placeholders of tolerant mode. Outside it they
+// return nil, so a parse function can end with `return p.missingExpression(tok)`
+// in place of `return nil` either way.
+
+// skipped is the span from start through the current token, or an empty span
+// at start if the parser has not reached it yet.
+func (p *Parser) skipped(start token.Token) ast.Span {
+	if p.curToken.Pos.Offset < start.Pos.Offset {
+		return ast.Span{Start: start.Pos, End: start.Pos}
+	}
+	return ast.Span{Start: start.Pos, End: p.curToken.End()}
+}
+
+func (p *Parser) missingExpression(start token.Token) ast.Expression {
+	if !p.opts.Tolerant {
+		return nil
+	}
+	return Alloc(p, ast.MissingExpression{Token: start, Span: p.skipped(start)})
+}
+
+func (p *Parser) orMissing(expr ast.Expression, start token.Token) ast.Expression {
+	if expr != nil {
+		return expr
+	}
+	return p.missingExpression(start)
+}
+
+func (p *Parser) missingStatement(start token.Token) ast.Statement {
+	if !p.opts.Tolerant {
+		return nil
+	}
+	return Alloc(p, ast.MissingStatement{Token: start, Span: p.skipped(start)})
+}
+
+func (p *Parser) orMissingStatement(stmt ast.Statement, start token.Token) ast.Statement {
+	if stmt != nil {
+		return stmt
+	}
+	return p.missingStatement(start)
+}
+
+func (p *Parser) missingType(start token.Token) ast.TypeExpression {
+	if !p.opts.Tolerant {
+		return nil
+	}
+	return Alloc(p, ast.MissingType{Token: start, Span: p.skipped(start)})
+}
+
+// missingIdentifier is a nameless identifier at the position of tok.
+func (p *Parser) missingIdentifier(tok token.Token) *ast.Identifier {
+	return Alloc(p, ast.Identifier{Token: token.Token{Type: token.IDENT, Pos: tok.Pos}})
+}
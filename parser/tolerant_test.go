@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"reflect"
+	"testing"
+)
+
+func TestTolerant(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 1;", "let  = 1;"},
+		{"let x 1;", "let x = <missing>;1;"},
+		{"let x = ;", "let x = <missing>;"},
+		{"let x = 1 + ;", "let x = (1 + <missing>);"},
+		{"if (x { 1 }", "if x {1;}"},
+		{"if x { 1 }", "if <missing> <missing>x;{1;}"},
+		{"if (x) { 1 } else 2", "if x {1;} else <missing>2;"},
+		{"add(1, 2", "add(1, 2);"},
+		{"(1 + 2", "(1 + 2);"},
+		{"let f = fn(a, b { a };", "let f = fn(a, b){a;};"},
+		{"let f = fn(a) a;", "let f = fn(a)<missing>;a;"},
+		{"let f = fn;", "let f = fn()<missing>;"},
+		{"1 = 2;", "<missing>;"},
+		{")", "<missing>;"},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{Tolerant: true})
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: no errors reported", tt.input)
+		}
+		assertNoNil(t, tt.input, reflect.ValueOf(program))
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: program = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestTolerant_Spans(t *testing.T) {
+	p := NewWithOptions(lexer.New("let x = ;\nlet = 2;"), Options{Tolerant: true})
+	program := p.ParseProgram()
+
+	value := program.Statements[0].(*ast.LetStatement).Value.(*ast.MissingExpression)
+	if value.Span.Start.String() != "1:9" || value.Span.End.String() != "1:10" {
+		t.Errorf("missing value spans %s-%s, want 1:9-1:10", value.Span.Start, value.Span.End)
+	}
+
+	name := program.Statements[1].(*ast.LetStatement).Name
+	if name.Value != "" || name.Token.Pos.String() != "2:5" {
+		t.Errorf("missing name = %q at %s, want an empty name at 2:5", name.Value, name.Token.Pos)
+	}
+
+	_, ok := NewWithOptions(lexer.New("fn() {"), Options{Tolerant: true}).ParseExpression().(*ast.MissingExpression)
+	if ok {
+		t.Errorf("unclosed function literal was dropped")
+	}
+}
+
+// Every prefix of a valid program is what an editor sees while it is being
+// typed; none may leave a hole in the tree.
+func TestTolerant_Prefixes(t *testing.T) {
+	input := generateSource(2) + "xs |> map(fn(x) => x * 2) |> sum; a = b = c; if (a) { 1 } else { -2 ** 3 }"
+
+	for _, opts := range []Options{{Tolerant: true}, {Tolerant: true, LazyBodies: true}} {
+		for i := range input {
+			prefix := input[:i]
+			program := NewWithOptions(lexer.New(prefix), opts).ParseProgram()
+			assertNoNil(t, prefix, reflect.ValueOf(program))
+			_ = program.String()
+		}
+	}
+	if t.Failed() {
+		return
+	}
+
+	for _, parse := range []func(p *Parser) any{
+		func(p *Parser) any { return p.ParseExpression() },
+		func(p *Parser) any { return p.ParseStatement() },
+		func(p *Parser) any { return p.ParseBlock() },
+		func(p *Parser) any { return p.ParseType() },
+	} {
+		for _, input := range []string{"", ")", "fn(", "{ let", "fn(int) =>"} {
+			node := parse(NewWithOptions(lexer.New(input), Options{Tolerant: true}))
+			assertNoNil(t, input, reflect.ValueOf(node))
+		}
+	}
+}
+
+var (
+	statementType  = reflect.TypeOf((*ast.Statement)(nil)).Elem()
+	expressionType = reflect.TypeOf((*ast.Expression)(nil)).Elem()
+	typeExprType   = reflect.TypeOf((*ast.TypeExpression)(nil)).Elem()
+)
+
+// assertNoNil fails if any node reachable from v is nil, other than the
+// alternative of an if without else and absent annotations and type
+// arguments.
+func assertNoNil(t *testing.T, input string, v reflect.Value) {
+	t.Helper()
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			t.Errorf("%q: nil node", input)
+			return
+		}
+		assertNoNil(t, input, v.Elem())
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			assertNoNil(t, input, v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type().PkgPath() != "mcompiler/ast" {
+			return
+		}
+		if fn, ok := v.Addr().Interface().(*ast.FunctionExpression); ok {
+			fn.ParseBody()
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field, f := v.Type().Field(i), v.Field(i)
+			switch field.Name {
+			case "Alternative", "Type", "ParameterTypes", "Arguments":
+				if f.IsNil() {
+					continue
+				}
+			}
+			if field.Name == "ParameterTypes" {
+				// One for each parameter, nil where it has none.
+				for i := 0; i < f.Len(); i++ {
+					if !f.Index(i).IsNil() {
+						assertNoNil(t, input, f.Index(i))
+					}
+				}
+				continue
+			}
+			switch field.Type {
+			case statementType, expressionType, typeExprType:
+				assertNoNil(t, input, f)
+			default:
+				if field.Type.Kind() == reflect.Pointer || field.Type.Kind() == reflect.Slice {
+					assertNoNil(t, input, f)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/token"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+var precedenceNames = [...]string{
+	LOWEST:     "LOWEST",
+	ASSIGNMENT: "ASSIGNMENT",
+	PIPELINE:   "PIPELINE",
+	EQUALITY:   "EQUALITY",
+	COMPARISON: "COMPARISON",
+	SUM:        "SUM",
+	PRODUCT:    "PRODUCT",
+	PREFIX:     "PREFIX",
+	POWER:      "POWER",
+}
+
+func (pr Precedence) String() string {
+	if pr > 0 && int(pr) < len(precedenceNames) {
+		return precedenceNames[pr]
+	}
+	return fmt.Sprintf("Precedence(%d)", int(pr))
+}
+
+func (a Associativity) String() string {
+	if a == RightAssoc {
+		return "right"
+	}
+	return "left"
+}
+
+// trace logs the entry into a parse function when Options.Trace is set, and
+// returns what untrace needs to log its exit:
+//
+//	defer p.untrace(p.trace("parseLetStatement"))
+func (p *Parser) trace(name string) string {
+	if p.opts.Trace == nil {
+		return ""
+	}
+	p.tracef("BEGIN %s", name)
+	p.traceDepth++
+	return name
+}
+
+func (p *Parser) untrace(name string) {
+	if p.opts.Trace == nil {
+		return
+	}
+	p.traceDepth--
+	p.tracef("END %s", name)
+}
+
+// tracef writes one line of the trace, indented by nesting and followed by
+// the current and peek tokens.
+func (p *Parser) tracef(format string, args ...any) {
+	if p.opts.Trace == nil {
+		return
+	}
+	line := strings.Repeat("  ", p.traceDepth) + fmt.Sprintf(format, args...)
+	fmt.Fprintf(p.opts.Trace, "%-48s cur=%s peek=%s\n", line, traceToken(p.curToken), traceToken(p.peekToken))
+}
+
+func traceToken(tok token.Token) string {
+	if tok.Type == token.EOF {
+		return fmt.Sprintf("EOF@%s", tok.Pos)
+	}
+	if string(tok.Type) == tok.Literal {
+		return fmt.Sprintf("%q@%s", tok.Literal, tok.Pos)
+	}
+	return fmt.Sprintf("%s%q@%s", tok.Type, tok.Literal, tok.Pos)
+}
+
+// funcName names a parse function for the trace, e.g. "parseIdentifier".
+func funcName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
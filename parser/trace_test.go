@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"mcompiler/lexer"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	var out strings.Builder
+	p := NewWithOptions(lexer.New("1 + 2 ** 3"), Options{Trace: &out})
+	p.ParseExpression()
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		lines = append(lines, strings.TrimRight(line[:strings.Index(line, " cur=")], " "))
+	}
+	expected := []string{
+		"BEGIN parseExpression",
+		"  precedence LOWEST",
+		"  prefix parseIntegerLiteral for INT",
+		"  infix parseOperatorExpression for + at SUM, left-associative",
+		"  BEGIN parseOperatorExpression",
+		"    BEGIN parseExpression",
+		"      precedence SUM",
+		"      prefix parseIntegerLiteral for INT",
+		"      infix parseOperatorExpression for ** at POWER, right-associative",
+		"      BEGIN parseOperatorExpression",
+		"        BEGIN parseExpression",
+		"          precedence PREFIX",
+		"          prefix parseIntegerLiteral for INT",
+		"        END parseExpression",
+		"      END parseOperatorExpression",
+		"    END parseExpression",
+		"  END parseOperatorExpression",
+		"END parseExpression",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("trace:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if first := strings.SplitN(out.String(), "\n", 2)[0]; !strings.HasSuffix(first, `cur=INT"1"@1:1 peek="+"@1:3`) {
+		t.Errorf("first line %q does not show the current and peek tokens", first)
+	}
+}
+
+func TestTrace_Off(t *testing.T) {
+	p := New(lexer.New("let x = fn(a) { a + 1 };"))
+	p.ParseProgram()
+	if p.traceDepth != 0 {
+		t.Fatalf("traceDepth = %d without tracing", p.traceDepth)
+	}
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/token"
+)
+
+func (p *Parser) parseType() ast.TypeExpression {
+	defer p.untrace(p.trace("parseType"))
+	if !p.enter() {
+		return p.missingType(p.curToken)
+	}
+	defer p.leave()
+
+	switch p.curToken.Type {
+	case token.IDENT:
+		typ := Alloc(p, ast.NamedType{Token: p.curToken, Name: p.curToken.Literal})
+		if !p.peekTokenIs(token.LPAREN) {
+			return typ
+		}
+		start := p.curToken
+		p.nextToken()
+		args, ok := p.parseTypeList()
+		if !ok {
+			return p.missingType(start)
+		}
+		typ.Arguments, typ.Rparen = args, p.curToken.Pos
+		return typ
+	case token.FUNCTION:
+		start := p.curToken
+		if typ := p.parseFunctionType(); typ != nil {
+			return typ
+		}
+		return p.missingType(start)
+	default:
+		msg := fmt.Sprintf("expected type, got %s instead", p.curToken.Type)
+		p.errorAt(p.curToken.Pos, msg)
+		return p.missingType(p.curToken)
+	}
+}
+
+func (p *Parser) parseFunctionType() ast.TypeExpression {
+	typ := Alloc(p, ast.FunctionType{Token: p.curToken})
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	params, ok := p.parseTypeList()
+	if !ok {
+		return nil
+	}
+	typ.Parameters = params
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+
+	typ.Result = p.parseType()
+	if typ.Result == nil {
+		return nil
+	}
+	return typ
+}
+
+// parseTypeList parses the types from the `(` at the current token through
+// the matching `)`, and reports false if the list is not closed or a type
+// in it is missing.
+func (p *Parser) parseTypeList() ([]ast.TypeExpression, bool) {
+	base := len(p.types)
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+	} else {
+		p.nextToken()
+		p.types = append(p.types, p.parseType())
+
+		for p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			p.nextToken()
+			p.types = append(p.types, p.parseType())
+		}
+
+		if !p.expectPeek(token.RPAREN) {
+			p.types = p.types[:base]
+			return nil, false
+		}
+	}
+	list := collect(p, &p.types, base)
+	for _, typ := range list {
+		if typ == nil {
+			return nil, false
+		}
+	}
+	return list, true
+}
+
+// parseAnnotation parses the `: type` after a let's name or a parameter, if
+// there is one. It returns nil if there is none, and reports false if the
+// type is missing.
+func (p *Parser) parseAnnotation() (ast.TypeExpression, bool) {
+	if !p.peekTokenIs(token.COLON) || p.opts.Disable&TypeAnnotations != 0 {
+		return nil, true
+	}
+	p.nextToken()
+	p.nextToken()
+	typ := p.parseType()
+	return typ, typ != nil
+}
@@ -0,0 +1,401 @@
+// Package resolve connects every use of a name to its declaration.
+//
+// The program, each block and each function have a scope; a function's
+// parameters and the statements of its body share one. A name is visible
+// from its declaration to the end of its scope, except that a let whose
+// value is a function is visible in that function, for recursion, and a
+// global is visible in every function body, since bodies run after the
+// program's top level has been declared.
+package resolve
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/token"
+)
+
+// Kind classifies a declaration, or how a use sees it.
+type Kind uint8
+
+const (
+	Global    Kind = iota + 1 // declared at the top level of the program
+	Local                     // declared in a block or function body
+	Parameter                 // a function's parameter
+	Free                      // a local or parameter of an enclosing function
+	Builtin                   // predeclared, see Options.Builtins
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Global:
+		return "global"
+	case Local:
+		return "local"
+	case Parameter:
+		return "parameter"
+	case Free:
+		return "free"
+	case Builtin:
+		return "builtin"
+	}
+	return fmt.Sprintf("Kind(%d)", k)
+}
+
+// DefaultBuiltins are the builtins used when Options.Builtins is nil.
+var DefaultBuiltins = []string{"len", "first", "last", "rest", "push", "puts"}
+
+type Options struct {
+	// Builtins are the names declared before the program. Nil means
+	// DefaultBuiltins.
+	Builtins []string
+}
+
+// Symbol is a declared name. Kind is never Free: that is how a use sees a
+// symbol, see Binding.
+type Symbol struct {
+	Name  string
+	Kind  Kind
+	Decl  *ast.Identifier // nil for builtins
+	Scope *Scope
+
+	// Uses counts the identifiers and calls that read the symbol.
+	// Assignments are not counted.
+	Uses int
+}
+
+type ScopeKind uint8
+
+const (
+	UniverseScope ScopeKind = iota + 1 // the builtins
+	ProgramScope
+	BlockScope
+	FunctionScope
+)
+
+// Scope holds the symbols declared in a program, block or function. Node
+// is the *ast.Program, *ast.BlockStatement or *ast.FunctionExpression that
+// opens it, and nil for the universe scope.
+type Scope struct {
+	Kind     ScopeKind
+	Node     ast.Node
+	Parent   *Scope
+	Children []*Scope
+	Symbols  []*Symbol // in declaration order, redeclarations included
+
+	// Free lists, for a function scope, the locals and parameters of
+	// enclosing functions that the function or the functions inside it
+	// use, in order of first use.
+	Free []*Symbol
+
+	names map[string]*Symbol // the latest declaration of each name
+}
+
+// Lookup returns the symbol name refers to in s or an enclosing scope, by
+// its latest declaration in each.
+func (s *Scope) Lookup(name string) *Symbol {
+	for ; s != nil; s = s.Parent {
+		if sym := s.names[name]; sym != nil {
+			return sym
+		}
+	}
+	return nil
+}
+
+// function returns the function scope s belongs to, or nil outside any
+// function.
+func (s *Scope) function() *Scope {
+	for ; s != nil; s = s.Parent {
+		if s.Kind == FunctionScope {
+			return s
+		}
+	}
+	return nil
+}
+
+// Binding is what a name refers to. Kind is the symbol's kind, or Free for
+// a local or parameter used from inside a nested function.
+type Binding struct {
+	Symbol *Symbol
+	Kind   Kind
+}
+
+type Severity uint8
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+type Diagnostic struct {
+	Pos      token.Pos
+	Severity Severity
+	Msg      string
+}
+
+func (d Diagnostic) String() string {
+	if d.Severity == Warning {
+		return fmt.Sprintf("%s: warning: %s", d.Pos, d.Msg)
+	}
+	return fmt.Sprintf("%s: %s", d.Pos, d.Msg)
+}
+
+// Info is the result of resolving a program.
+type Info struct {
+	Universe *Scope
+	Global   *Scope
+
+	// Bindings maps every identifier, declarations and assignment targets
+	// included, to the symbol it names. Undefined names are left out.
+	Bindings map[*ast.Identifier]Binding
+
+	// Calls maps every call to the function it names, unless undefined.
+	Calls map[*ast.FunctionInvokeExpression]Binding
+
+	// Scopes maps programs, blocks and functions to their scopes.
+	Scopes map[ast.Node]*Scope
+
+	// Diagnostics reports undefined names and duplicate declarations as
+	// errors and shadowing as warnings, in source order.
+	Diagnostics []Diagnostic
+}
+
+// Errors reports whether any diagnostic is an error.
+func (info *Info) Errors() bool {
+	for _, d := range info.Diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Program resolves every name in program. Function bodies skipped by a lazy
+// parser are parsed on the way.
+func Program(program *ast.Program, opts Options) *Info {
+	builtins := opts.Builtins
+	if builtins == nil {
+		builtins = DefaultBuiltins
+	}
+	info := &Info{
+		Bindings: make(map[*ast.Identifier]Binding),
+		Calls:    make(map[*ast.FunctionInvokeExpression]Binding),
+		Scopes:   make(map[ast.Node]*Scope),
+	}
+	r := &resolver{info: info}
+
+	info.Universe = r.push(UniverseScope, nil)
+	for _, name := range builtins {
+		sym := &Symbol{Name: name, Kind: Builtin, Scope: info.Universe}
+		info.Universe.Symbols = append(info.Universe.Symbols, sym)
+		info.Universe.names[name] = sym
+	}
+
+	// Globals are created up front so that function bodies can refer to
+	// those declared further down; each comes into scope at its let.
+	info.Global = r.push(ProgramScope, program)
+	r.globals = make(map[*ast.LetStatement]*Symbol)
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && declares(let.Name) {
+			sym := &Symbol{Name: let.Name.Value, Kind: Global, Decl: let.Name, Scope: info.Global}
+			r.globals[let] = sym
+			r.pending = append(r.pending, sym)
+		}
+	}
+	for _, stmt := range program.Statements {
+		r.node(stmt)
+	}
+	r.pop()
+	r.pop()
+	return info
+}
+
+type resolver struct {
+	info  *Info
+	scope *Scope
+
+	globals map[*ast.LetStatement]*Symbol
+	pending []*Symbol // globals not yet in scope, in source order
+}
+
+func declares(ident *ast.Identifier) bool {
+	return ident != nil && ident.Value != ""
+}
+
+func (r *resolver) push(kind ScopeKind, node ast.Node) *Scope {
+	s := &Scope{Kind: kind, Node: node, Parent: r.scope, names: make(map[string]*Symbol)}
+	if r.scope != nil {
+		r.scope.Children = append(r.scope.Children, s)
+	}
+	if node != nil {
+		r.info.Scopes[node] = s
+	}
+	r.scope = s
+	return s
+}
+
+func (r *resolver) pop() {
+	r.scope = r.scope.Parent
+}
+
+func (r *resolver) report(pos token.Pos, severity Severity, format string, args ...any) {
+	r.info.Diagnostics = append(r.info.Diagnostics, Diagnostic{Pos: pos, Severity: severity, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (r *resolver) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.LetStatement:
+		// A function can call itself by the name it is bound to.
+		if _, ok := n.Value.(*ast.FunctionExpression); ok {
+			r.declareLet(n)
+			r.node(n.Value)
+		} else {
+			r.node(n.Value)
+			r.declareLet(n)
+		}
+	case *ast.BlockStatement:
+		r.push(BlockScope, n)
+		for _, stmt := range n.Statements {
+			r.node(stmt)
+		}
+		r.pop()
+	case *ast.FunctionExpression:
+		r.push(FunctionScope, n)
+		for i := range n.Parameters {
+			r.declare(&n.Parameters[i], &Symbol{Kind: Parameter})
+		}
+		// The body shares the parameters' scope.
+		switch body := n.ParseBody().(type) {
+		case *ast.BlockStatement:
+			r.info.Scopes[body] = r.scope
+			for _, stmt := range body.Statements {
+				r.node(stmt)
+			}
+		case nil:
+		default:
+			r.node(body)
+		}
+		r.pop()
+	case *ast.AssignExpression:
+		r.node(n.Value)
+		if declares(n.Name) {
+			if b, ok := r.bind(n.Name.Value, n.Name.Pos()); ok {
+				r.info.Bindings[n.Name] = b
+				if b.Kind == Builtin {
+					r.report(n.Name.Pos(), Error, "cannot assign to builtin %s", n.Name.Value)
+				}
+			}
+		}
+	case *ast.Identifier:
+		if declares(n) {
+			if b, ok := r.bind(n.Value, n.Pos()); ok {
+				b.Symbol.Uses++
+				r.info.Bindings[n] = b
+			}
+		}
+	case *ast.FunctionInvokeExpression:
+		if b, ok := r.bind(n.Token.Literal, n.Pos()); ok {
+			b.Symbol.Uses++
+			r.info.Calls[n] = b
+		}
+		for _, arg := range n.Arguments {
+			r.node(arg)
+		}
+	case nil:
+	default:
+		// Everything else only contains uses.
+		ast.Walk(children{r, node}, node)
+	}
+}
+
+// children is a Visitor that resolves the children of root.
+type children struct {
+	r    *resolver
+	root ast.Node
+}
+
+func (c children) Visit(node ast.Node) ast.Visitor {
+	if node == c.root {
+		return c
+	}
+	if node != nil {
+		c.r.node(node)
+	}
+	return nil
+}
+
+func (r *resolver) declareLet(let *ast.LetStatement) {
+	if !declares(let.Name) {
+		return
+	}
+	if sym := r.globals[let]; sym != nil {
+		r.pending = r.pending[1:]
+		r.declare(let.Name, sym)
+		return
+	}
+	r.declare(let.Name, &Symbol{Kind: Local})
+}
+
+// declare brings sym into the current scope under the name ident, filling
+// in what the caller left out.
+func (r *resolver) declare(ident *ast.Identifier, sym *Symbol) {
+	if !declares(ident) {
+		return
+	}
+	sym.Name, sym.Decl, sym.Scope = ident.Value, ident, r.scope
+
+	if prev := r.scope.names[sym.Name]; prev != nil {
+		r.report(ident.Pos(), Error, "%s redeclared in this scope; previous declaration at %s", sym.Name, prev.Decl.Pos())
+	} else if outer := r.scope.Parent.Lookup(sym.Name); outer != nil {
+		if outer.Kind == Builtin {
+			r.report(ident.Pos(), Warning, "%s shadows the builtin", sym.Name)
+		} else {
+			r.report(ident.Pos(), Warning, "%s shadows the declaration at %s", sym.Name, outer.Decl.Pos())
+		}
+	}
+
+	r.scope.Symbols = append(r.scope.Symbols, sym)
+	r.scope.names[sym.Name] = sym
+	r.info.Bindings[ident] = Binding{Symbol: sym, Kind: sym.Kind}
+}
+
+// bind finds what name refers to at pos, reporting it if nothing.
+func (r *resolver) bind(name string, pos token.Pos) (Binding, bool) {
+	sym := r.scope.Lookup(name)
+	fn := r.scope.function()
+	// A function runs after the lets below it, so a global declared later
+	// is what it sees, even in place of a builtin.
+	if (sym == nil || sym.Kind == Builtin) && fn != nil {
+		for _, g := range r.pending {
+			if g.Name == name {
+				sym = g
+				break
+			}
+		}
+	}
+	if sym == nil {
+		r.report(pos, Error, "undefined: %s", name)
+		return Binding{}, false
+	}
+
+	kind := sym.Kind
+	if kind == Local || kind == Parameter {
+		// Every function between the use and the declaration captures it.
+		owner := sym.Scope.function()
+		for f := fn; f != owner; f = f.Parent.function() {
+			kind = Free
+			if !contains(f.Free, sym) {
+				f.Free = append(f.Free, sym)
+			}
+		}
+	}
+	return Binding{Symbol: sym, Kind: kind}, true
+}
+
+func contains(list []*Symbol, sym *Symbol) bool {
+	for _, s := range list {
+		if s == sym {
+			return true
+		}
+	}
+	return false
+}
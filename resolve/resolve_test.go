@@ -0,0 +1,186 @@
+package resolve
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+	return program
+}
+
+// uses lists every name in the program with how it was resolved.
+func uses(program *ast.Program, info *Info) []string {
+	var out []string
+	add := func(name string, pos fmt.Stringer, b Binding, ok bool) {
+		if !ok {
+			out = append(out, fmt.Sprintf("%s %s undefined", name, pos))
+			return
+		}
+		decl := "-"
+		if b.Symbol.Decl != nil {
+			decl = b.Symbol.Decl.Pos().String()
+		}
+		out = append(out, fmt.Sprintf("%s %s %s %s", name, pos, b.Kind, decl))
+	}
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			b, ok := info.Bindings[n]
+			add(n.Value, n.Pos(), b, ok)
+		case *ast.FunctionInvokeExpression:
+			b, ok := info.Calls[n]
+			add(n.Token.Literal+"()", n.Pos(), b, ok)
+		}
+		return true
+	})
+	return out
+}
+
+func TestProgram_Bindings(t *testing.T) {
+	program := parse(t, `let n = 10;
+let fact = fn(x) { if (x < 2) { 1 } else { x * fact(x - 1) } };
+let adder = fn(a) { let b = a; fn(c) { a + b + c + n } };
+if (n > 1) { let m = len(n); fn() { m } }
+`)
+	info := Program(program, Options{})
+	if len(info.Diagnostics) != 0 {
+		t.Fatalf("diagnostics: %v", info.Diagnostics)
+	}
+	expected := []string{
+		"n 1:5 global 1:5",
+		"fact 2:5 global 2:5",
+		"x 2:15 parameter 2:15",
+		"x 2:24 parameter 2:15",
+		"x 2:44 parameter 2:15",
+		"fact() 2:48 global 2:5",
+		"x 2:53 parameter 2:15",
+		"adder 3:5 global 3:5",
+		"a 3:16 parameter 3:16",
+		"b 3:25 local 3:25",
+		"a 3:29 parameter 3:16",
+		"c 3:35 parameter 3:35",
+		"a 3:40 free 3:16",
+		"b 3:44 free 3:25",
+		"c 3:48 parameter 3:35",
+		"n 3:52 global 1:5",
+		"n 4:5 global 1:5",
+		"m 4:18 local 4:18",
+		"len() 4:22 builtin -",
+		"n 4:26 global 1:5",
+		"m 4:37 free 4:18",
+	}
+	if got := uses(program, info); !reflect.DeepEqual(got, expected) {
+		t.Errorf("bindings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	fact := info.Global.Lookup("fact")
+	if fact == nil || fact.Uses != 1 {
+		t.Errorf("fact = %+v, want one use", fact)
+	}
+	if n := info.Global.Lookup("n"); n.Uses != 3 {
+		t.Errorf("n has %d uses, want 3", n.Uses)
+	}
+}
+
+// A function sees a global declared after it in place of a builtin of the
+// same name; code at the top level before the let still sees the builtin.
+func TestProgram_LaterGlobalHidesBuiltin(t *testing.T) {
+	program := parse(t, "len;\nlet f = fn() { len };\nlet len = 5;")
+	info := Program(program, Options{})
+	expected := []string{
+		"len 1:1 builtin -",
+		"f 2:5 global 2:5",
+		"len 2:16 global 3:5",
+		"len 3:5 global 3:5",
+	}
+	if got := uses(program, info); !reflect.DeepEqual(got, expected) {
+		t.Errorf("bindings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestProgram_Scopes(t *testing.T) {
+	program := parse(t, `let f = fn(a) { let g = fn(b) { fn() { a + b } }; g };`)
+	info := Program(program, Options{Builtins: []string{}})
+
+	if info.Universe.Symbols != nil || info.Scopes[program] != info.Global || info.Global.Parent != info.Universe {
+		t.Fatalf("unexpected outer scopes")
+	}
+	var fns []*Scope
+	ast.InspectType(program, func(fn *ast.FunctionExpression) bool {
+		s := info.Scopes[fn]
+		if s == nil || s.Kind != FunctionScope || info.Scopes[fn.Body] != s {
+			t.Fatalf("%s has no scope of its own shared with its body", fn)
+		}
+		fns = append(fns, s)
+		return true
+	})
+
+	names := func(syms []*Symbol) string {
+		var out []string
+		for _, sym := range syms {
+			out = append(out, sym.Name)
+		}
+		return strings.Join(out, " ")
+	}
+	tests := []struct{ symbols, free string }{
+		{"a g", ""},
+		{"b", "a"},
+		{"", "a b"},
+	}
+	for i, tt := range tests {
+		if got := names(fns[i].Symbols); got != tt.symbols {
+			t.Errorf("function %d declares %q, want %q", i, got, tt.symbols)
+		}
+		if got := names(fns[i].Free); got != tt.free {
+			t.Errorf("function %d captures %q, want %q", i, got, tt.free)
+		}
+	}
+}
+
+func TestProgram_Diagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"x;", []string{"1:1: undefined: x"}},
+		{"f(1);", []string{"1:1: undefined: f"}},
+		{"let x = x;", []string{"1:9: undefined: x"}},
+		{"y = 1;", []string{"1:1: undefined: y"}},
+		{"later; let later = 1;", []string{"1:1: undefined: later"}},
+		{"fn() { x }; { let x = 1; }", []string{"1:8: undefined: x"}},
+		// Function bodies see every global.
+		{"let f = fn() { g() }; let g = fn() { f() };", nil},
+		{"let x = 1; let x = 2;", []string{"1:16: x redeclared in this scope; previous declaration at 1:5"}},
+		{"fn(a, a) { a };", []string{"1:7: a redeclared in this scope; previous declaration at 1:4"}},
+		{"fn(a) { let a = 1; a };", []string{"1:13: a redeclared in this scope; previous declaration at 1:4"}},
+		{"let x = 1; fn(x) { x };", []string{"1:15: warning: x shadows the declaration at 1:5"}},
+		{"let x = 1; if (x) { let x = 2; x }", []string{"1:25: warning: x shadows the declaration at 1:5"}},
+		{"let len = 1;", []string{"1:5: warning: len shadows the builtin"}},
+		{"len = 1;", []string{"1:1: cannot assign to builtin len"}},
+	}
+	for _, tt := range tests {
+		info := Program(parse(t, tt.input), Options{})
+		var got []string
+		for _, d := range info.Diagnostics {
+			got = append(got, d.String())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: diagnostics = %q, want %q", tt.input, got, tt.expected)
+		}
+		if info.Errors() != (len(tt.expected) > 0 && !strings.Contains(tt.expected[0], "warning")) {
+			t.Errorf("%q: Errors() = %t", tt.input, info.Errors())
+		}
+	}
+}
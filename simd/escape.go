@@ -0,0 +1,90 @@
+package simd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Unescape decodes the escape sequences of a string value. The parser hands
+// out strings exactly as they appear between the quotes, so values that may
+// contain a backslash must go through Unescape. A string without escapes is
+// returned as it is.
+func Unescape(s string) (string, error) {
+	i := strings.IndexByte(s, '\\')
+	if i < 0 {
+		return s, nil
+	}
+
+	buf := make([]byte, 0, len(s))
+	buf = append(buf, s[:i]...)
+	for i < len(s) {
+		c := s[i]
+		if c != '\\' {
+			buf = append(buf, c)
+			i++
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", errors.New("simd: unterminated escape")
+		}
+		switch e := s[i+1]; e {
+		case '"', '\\', '/':
+			buf = append(buf, e)
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r, n, err := unescapeRune(s[i:])
+			if err != nil {
+				return "", err
+			}
+			buf = utf8.AppendRune(buf, r)
+			i += n
+			continue
+		default:
+			return "", errors.New("simd: invalid escape \\" + string(e))
+		}
+		i += 2
+	}
+	return string(buf), nil
+}
+
+// unescapeRune decodes the \uXXXX escape at the start of s, joining a
+// surrogate pair written as two escapes, and returns the rune and the number
+// of bytes consumed.
+func unescapeRune(s string) (rune, int, error) {
+	r, err := hex4(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	if utf16.IsSurrogate(r) {
+		if r2, err := hex4(s[6:]); err == nil {
+			if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+				return dec, 12, nil
+			}
+		}
+		return utf8.RuneError, 6, nil
+	}
+	return r, 6, nil
+}
+
+func hex4(s string) (rune, error) {
+	if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+		return 0, errors.New("simd: invalid \\u escape")
+	}
+	v, err := strconv.ParseUint(s[2:6], 16, 16)
+	if err != nil {
+		return 0, errors.New("simd: invalid \\u escape " + s[:6])
+	}
+	return rune(v), nil
+}
@@ -0,0 +1,52 @@
+package simd
+
+import (
+	"fmt"
+	"mcompiler/arena"
+)
+
+func Example() {
+	jsonInput := []byte(`{
+		"name": "SimdParser",
+		"description": "It says \"Hello\" to the world",
+		"path": "C:\\Windows\\System32",
+		"config": {
+			"version": "1.0",
+			"turbo": "on"
+		}
+	}`)
+
+	a := arena.NewBestArena()
+	p := NewParser(jsonInput, a)
+
+	root := p.ParseAny()
+
+	fmt.Printf("Root Type: %d (Object)\n", root.Type)
+
+	child1 := root.Children
+	fmt.Printf("Field 1: Key=%s, Val=%s\n", child1.Key, child1.ValueStr)
+
+	child2 := child1.Next
+	fmt.Printf("Field 2: Key=%s, Val=%s\n", child2.Key, child2.ValueStr)
+
+	child3 := child2.Next
+	fmt.Printf("Field 3: Key=%s, Val=%s\n", child3.Key, child3.ValueStr)
+
+	child4 := child3.Next
+	fmt.Printf("Field 4: Key=%s, Type=%d\n", child4.Key, child4.Type)
+
+	configChild1 := child4.Children
+	fmt.Printf("  -> Config Field 1: Key=%s, Val=%s\n", configChild1.Key, configChild1.ValueStr)
+
+	configChild2 := configChild1.Next
+	fmt.Printf("  -> Config Field 2: Key=%s, Val=%s\n", configChild2.Key, configChild2.ValueStr)
+
+	// Output:
+	// Root Type: 5 (Object)
+	// Field 1: Key=name, Val=SimdParser
+	// Field 2: Key=description, Val=It says \"Hello\" to the world
+	// Field 3: Key=path, Val=C:\\Windows\\System32
+	// Field 4: Key=config, Type=5
+	//   -> Config Field 1: Key=version, Val=1.0
+	//   -> Config Field 2: Key=turbo, Val=on
+}
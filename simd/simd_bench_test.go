@@ -1,4 +1,4 @@
-package main
+package simd
 
 import (
 	"encoding/json"
@@ -1,4 +1,4 @@
-package main
+package simd
 
 import (
 	"fmt"
@@ -32,9 +32,19 @@ type Node struct {
 	Type     NodeType
 }
 
+// DefaultMaxDepth is the nesting depth Parser allows when MaxDepth is 0,
+// the same as the Monkey parser's.
+const DefaultMaxDepth = 1000
+
 type Parser struct {
+	// MaxDepth bounds how deeply arrays and objects may nest; 0 means
+	// DefaultMaxDepth. Parsing recurses at each level, so without a bound
+	// deeply nested input would overflow the stack.
+	MaxDepth int
+
 	input  []byte
 	cursor int
+	depth  int
 	arena  *arena.BestArena
 }
 
@@ -100,8 +110,33 @@ func (p *Parser) ParseAny() *Node {
 	}
 }
 
+// Parse parses the whole input as a single JSON value. Unlike ParseAny,
+// which panics on malformed input, it reports an error, including for
+// anything but whitespace after the value. Only the parser's own panics
+// are turned into errors; any other is a bug and is not recovered.
+func (p *Parser) Parse() (root *Node, err error) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case string:
+			err = fmt.Errorf("simd: %s at offset %d", r, p.cursor)
+		default:
+			panic(r)
+		}
+	}()
+
+	root = p.ParseAny()
+	if c := p.peekNextToken(); c != 0 {
+		return nil, fmt.Errorf("simd: unexpected %q after value at offset %d", c, p.cursor)
+	}
+	return root, nil
+}
+
 func (p *Parser) parsePrimitive() *Node {
 	start := p.cursor
+	if start >= len(p.input) {
+		panic("Unexpected end of input")
+	}
 	c := p.input[start]
 
 	var node *Node
@@ -298,7 +333,26 @@ func (p *Parser) match(target string) bool {
 	return true
 }
 
+// enter is called on entering an array or object, and panics if that
+// nests them too deeply; leave is called on leaving it.
+func (p *Parser) enter() {
+	p.depth++
+	max := p.MaxDepth
+	if max <= 0 {
+		max = DefaultMaxDepth
+	}
+	if p.depth > max {
+		panic(fmt.Sprintf("Maximum nesting depth of %d exceeded", max))
+	}
+}
+
+func (p *Parser) leave() {
+	p.depth--
+}
+
 func (p *Parser) ParseObject() *Node {
+	p.enter()
+	defer p.leave()
 	p.cursor++ // Skip '{'
 
 	if p.peekNextToken() == '}' {
@@ -365,7 +419,7 @@ func (p *Parser) ParseObject() *Node {
 }
 
 func (p *Parser) parseStringValueOnly() string {
-	if p.input[p.cursor] != '"' {
+	if p.cursor >= len(p.input) || p.input[p.cursor] != '"' {
 		panic("Expected quote")
 	}
 	p.cursor++
@@ -383,6 +437,8 @@ func (p *Parser) parseStringValueOnly() string {
 }
 
 func (p *Parser) ParseArray() *Node {
+	p.enter()
+	defer p.leave()
 	p.cursor++ // Skip '['
 
 	if p.peekNextToken() == ']' {
@@ -468,40 +524,3 @@ func (p *Parser) ParseString() *Node {
 	p.cursor += strLen + 1
 	return node
 }
-
-func main() {
-	jsonInput := []byte(`{
-		"name": "SimdParser",
-		"description": "It says \"Hello\" to the world",
-		"path": "C:\\Windows\\System32",
-		"config": {
-			"version": "1.0",
-			"turbo": "on"
-		}
-	}`)
-
-	arena := arena.NewBestArena()
-	p := NewParser(jsonInput, arena)
-
-	root := p.ParseAny()
-
-	fmt.Printf("Root Type: %d (Object)\n", root.Type)
-
-	child1 := root.Children
-	fmt.Printf("Field 1: Key=%s, Val=%s\n", child1.Key, child1.ValueStr)
-
-	child2 := child1.Next
-	fmt.Printf("Field 2: Key=%s, Val=%s\n", child2.Key, child2.ValueStr)
-
-	child3 := child2.Next
-	fmt.Printf("Field 3: Key=%s, Val=%s\n", child3.Key, child3.ValueStr)
-
-	child4 := child3.Next
-	fmt.Printf("Field 4: Key=%s, Type=%d\n", child4.Key, child4.Type)
-
-	configChild1 := child4.Children
-	fmt.Printf("  -> Config Field 1: Key=%s, Val=%s\n", configChild1.Key, configChild1.ValueStr)
-
-	configChild2 := configChild1.Next
-	fmt.Printf("  -> Config Field 2: Key=%s, Val=%s\n", configChild2.Key, configChild2.ValueStr)
-}
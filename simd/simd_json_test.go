@@ -0,0 +1,94 @@
+package simd
+
+import (
+	"mcompiler/arena"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	a := arena.NewBestArena()
+	root, err := NewParser([]byte(` {"a": [1, true, null], "b": {}} `), a).Parse()
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if root.Type != Object || root.Children.Key != "a" || root.Children.Next.Key != "b" {
+		t.Fatalf("unexpected tree %+v", root)
+	}
+	var types []NodeType
+	for n := root.Children.Children; n != nil; n = n.Next {
+		types = append(types, n.Type)
+	}
+	if len(types) != 3 || types[0] != Number || types[1] != True || types[2] != Null {
+		t.Errorf("array element types = %v", types)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{``, "Unexpected EOF"},
+		{`{"a" 1}`, "Expected ':' after key"},
+		{`[1, 2`, "Expected ',' or ']'"},
+		{`"open`, "String not closed"},
+		{`{"a": tru}`, "Expected true"},
+		{`{} {}`, "after value"},
+		{`{"a": 1,}`, "Expected string key"},
+	}
+	for _, tt := range tests {
+		a := arena.NewBestArena()
+		_, err := NewParser([]byte(tt.input), a).Parse()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.input, err, tt.err)
+		}
+	}
+}
+
+func TestParse_MaxDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		return []byte(strings.Repeat(`{"a":[`, n) + strings.Repeat(`]}`, n))
+	}
+	if _, err := NewParser(nested(DefaultMaxDepth/2), arena.NewBestArena()).Parse(); err != nil {
+		t.Errorf("Parse at the default depth: %v", err)
+	}
+	for _, input := range [][]byte{nested(DefaultMaxDepth/2 + 1), []byte(strings.Repeat("[", 3_000_000))} {
+		_, err := NewParser(input, arena.NewBestArena()).Parse()
+		if err == nil || !strings.Contains(err.Error(), "Maximum nesting depth of 1000 exceeded") {
+			t.Errorf("Parse of %d bytes of nesting: %v", len(input), err)
+		}
+	}
+
+	p := NewParser(nested(10), arena.NewBestArena())
+	p.MaxDepth = 19
+	if _, err := p.Parse(); err == nil {
+		t.Errorf("Parse with MaxDepth 19 accepted 20 levels")
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{`plain`, "plain"},
+		{`say \"hi\"`, `say "hi"`},
+		{`C:\\dir\/x`, `C:\dir/x`},
+		{`a\tb\nc`, "a\tb\nc"},
+		{`\u00e9t\u00E9`, "été"},
+		{`\ud83d\ude00`, "😀"},
+		{`\ud83d`, "\uFFFD"},
+	}
+	for _, tt := range tests {
+		got, err := Unescape(tt.input)
+		if err != nil || got != tt.expected {
+			t.Errorf("Unescape(%q) = %q, %v; want %q", tt.input, got, err, tt.expected)
+		}
+	}
+
+	for _, bad := range []string{`\`, `\x`, `\u12`, `\uzzzz`} {
+		if _, err := Unescape(bad); err == nil {
+			t.Errorf("Unescape(%q) succeeded", bad)
+		}
+	}
+}
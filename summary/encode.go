@@ -0,0 +1,198 @@
+package summary
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+)
+
+// Version is the version of the file format written by Encode. Files of
+// other versions are rejected rather than misread.
+//
+// A file is a 24-byte header, a table of sections and the sections, each
+// starting at a multiple of 8 bytes. All integers are little-endian.
+//
+//	header   magic [8]byte, version u32, section count u32,
+//	         CRC-32C of the header's first 16 bytes and the table u32, zero u32
+//	table    per section: kind u32, offset u32, length u32, CRC-32C u32
+//
+// Strings are stored once, in the strings section, and referred to by
+// index everywhere else. Each section has its own checksum, verified the
+// first time the section is read, so opening a file costs the same however
+// large it is.
+const Version = 3
+
+const magic = "\x7fMKSUM\r\n"
+
+const (
+	headerSize = 24
+	entrySize  = 16
+	symbolSize = 32
+	nodeSize   = 16
+	tokenSize  = 20
+)
+
+type sectionKind uint32
+
+// Sections, in file order. Records are u32s unless noted:
+//
+//	strings   see encoder.stringTable
+//	meta      module name, tree root
+//	symbols   sorted by name: name, kind, offset, line, column, first
+//	          parameter, parameter count, type
+//	params    parameter names
+//
+// The tree sections mirror the arrays of compact.Tree and are present only
+// if the summary includes the tree. A node is kind u8, flags u8, zero u16,
+// token, lhs, rhs, and a token is type u16, zero u16, literal, offset,
+// line, column; types and tree strings are string indexes, ints are i64.
+const (
+	sectionStrings sectionKind = iota + 1
+	sectionMeta
+	sectionSymbols
+	sectionParams
+	sectionNodes
+	sectionExtra
+	sectionTokens
+	sectionTypes
+	sectionTreeStrings
+	sectionInts
+	numSections
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Encode returns the summary in the binary file format.
+func (s *Summary) Encode() []byte {
+	e := &encoder{strings: make(map[string]uint32)}
+
+	meta := make([]byte, 0, 8)
+	meta = binary.LittleEndian.AppendUint32(meta, e.string(s.Module))
+	if s.Tree != nil {
+		meta = binary.LittleEndian.AppendUint32(meta, uint32(s.Tree.Root))
+	} else {
+		meta = binary.LittleEndian.AppendUint32(meta, 0)
+	}
+
+	var symbols, params []byte
+	nparams := uint32(0)
+	for _, sym := range s.Symbols {
+		symbols = appendUint32s(symbols,
+			e.string(sym.Name), uint32(sym.Kind),
+			uint32(sym.Pos.Offset), uint32(sym.Pos.Line), uint32(sym.Pos.Column),
+			nparams, uint32(len(sym.Params)), e.string(sym.Type))
+		for _, param := range sym.Params {
+			params = binary.LittleEndian.AppendUint32(params, e.string(param))
+		}
+		nparams += uint32(len(sym.Params))
+	}
+
+	sections := map[sectionKind][]byte{
+		sectionMeta:    meta,
+		sectionSymbols: symbols,
+		sectionParams:  params,
+	}
+	if t := s.Tree; t != nil {
+		var nodes, extra, tokens, types, treeStrings, ints []byte
+		for _, n := range t.Nodes {
+			nodes = append(nodes, byte(n.Kind), n.Flags, 0, 0)
+			nodes = appendUint32s(nodes, n.Token, n.LHS, n.RHS)
+		}
+		extra = appendUint32s(extra, t.Extra...)
+		for _, tok := range t.Tokens {
+			tokens = binary.LittleEndian.AppendUint16(tokens, tok.Type)
+			tokens = append(tokens, 0, 0)
+			tokens = appendUint32s(tokens, tok.Lit, tok.Offset, tok.Line, tok.Column)
+		}
+		for _, typ := range t.Types {
+			types = binary.LittleEndian.AppendUint32(types, e.string(string(typ)))
+		}
+		for _, str := range t.Strings {
+			treeStrings = binary.LittleEndian.AppendUint32(treeStrings, e.string(str))
+		}
+		for _, v := range t.Ints {
+			ints = binary.LittleEndian.AppendUint64(ints, uint64(v))
+		}
+		sections[sectionNodes] = nodes
+		sections[sectionExtra] = extra
+		sections[sectionTokens] = tokens
+		sections[sectionTypes] = types
+		sections[sectionTreeStrings] = treeStrings
+		sections[sectionInts] = ints
+	}
+	sections[sectionStrings] = e.stringTable()
+
+	var kinds []sectionKind
+	for kind := sectionStrings; kind < numSections; kind++ {
+		if _, ok := sections[kind]; ok {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	out := make([]byte, headerSize+entrySize*len(kinds))
+	copy(out, magic)
+	binary.LittleEndian.PutUint32(out[8:], Version)
+	binary.LittleEndian.PutUint32(out[12:], uint32(len(kinds)))
+	for i, kind := range kinds {
+		for len(out)%8 != 0 {
+			out = append(out, 0)
+		}
+		data := sections[kind]
+		entry := out[headerSize+entrySize*i:]
+		binary.LittleEndian.PutUint32(entry[0:], uint32(kind))
+		binary.LittleEndian.PutUint32(entry[4:], uint32(len(out)))
+		binary.LittleEndian.PutUint32(entry[8:], uint32(len(data)))
+		binary.LittleEndian.PutUint32(entry[12:], crc32.Checksum(data, castagnoli))
+		out = append(out, data...)
+	}
+	binary.LittleEndian.PutUint32(out[16:], tableChecksum(out, len(kinds)))
+	return out
+}
+
+// WriteFile writes the encoded summary to the named file.
+func (s *Summary) WriteFile(path string) error {
+	return os.WriteFile(path, s.Encode(), 0o644)
+}
+
+func tableChecksum(data []byte, n int) uint32 {
+	crc := crc32.Update(0, castagnoli, data[:16])
+	return crc32.Update(crc, castagnoli, data[headerSize:headerSize+entrySize*n])
+}
+
+func appendUint32s(b []byte, vs ...uint32) []byte {
+	for _, v := range vs {
+		b = binary.LittleEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+type encoder struct {
+	strings map[string]uint32
+	list    []string
+}
+
+func (e *encoder) string(s string) uint32 {
+	if id, ok := e.strings[s]; ok {
+		return id
+	}
+	id := uint32(len(e.list))
+	e.list = append(e.list, s)
+	e.strings[s] = id
+	return id
+}
+
+// stringTable lays out the strings as a count, count+1 offsets into the
+// bytes that follow, and the bytes.
+func (e *encoder) stringTable() []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(e.list)))
+	offset := uint32(0)
+	b = binary.LittleEndian.AppendUint32(b, offset)
+	for _, s := range e.list {
+		offset += uint32(len(s))
+		b = binary.LittleEndian.AppendUint32(b, offset)
+	}
+	for _, s := range e.list {
+		b = append(b, s...)
+	}
+	return b
+}
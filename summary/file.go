@@ -0,0 +1,425 @@
+package summary
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"mcompiler/ast/compact"
+	"mcompiler/token"
+	"mcompiler/types"
+	"os"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// ErrCorrupt is returned, wrapped, for files that fail a checksum or do not
+// hold what their table says.
+var ErrCorrupt = errors.New("summary: corrupt file")
+
+// File is an encoded summary read in place, from a memory-mapped file or
+// any byte slice. Only the header and section table are checked up front;
+// each section is verified and decoded when first needed. Everything a File
+// returns is copied out of its memory and stays valid after Close.
+//
+// A File is safe for concurrent use.
+type File struct {
+	data     []byte
+	unmap    func() error
+	sections [numSections]section
+
+	// open is held for reading while data is read, and for writing by
+	// Close, so that the memory is not unmapped under a reader.
+	open sync.RWMutex
+
+	mu       sync.Mutex
+	verified [numSections]bool
+}
+
+// recordSize is the size of the fixed-size records in each section; a
+// section's length must be a multiple of it.
+var recordSize = [numSections]int{
+	sectionStrings:     1,
+	sectionMeta:        8,
+	sectionSymbols:     symbolSize,
+	sectionParams:      4,
+	sectionNodes:       nodeSize,
+	sectionExtra:       4,
+	sectionTokens:      tokenSize,
+	sectionTypes:       4,
+	sectionTreeStrings: 4,
+	sectionInts:        8,
+}
+
+type section struct {
+	offset, length int
+	crc            uint32
+	present        bool
+}
+
+// Open memory-maps the named summary file. The caller must Close it.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, unmap, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	file, err := Decode(data)
+	if err != nil {
+		unmap()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	file.unmap = unmap
+	return file, nil
+}
+
+// Decode reads a summary from data, which must not change while the File
+// is in use.
+func Decode(data []byte) (*File, error) {
+	if len(data) < headerSize || string(data[:len(magic)]) != magic {
+		return nil, errors.New("summary: not a summary file")
+	}
+	if v := binary.LittleEndian.Uint32(data[8:]); v != Version {
+		return nil, fmt.Errorf("summary: unsupported version %d", v)
+	}
+	n := int(binary.LittleEndian.Uint32(data[12:]))
+	if n >= int(numSections) || headerSize+entrySize*n > len(data) {
+		return nil, fmt.Errorf("%w: bad section table", ErrCorrupt)
+	}
+	if binary.LittleEndian.Uint32(data[16:]) != tableChecksum(data, n) {
+		return nil, fmt.Errorf("%w: section table checksum mismatch", ErrCorrupt)
+	}
+
+	f := &File{data: data}
+	for i := 0; i < n; i++ {
+		entry := data[headerSize+entrySize*i:]
+		kind := sectionKind(binary.LittleEndian.Uint32(entry))
+		s := section{
+			offset:  int(binary.LittleEndian.Uint32(entry[4:])),
+			length:  int(binary.LittleEndian.Uint32(entry[8:])),
+			crc:     binary.LittleEndian.Uint32(entry[12:]),
+			present: true,
+		}
+		if kind == 0 || kind >= numSections || f.sections[kind].present ||
+			s.offset%8 != 0 || s.offset+s.length > len(data) || s.length%recordSize[kind] != 0 {
+			return nil, fmt.Errorf("%w: bad section table", ErrCorrupt)
+		}
+		f.sections[kind] = s
+	}
+	for _, kind := range []sectionKind{sectionStrings, sectionMeta, sectionSymbols, sectionParams} {
+		if !f.sections[kind].present {
+			return nil, fmt.Errorf("%w: missing section %d", ErrCorrupt, kind)
+		}
+	}
+	return f, nil
+}
+
+// Close releases the memory of a File returned by Open. It waits for reads
+// in progress; reads after it fail.
+func (f *File) Close() error {
+	f.open.Lock()
+	defer f.open.Unlock()
+	if f.unmap == nil {
+		return nil
+	}
+	unmap := f.unmap
+	f.unmap = nil
+	f.data = nil
+	return unmap()
+}
+
+// Module returns the name of the summarized module.
+func (f *File) Module() (string, error) {
+	r := f.reader()
+	defer r.done()
+	meta := r.section(sectionMeta)
+	name := r.string(r.uint32(meta, 0))
+	return strings.Clone(name), r.err
+}
+
+// HasTree reports whether the file includes the module's syntax tree.
+func (f *File) HasTree() bool {
+	return f.sections[sectionNodes].present
+}
+
+// Len returns the number of symbols, without decoding them.
+func (f *File) Len() int {
+	return f.sections[sectionSymbols].length / symbolSize
+}
+
+// Lookup finds the symbol named name by binary search over the encoded
+// symbols, decoding only the one it returns.
+func (f *File) Lookup(name string) (Symbol, bool, error) {
+	r := f.reader()
+	defer r.done()
+	symbols := r.section(sectionSymbols)
+	lo, hi := 0, len(symbols)/symbolSize
+	for lo < hi && r.err == nil {
+		mid := int(uint(lo+hi) >> 1)
+		if r.string(r.uint32(symbols, mid*symbolSize)) < name {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == len(symbols)/symbolSize || r.string(r.uint32(symbols, lo*symbolSize)) != name {
+		return Symbol{}, false, r.err
+	}
+	sym := r.symbol(symbols, lo)
+	return sym, r.err == nil, r.err
+}
+
+// Symbols decodes every symbol, sorted by name.
+func (f *File) Symbols() ([]Symbol, error) {
+	r := f.reader()
+	defer r.done()
+	symbols := r.section(sectionSymbols)
+	out := make([]Symbol, len(symbols)/symbolSize)
+	for i := range out {
+		out[i] = r.symbol(symbols, i)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return out, nil
+}
+
+// Tree decodes the module's syntax tree. It fails if the file has none.
+func (f *File) Tree() (*compact.Tree, error) {
+	if !f.HasTree() {
+		return nil, errors.New("summary: file has no syntax tree")
+	}
+	r := f.reader()
+	defer r.done()
+	t := &compact.Tree{Root: compact.NodeID(r.uint32(r.section(sectionMeta), 4))}
+
+	nodes := r.section(sectionNodes)
+	t.Nodes = make([]compact.Node, len(nodes)/nodeSize)
+	for i := range t.Nodes {
+		b := nodes[i*nodeSize:]
+		t.Nodes[i] = compact.Node{
+			Kind:  compact.Kind(b[0]),
+			Flags: b[1],
+			Token: r.uint32(b, 4),
+			LHS:   r.uint32(b, 8),
+			RHS:   r.uint32(b, 12),
+		}
+	}
+
+	extra := r.section(sectionExtra)
+	t.Extra = make([]uint32, len(extra)/4)
+	for i := range t.Extra {
+		t.Extra[i] = r.uint32(extra, i*4)
+	}
+
+	tokens := r.section(sectionTokens)
+	t.Tokens = make([]compact.Token, len(tokens)/tokenSize)
+	for i := range t.Tokens {
+		b := tokens[i*tokenSize:]
+		t.Tokens[i] = compact.Token{
+			Type:   binary.LittleEndian.Uint16(b),
+			Lit:    r.uint32(b, 4),
+			Offset: r.uint32(b, 8),
+			Line:   r.uint32(b, 12),
+			Column: r.uint32(b, 16),
+		}
+	}
+
+	types := r.section(sectionTypes)
+	t.Types = make([]token.TokenType, len(types)/4)
+	for i := range t.Types {
+		t.Types[i] = token.TokenType(strings.Clone(r.string(r.uint32(types, i*4))))
+	}
+
+	treeStrings := r.section(sectionTreeStrings)
+	t.Strings = make([]string, len(treeStrings)/4)
+	for i := range t.Strings {
+		t.Strings[i] = strings.Clone(r.string(r.uint32(treeStrings, i*4)))
+	}
+
+	ints := r.section(sectionInts)
+	t.Ints = make([]int64, len(ints)/8)
+	for i := range t.Ints {
+		if r.err == nil {
+			t.Ints[i] = int64(binary.LittleEndian.Uint64(ints[i*8:]))
+		}
+	}
+
+	if r.err == nil {
+		r.err = validTree(t)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return t, nil
+}
+
+// Types parses the type of every symbol that has one, keyed by name, in the
+// form types.Options.Builtins takes, so that a program using the module can
+// be checked against it.
+func (f *File) Types() (map[string]types.Type, error) {
+	symbols, err := f.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]types.Type, len(symbols))
+	for _, sym := range symbols {
+		if sym.Type == "" {
+			continue
+		}
+		typ, err := types.Parse(sym.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%w: type of %s: %v", ErrCorrupt, sym.Name, err)
+		}
+		out[sym.Name] = typ
+	}
+	return out, nil
+}
+
+// Summary decodes the whole file.
+func (f *File) Summary() (*Summary, error) {
+	module, err := f.Module()
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := f.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	s := &Summary{Module: module, Symbols: symbols}
+	if f.HasTree() {
+		if s.Tree, err = f.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// validTree checks that the tree is laid out as compact.FromAST lays it
+// out, so that reading it cannot go out of range. The checksums catch
+// damage to a file, not files made up to pass them.
+func validTree(t *compact.Tree) error {
+	if err := t.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return nil
+}
+
+// reader reads sections of a File, recording the first error and returning
+// zero values after it, so decoding code checks for errors once at the end.
+// The file stays open until done is called.
+type reader struct {
+	f   *File
+	err error
+}
+
+func (f *File) reader() *reader {
+	f.open.RLock()
+	return &reader{f: f}
+}
+
+func (r *reader) done() {
+	r.f.open.RUnlock()
+}
+
+// section returns the bytes of a section, verifying its checksum the first
+// time. A missing section reads as empty.
+func (r *reader) section(kind sectionKind) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.f.data == nil {
+		r.err = errors.New("summary: file is closed")
+		return nil
+	}
+	s := r.f.sections[kind]
+	data := r.f.data[s.offset : s.offset+s.length]
+
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	if !r.f.verified[kind] {
+		if crc32.Checksum(data, castagnoli) != s.crc {
+			r.err = fmt.Errorf("%w: section %d checksum mismatch", ErrCorrupt, kind)
+			return nil
+		}
+		r.f.verified[kind] = true
+	}
+	return data
+}
+
+func (r *reader) uint32(b []byte, offset int) uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if offset+4 > len(b) {
+		r.err = fmt.Errorf("%w: truncated section", ErrCorrupt)
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b[offset:])
+}
+
+// string returns string i of the strings section. The result points into
+// the file's memory and must be cloned before it is handed out.
+func (r *reader) string(i uint32) string {
+	table := r.section(sectionStrings)
+	n := r.uint32(table, 0)
+	if r.err != nil {
+		return ""
+	}
+	if i >= n || 4+4*(int(n)+1) > len(table) {
+		r.err = fmt.Errorf("%w: string %d out of range", ErrCorrupt, i)
+		return ""
+	}
+	base := 4 + 4*(int(n)+1)
+	start := base + int(r.uint32(table, 4+4*int(i)))
+	end := base + int(r.uint32(table, 8+4*int(i)))
+	if start > end || end > len(table) {
+		r.err = fmt.Errorf("%w: string %d out of range", ErrCorrupt, i)
+		return ""
+	}
+	if start == end {
+		return ""
+	}
+	return unsafe.String(&table[start], end-start)
+}
+
+func (r *reader) symbol(symbols []byte, i int) Symbol {
+	b := symbols[i*symbolSize : (i+1)*symbolSize]
+	sym := Symbol{
+		Name: strings.Clone(r.string(r.uint32(b, 0))),
+		Kind: SymbolKind(r.uint32(b, 4)),
+		Pos: token.Pos{
+			Offset: int(r.uint32(b, 8)),
+			Line:   int(r.uint32(b, 12)),
+			Column: int(r.uint32(b, 16)),
+		},
+		Type: strings.Clone(r.string(r.uint32(b, 28))),
+	}
+	if sym.Kind > Function && r.err == nil {
+		r.err = fmt.Errorf("%w: bad symbol kind %d", ErrCorrupt, sym.Kind)
+	}
+	first, n := int(r.uint32(b, 20)), int(r.uint32(b, 24))
+	params := r.section(sectionParams)
+	if first+n > len(params)/4 {
+		if r.err == nil {
+			r.err = fmt.Errorf("%w: parameters out of range", ErrCorrupt)
+		}
+		return Symbol{}
+	}
+	if sym.Kind == Function {
+		sym.Params = make([]string, n)
+		for j := range sym.Params {
+			sym.Params[j] = strings.Clone(r.string(r.uint32(params, (first+j)*4)))
+		}
+	}
+	return sym
+}
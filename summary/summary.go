@@ -0,0 +1,103 @@
+// Package summary stores what other compilation units need to know about a
+// module, its exported names and function signatures and optionally its
+// whole syntax tree, in a binary file that can be memory-mapped and read
+// piece by piece. A unit that depends on a module opens the module's
+// summary instead of parsing its source again.
+package summary
+
+import (
+	"mcompiler/ast"
+	"mcompiler/ast/compact"
+	"mcompiler/token"
+	"mcompiler/types"
+	"sort"
+)
+
+type SymbolKind uint8
+
+const (
+	Value SymbolKind = iota
+	Function
+)
+
+func (k SymbolKind) String() string {
+	if k == Function {
+		return "function"
+	}
+	return "value"
+}
+
+// Symbol is a name a module exports. Every top-level let binding is
+// exported; a name bound twice is exported with its last binding.
+//
+// Type is the symbol's type as types.Check infers it, such as
+// `fn(int) => bool` or `fn(a) => array(a)`, or empty if it is not known;
+// types.Parse reads it back.
+type Symbol struct {
+	Name   string
+	Kind   SymbolKind
+	Pos    token.Pos
+	Params []string // parameter names of a Function
+	Type   string
+}
+
+// Summary is the content of a summary file. Symbols are sorted by name, and
+// Tree is nil unless the syntax tree is included.
+type Summary struct {
+	Module  string
+	Symbols []Symbol
+	Tree    *compact.Tree
+}
+
+// Build summarizes a parsed module. With withTree set the summary includes
+// the module's syntax tree, which fails for trees compact cannot hold, such
+// as those with nodes built by parser extensions.
+func Build(module string, program *ast.Program, withTree bool) (*Summary, error) {
+	s := &Summary{Module: module}
+	info := types.Check(program, types.Options{})
+	index := make(map[string]int)
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			continue
+		}
+		sym := Symbol{Name: let.Name.Value, Kind: Value, Pos: let.Name.Pos()}
+		if fn, ok := let.Value.(*ast.FunctionExpression); ok {
+			sym.Kind = Function
+			sym.Params = make([]string, len(fn.Parameters))
+			for i, param := range fn.Parameters {
+				sym.Params[i] = param.Value
+			}
+		}
+		if b, ok := info.Names.Bindings[let.Name]; ok {
+			if t, ok := info.Defs[b.Symbol]; ok {
+				sym.Type = t.String()
+			}
+		}
+		if i, ok := index[sym.Name]; ok {
+			s.Symbols[i] = sym
+			continue
+		}
+		index[sym.Name] = len(s.Symbols)
+		s.Symbols = append(s.Symbols, sym)
+	}
+	sort.Slice(s.Symbols, func(i, j int) bool { return s.Symbols[i].Name < s.Symbols[j].Name })
+
+	if withTree {
+		tree, err := compact.FromAST(program)
+		if err != nil {
+			return nil, err
+		}
+		s.Tree = tree
+	}
+	return s, nil
+}
+
+// Lookup returns the symbol named name.
+func (s *Summary) Lookup(name string) (Symbol, bool) {
+	i := sort.Search(len(s.Symbols), func(i int) bool { return s.Symbols[i].Name >= name })
+	if i < len(s.Symbols) && s.Symbols[i].Name == name {
+		return s.Symbols[i], true
+	}
+	return Symbol{}, false
+}
@@ -0,0 +1,343 @@
+package summary
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/ast/compact"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"mcompiler/types"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const module = `let add = fn(a, b) { a + b };
+let limit = 10;
+add(limit, 2);
+let twice = fn(f, x) => f(f(x));
+let now = fn() { 0 };
+let limit = add(limit, 1);
+if (true) { let hidden = 1; }
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+	return program
+}
+
+func TestBuild(t *testing.T) {
+	s, err := Build("math", parse(t, module), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, sym := range s.Symbols {
+		got = append(got, sym.Kind.String()+" "+sym.Name+"("+strings.Join(sym.Params, ", ")+") "+sym.Pos.String()+" "+sym.Type)
+	}
+	expected := []string{
+		"function add(a, b) 1:5 fn(int, int) => int",
+		"value limit() 6:5 int",
+		"function now() 5:5 fn() => int",
+		"function twice(f, x) 4:5 fn(fn(a) => a, a) => a",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("symbols = %q, want %q", got, expected)
+	}
+	if s.Tree != nil {
+		t.Errorf("summary has a tree without asking for one")
+	}
+	if _, ok := s.Lookup("hidden"); ok {
+		t.Errorf("a binding inside a block is exported")
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	program := parse(t, module)
+	s, err := Build("math", program, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Decode(s.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := f.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, s) {
+		t.Errorf("decoded summary differs:\n%+v\nwant:\n%+v", decoded, s)
+	}
+	if got := decoded.Tree.ToAST().String(); got != program.String() {
+		t.Errorf("decoded tree = %q, want %q", got, program.String())
+	}
+}
+
+func TestOpen(t *testing.T) {
+	s, err := Build("math", parse(t, module), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "math.sum")
+	if err := s.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := f.Module(); name != "math" || err != nil {
+		t.Errorf("Module() = %q, %v", name, err)
+	}
+	if f.Len() != 4 || f.HasTree() {
+		t.Errorf("Len() = %d, HasTree() = %t; want 4, false", f.Len(), f.HasTree())
+	}
+	if _, err := f.Tree(); err == nil {
+		t.Errorf("Tree() of a file without one succeeded")
+	}
+
+	for _, name := range []string{"add", "limit", "now", "twice"} {
+		sym, ok, err := f.Lookup(name)
+		if !ok || err != nil {
+			t.Fatalf("Lookup(%q) = %t, %v", name, ok, err)
+		}
+		want, _ := s.Lookup(name)
+		if !reflect.DeepEqual(sym, want) {
+			t.Errorf("Lookup(%q) = %+v, want %+v", name, sym, want)
+		}
+	}
+	for _, name := range []string{"", "a", "hidden", "zzz"} {
+		if _, ok, err := f.Lookup(name); ok || err != nil {
+			t.Errorf("Lookup(%q) = %t, %v; want not found", name, ok, err)
+		}
+	}
+
+	sym, _, _ := f.Lookup("twice")
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sym.Name != "twice" || sym.Params[1] != "x" {
+		t.Errorf("symbol changed after Close: %+v", sym)
+	}
+	if _, _, err := f.Lookup("add"); err == nil {
+		t.Errorf("Lookup after Close succeeded")
+	}
+}
+
+// A program that uses a module is checked against the types in the
+// module's summary instead of the module's source.
+func TestFile_Types(t *testing.T) {
+	s, err := Build("math", parse(t, module), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Decode(s.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := f.Types()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sym := range s.Symbols {
+		if got := imported[sym.Name].String(); got != sym.Type {
+			t.Errorf("type of %s = %s, want %s", sym.Name, got, sym.Type)
+		}
+	}
+
+	builtins := make(map[string]types.Type)
+	for name, typ := range types.DefaultBuiltins {
+		builtins[name] = typ
+	}
+	for name, typ := range imported {
+		builtins[name] = typ
+	}
+	program := parse(t, "let n = add(limit, 2);\nlet b = twice(fn(x) => !x, true);\nadd(b, now());\n")
+	info := types.Check(program, types.Options{Builtins: builtins})
+	var got []string
+	for _, d := range info.Diagnostics {
+		got = append(got, d.String())
+	}
+	if want := []string{"3:5: expected int, found bool"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("diagnostics = %q, want %q", got, want)
+	}
+}
+
+// A tree made up to pass the checksums is still checked before it is read.
+func TestTree_Malformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(tree *compact.Tree)
+	}{
+		{"root", func(tree *compact.Tree) { tree.Root = 1 }},
+		{"child", func(tree *compact.Tree) { tree.Nodes[len(tree.Nodes)-2].LHS = 1 << 30 }},
+		{"later child", func(tree *compact.Tree) {
+			for i, n := range tree.Nodes {
+				if n.Kind == compact.KindBinary {
+					tree.Nodes[i].LHS = uint32(i)
+				}
+			}
+		}},
+		{"kind", func(tree *compact.Tree) { tree.Nodes[1].Kind = 200 }},
+		{"list", func(tree *compact.Tree) { tree.Nodes[tree.Root].RHS = 1 << 30 }},
+		{"extra", func(tree *compact.Tree) { tree.Extra[0] = 1 << 30 }},
+		{"string", func(tree *compact.Tree) {
+			for i, n := range tree.Nodes {
+				if n.Kind == compact.KindIdentifier {
+					tree.Nodes[i].LHS = uint32(len(tree.Strings))
+				}
+			}
+		}},
+		{"integer", func(tree *compact.Tree) {
+			for i, n := range tree.Nodes {
+				if n.Kind == compact.KindInteger {
+					tree.Nodes[i].LHS = uint32(len(tree.Ints))
+				}
+			}
+		}},
+		{"statement", func(tree *compact.Tree) {
+			root := tree.Nodes[tree.Root]
+			for i, n := range tree.Nodes {
+				if n.Kind == compact.KindInteger {
+					tree.Extra[root.LHS+root.RHS-1] = uint32(i)
+					break
+				}
+			}
+		}},
+	}
+	for _, tt := range tests {
+		s, err := Build("math", parse(t, module), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.damage(s.Tree)
+		f, err := Decode(s.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Tree(); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: Tree() error = %v, want ErrCorrupt", tt.name, err)
+		}
+	}
+}
+
+// Close waits for reads in progress instead of unmapping the memory under
+// them.
+func TestOpen_ConcurrentClose(t *testing.T) {
+	s, err := Build("math", parse(t, module), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "math.sum")
+	if err := s.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := f.Summary(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+}
+
+// Sections are verified when first read, so damage to the tree does not
+// get in the way of looking up symbols.
+func TestLazyVerification(t *testing.T) {
+	s, err := Build("math", parse(t, module), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := s.Encode()
+	f, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := f.sections[sectionNodes]
+	data[nodes.offset+nodes.length-1] ^= 0xFF
+
+	if _, ok, err := f.Lookup("add"); !ok || err != nil {
+		t.Errorf("Lookup with a damaged tree = %t, %v", ok, err)
+	}
+	if _, err := f.Tree(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Tree() error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	s, err := Build("math", parse(t, module), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := s.Encode()
+
+	damage := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), good...))
+	}
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "not a summary file"},
+		{"magic", damage(func(b []byte) []byte { b[1] = 'X'; return b }), "not a summary file"},
+		{"version", damage(func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[8:], Version+1)
+			return b
+		}), fmt.Sprintf("unsupported version %d", Version+1)},
+		{"table", damage(func(b []byte) []byte { b[headerSize+4]++; return b }), "section table checksum mismatch"},
+		{"truncated", good[:len(good)-8], "bad section table"},
+	}
+	for _, tt := range tests {
+		_, err := Decode(tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Decode error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	// Damage to any section is caught when the file is read in full.
+	f, err := Decode(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for kind := sectionStrings; kind < numSections; kind++ {
+		sec := f.sections[kind]
+		if sec.length == 0 {
+			continue
+		}
+		data := damage(func(b []byte) []byte { b[sec.offset] ^= 0x55; return b })
+		df, err := Decode(data)
+		if err != nil {
+			t.Fatalf("section %d: Decode: %v", kind, err)
+		}
+		if _, err := df.Summary(); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("section %d: Summary error = %v, want ErrCorrupt", kind, err)
+		}
+	}
+}
@@ -1,21 +1,50 @@
 package token
 
+import (
+	"fmt"
+	"mcompiler/intern"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Pos
+	Sym     intern.Symbol // set for identifiers and literals when the lexer interns
+}
+
+// End is the position just past the token. Tokens never span lines.
+func (t Token) End() Pos {
+	end := t.Pos
+	end.Offset += len(t.Literal)
+	end.Column += len(t.Literal)
+	return end
+}
+
+// Pos is the position of a token's first byte in the source. Line and
+// Column are 1-based; Column counts bytes.
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT   = "IDENT"
+	INT     = "INT"
+	COMMENT = "COMMENT" // only from lexers asked to keep comments
 
 	COMMA     = "," //
 	SEMICOLON = ";" //
+	COLON     = ":" //
 
 	ASSIGN = "=" //
 	PLUS   = "+" //
@@ -31,6 +60,7 @@ const (
 
 	BANG     = "!"
 	ASTERISK = "*"
+	POWER    = "**"
 	SLASH    = "/"
 	LT       = "<"
 	GT       = ">"
@@ -43,4 +73,7 @@ const (
 
 	EQUAL    = "=="
 	NOTEQUAL = "!="
+
+	PIPE  = "|>"
+	ARROW = "=>"
 )
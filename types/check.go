@@ -0,0 +1,679 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"mcompiler/ast"
+	"mcompiler/resolve"
+	"mcompiler/token"
+	"sort"
+)
+
+// DefaultBuiltins are the types of resolve.DefaultBuiltins, used when
+// Options.Builtins is nil.
+var DefaultBuiltins = func() map[string]Type {
+	a := NewVar()
+	return map[string]Type{
+		"len":   Func([]Type{Array(a)}, Int),
+		"first": Func([]Type{Array(a)}, a),
+		"last":  Func([]Type{Array(a)}, a),
+		"rest":  Func([]Type{Array(a)}, Array(a)),
+		"push":  Func([]Type{Array(a), a}, Array(a)),
+		"puts":  Func([]Type{a}, Null),
+	}
+}()
+
+type Options struct {
+	// Builtins are the names declared before the program, with their
+	// types; every type variable in them is generalized. Nil means
+	// DefaultBuiltins.
+	Builtins map[string]Type
+}
+
+// Diagnostic is a type error. Expected and Actual are set when a value of
+// one type was found where another was needed.
+type Diagnostic struct {
+	Span     ast.Span
+	Msg      string
+	Expected Type
+	Actual   Type
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Span.Start, d.Msg)
+}
+
+// Info is the result of checking a program.
+type Info struct {
+	// Names is the resolution of the program's names the types were
+	// inferred from.
+	Names *resolve.Info
+
+	// Types holds the type of every expression. Where inference could not
+	// tell, the type is or contains a type variable.
+	Types map[ast.Expression]Type
+
+	// Defs holds the type of every declared symbol. A let-bound function
+	// has a generalized type, in which each variable stands for any type.
+	Defs map[*resolve.Symbol]Type
+
+	// Diagnostics are the type errors, in source order. Errors in resolving
+	// names are in Names.
+	Diagnostics []Diagnostic
+}
+
+// generic is the level of a generalized type variable.
+const generic = math.MaxInt
+
+// Check resolves the names in program and infers its types. Function
+// bodies skipped by a lazy parser are parsed on the way.
+//
+// A value bound by let is generalized when it is a function and is never
+// assigned to, so that `let id = fn(x) => x;` can be applied to an int and
+// a bool alike. Any other binding has one type throughout.
+//
+// An annotated let or parameter has the type of its annotation. The type
+// variables an annotation names stand for types inference fills in; they
+// are shared by the annotations of one let, or of the parameters of one
+// function.
+func Check(program *ast.Program, opts Options) *Info {
+	builtins := opts.Builtins
+	if builtins == nil {
+		builtins = DefaultBuiltins
+	}
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	info := &Info{
+		Names: resolve.Program(program, resolve.Options{Builtins: names}),
+		Types: make(map[ast.Expression]Type),
+		Defs:  make(map[*resolve.Symbol]Type),
+	}
+	c := &checker{info: info, builtins: builtins, assigned: make(map[*resolve.Symbol]bool)}
+	ast.InspectType(program, func(assign *ast.AssignExpression) bool {
+		if b, ok := info.Names.Bindings[assign.Name]; ok {
+			c.assigned[b.Symbol] = true
+		}
+		return true
+	})
+
+	for _, stmt := range program.Statements {
+		c.statement(stmt, false)
+	}
+
+	for e, t := range info.Types {
+		info.Types[e] = Resolve(t)
+	}
+	for sym, t := range info.Defs {
+		info.Defs[sym] = Resolve(t)
+	}
+	sort.SliceStable(info.Diagnostics, func(i, j int) bool {
+		return info.Diagnostics[i].Span.Start.Offset < info.Diagnostics[j].Span.Start.Offset
+	})
+	return info
+}
+
+type checker struct {
+	info     *Info
+	builtins map[string]Type
+	assigned map[*resolve.Symbol]bool
+
+	// level is how many lets deep inference is. A variable created at a
+	// deeper level than the let being finished does not occur in any type
+	// outside it, so it can be generalized.
+	level int
+
+	results []Type // result types of the enclosing functions
+	trail   []trailEntry
+}
+
+func (c *checker) newVar() *Var {
+	return &Var{level: c.level}
+}
+
+func (c *checker) errorf(node ast.Node, format string, args ...any) {
+	c.info.Diagnostics = append(c.info.Diagnostics, Diagnostic{
+		Span: ast.Span{Start: node.Pos(), End: node.End()},
+		Msg:  fmt.Sprintf(format, args...),
+	})
+}
+
+// expect unifies the type actual of node with the type expected of it,
+// reporting the two if they do not agree.
+func (c *checker) expect(expected, actual Type, node ast.Node) {
+	err := c.unify(expected, actual)
+	if err == unifyOK {
+		return
+	}
+	expected, actual = Resolve(expected), Resolve(actual)
+	msg := fmt.Sprintf("expected %s, found %s", expected, actual)
+	if err == unifyInfinite {
+		msg += " (the type would contain itself)"
+	}
+	c.info.Diagnostics = append(c.info.Diagnostics, Diagnostic{
+		Span:     ast.Span{Start: node.Pos(), End: node.End()},
+		Msg:      msg,
+		Expected: expected,
+		Actual:   actual,
+	})
+}
+
+// statement infers the types in stmt and returns the value it leaves, and
+// the node that gives it, for the last statement of a function body. The
+// branches of an if must agree only when that value is used.
+func (c *checker) statement(stmt ast.Statement, used bool) (Type, ast.Node) {
+	switch s := stmt.(type) {
+	case *ast.ExpressionStatement:
+		if s.Expression == nil {
+			return Null, s
+		}
+		return c.expression(s.Expression), s.Expression
+	case *ast.LetStatement:
+		c.let(s)
+		return Null, s
+	case *ast.ReturnStatement:
+		var value Type = Null
+		var at ast.Node = s
+		if s.Value != nil {
+			value, at = c.expression(s.Value), s.Value
+		}
+		if len(c.results) > 0 {
+			c.expect(c.results[len(c.results)-1], value, at)
+		}
+		// Nothing after a return is reached, so its value fits anything.
+		return c.newVar(), s
+	case *ast.BlockStatement:
+		return c.block(s, used)
+	case *ast.IfStatement:
+		if s.Condition != nil {
+			c.expect(Bool, c.expression(s.Condition), s.Condition)
+		}
+		if s.Consequence == nil {
+			return Null, s
+		}
+		value, at := c.statement(s.Consequence, used)
+		if s.Alternative == nil {
+			return Null, s
+		}
+		alt, altAt := c.statement(s.Alternative, used)
+		if used {
+			c.expect(value, alt, altAt)
+		}
+		return value, at
+	case nil, *ast.MissingStatement:
+		return Null, stmt
+	default:
+		c.children(stmt)
+		return Null, stmt
+	}
+}
+
+func (c *checker) block(b *ast.BlockStatement, used bool) (Type, ast.Node) {
+	var value Type = Null
+	var at ast.Node = b
+	for i, stmt := range b.Statements {
+		value, at = c.statement(stmt, used && i == len(b.Statements)-1)
+	}
+	return value, at
+}
+
+func (c *checker) let(let *ast.LetStatement) {
+	var sym *resolve.Symbol
+	if b, ok := c.info.Names.Bindings[let.Name]; ok && let.Name != nil {
+		sym = b.Symbol
+	}
+	_, isFunc := let.Value.(*ast.FunctionExpression)
+
+	c.level++
+	declared := c.annotation(let.Type, make(map[string]*Var))
+	// A function sees itself with a single type while its body is
+	// inferred, and a global used by a function above its let already
+	// has one.
+	early, forward := c.info.Defs[sym]
+	if sym != nil && !forward && isFunc {
+		early = declared
+		if early == nil {
+			early = c.newVar()
+		}
+		c.info.Defs[sym] = early
+	}
+	var value Type = c.newVar()
+	if let.Value != nil {
+		value = c.expression(let.Value)
+		if declared != nil {
+			c.expect(declared, value, let.Value)
+		}
+		if early != nil && early != declared {
+			c.expect(early, value, let.Value)
+		}
+	}
+	if declared != nil {
+		value = declared
+	}
+	c.level--
+
+	if sym == nil || forward {
+		c.lower(value)
+		return
+	}
+	if isFunc && !c.assigned[sym] {
+		c.generalize(value)
+	} else {
+		c.lower(value)
+	}
+	c.info.Defs[sym] = value
+}
+
+func (c *checker) expression(expr ast.Expression) Type {
+	t := c.infer(expr)
+	c.info.Types[expr] = t
+	return t
+}
+
+func (c *checker) infer(expr ast.Expression) Type {
+	switch e := expr.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.BooleanLiteral:
+		return Bool
+	case *ast.Identifier:
+		return c.identifier(e)
+	case *ast.UnaryExpression:
+		right := c.operand(e.Right)
+		switch e.Token.Type {
+		case token.BANG:
+			c.expectOperand(Bool, right, e.Right)
+			return Bool
+		case token.MINUS:
+			c.expectOperand(Int, right, e.Right)
+			return Int
+		}
+		return c.newVar()
+	case *ast.BinaryExpression:
+		left, right := c.operand(e.Left), c.operand(e.Right)
+		switch e.Token.Type {
+		case token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.POWER:
+			c.expectOperand(Int, left, e.Left)
+			c.expectOperand(Int, right, e.Right)
+			return Int
+		case token.LT, token.GT:
+			c.expectOperand(Int, left, e.Left)
+			c.expectOperand(Int, right, e.Right)
+			return Bool
+		case token.EQUAL, token.NOTEQUAL:
+			c.expectOperand(left, right, e.Right)
+			return Bool
+		}
+		// An operator added by a parser extension.
+		return c.newVar()
+	case *ast.AssignExpression:
+		value := c.operand(e.Value)
+		if b, ok := c.info.Names.Bindings[e.Name]; ok && e.Name != nil && b.Kind != resolve.Builtin {
+			c.expectOperand(c.symbol(b.Symbol), value, e.Value)
+		}
+		return value
+	case *ast.FunctionInvokeExpression:
+		return c.call(e, nil, nil)
+	case *ast.PipeExpression:
+		left := c.operand(e.Left)
+		if call, ok := e.Right.(*ast.FunctionInvokeExpression); ok {
+			result := c.call(call, e.Left, left)
+			c.info.Types[call] = result
+			return result
+		}
+		if e.Right == nil {
+			return c.newVar()
+		}
+		fn := c.expression(e.Right)
+		return c.apply(e, e.Right.String(), fn, []ast.Expression{e.Left}, []Type{left})
+	case *ast.FunctionExpression:
+		return c.function(e)
+	case nil, *ast.MissingExpression:
+		return c.newVar()
+	default:
+		c.children(expr)
+		return c.newVar()
+	}
+}
+
+// operand infers the type of an operand that may be missing from a tree
+// with errors.
+func (c *checker) operand(expr ast.Expression) Type {
+	if expr == nil {
+		return c.newVar()
+	}
+	return c.expression(expr)
+}
+
+func (c *checker) expectOperand(expected, actual Type, node ast.Expression) {
+	if node != nil {
+		c.expect(expected, actual, node)
+	}
+}
+
+func (c *checker) identifier(ident *ast.Identifier) Type {
+	b, ok := c.info.Names.Bindings[ident]
+	if !ok {
+		return c.newVar()
+	}
+	return c.symbol(b.Symbol)
+}
+
+// symbol returns a fresh instance of the type of sym.
+func (c *checker) symbol(sym *resolve.Symbol) Type {
+	if sym.Kind == resolve.Builtin {
+		if t, ok := c.builtins[sym.Name]; ok {
+			return c.instantiate(t)
+		}
+		return c.newVar()
+	}
+	t, ok := c.info.Defs[sym]
+	if !ok {
+		// A global used in a function body above its let. Its type is not
+		// known yet and is never generalized.
+		t = &Var{level: 0}
+		c.info.Defs[sym] = t
+	}
+	return c.instantiate(t)
+}
+
+// call infers the type of a call, with first as an extra first argument
+// when the call is the right-hand side of a pipe.
+func (c *checker) call(call *ast.FunctionInvokeExpression, first ast.Expression, firstType Type) Type {
+	var fn Type
+	if b, ok := c.info.Names.Calls[call]; ok {
+		fn = c.symbol(b.Symbol)
+	} else {
+		fn = c.newVar()
+	}
+	var args []ast.Expression
+	var types []Type
+	if first != nil {
+		args, types = append(args, first), append(types, firstType)
+	}
+	for _, arg := range call.Arguments {
+		args, types = append(args, arg), append(types, c.operand(arg))
+	}
+	return c.apply(call, call.Token.Literal, fn, args, types)
+}
+
+// apply checks a call of the function named name, of type fn, and returns
+// the type of its result.
+func (c *checker) apply(node ast.Node, name string, fn Type, args []ast.Expression, types []Type) Type {
+	switch f := prune(fn).(type) {
+	case *Function:
+		if len(f.Params) != len(args) {
+			c.errorf(node, "%s expects %s, found %d", name, plural(len(f.Params), "argument"), len(args))
+			return f.Result
+		}
+		for i, arg := range args {
+			c.expectOperand(f.Params[i], types[i], arg)
+		}
+		return f.Result
+	case *Var:
+		result := c.newVar()
+		c.expect(fn, Func(types, result), node)
+		return result
+	default:
+		c.errorf(node, "cannot call %s of type %s", name, Resolve(fn))
+		return c.newVar()
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// annotation converts the type annotation t, or returns nil if there is
+// none. The variables it names are looked up in vars, which holds those of
+// the annotations converted with it.
+func (c *checker) annotation(t ast.TypeExpression, vars map[string]*Var) Type {
+	if t == nil {
+		return nil
+	}
+	typ, err := fromSyntax(t, vars, c.newVar)
+	if err != nil {
+		// Only a tolerant parser leaves a type missing, and it has
+		// reported it.
+		return c.newVar()
+	}
+	return typ
+}
+
+func (c *checker) function(fn *ast.FunctionExpression) Type {
+	t := &Function{Params: make([]Type, len(fn.Parameters)), Result: c.newVar()}
+	vars := make(map[string]*Var)
+	for i := range fn.Parameters {
+		t.Params[i] = c.annotation(fn.ParameterType(i), vars)
+		if t.Params[i] == nil {
+			t.Params[i] = c.newVar()
+		}
+		if b, ok := c.info.Names.Bindings[&fn.Parameters[i]]; ok {
+			c.info.Defs[b.Symbol] = t.Params[i]
+		}
+	}
+
+	c.results = append(c.results, t.Result)
+	switch body := fn.ParseBody().(type) {
+	case *ast.BlockStatement:
+		value, at := c.block(body, true)
+		c.expect(t.Result, value, at)
+	case nil:
+	default:
+		value, at := c.statement(body, true)
+		c.expect(t.Result, value, at)
+	}
+	c.results = c.results[:len(c.results)-1]
+	return t
+}
+
+// children infers the types in a node built by a parser extension, which
+// the checker knows nothing else about.
+func (c *checker) children(node ast.Node) {
+	ast.Walk(childVisitor{c, node}, node)
+}
+
+type childVisitor struct {
+	c    *checker
+	root ast.Node
+}
+
+func (v childVisitor) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case nil:
+	case ast.Expression:
+		if node != v.root {
+			v.c.expression(n)
+			return nil
+		}
+	case ast.Statement:
+		if node != v.root {
+			v.c.statement(n, false)
+			return nil
+		}
+	}
+	return v
+}
+
+type unifyResult uint8
+
+const (
+	unifyOK unifyResult = iota
+	unifyMismatch
+	unifyInfinite
+)
+
+// trailEntry records a variable bound or lowered during one unification,
+// so that a failed unification can be undone and leaves no trace.
+type trailEntry struct {
+	v     *Var
+	level int
+	link  Type
+}
+
+func (c *checker) unify(a, b Type) unifyResult {
+	c.trail = c.trail[:0]
+	err := c.unifyTypes(a, b)
+	if err != unifyOK {
+		for i := len(c.trail) - 1; i >= 0; i-- {
+			e := c.trail[i]
+			e.v.level, e.v.link = e.level, e.link
+		}
+	}
+	c.trail = c.trail[:0]
+	return err
+}
+
+func (c *checker) unifyTypes(a, b Type) unifyResult {
+	a, b = prune(a), prune(b)
+	if a == b {
+		return unifyOK
+	}
+	if v, ok := a.(*Var); ok {
+		return c.bind(v, b)
+	}
+	if v, ok := b.(*Var); ok {
+		return c.bind(v, a)
+	}
+	switch a := a.(type) {
+	case *Con:
+		b, ok := b.(*Con)
+		if !ok || a.Name != b.Name || len(a.Args) != len(b.Args) {
+			return unifyMismatch
+		}
+		for i := range a.Args {
+			if err := c.unifyTypes(a.Args[i], b.Args[i]); err != unifyOK {
+				return err
+			}
+		}
+		return unifyOK
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Params) != len(b.Params) {
+			return unifyMismatch
+		}
+		for i := range a.Params {
+			if err := c.unifyTypes(a.Params[i], b.Params[i]); err != unifyOK {
+				return err
+			}
+		}
+		return c.unifyTypes(a.Result, b.Result)
+	}
+	return unifyMismatch
+}
+
+// bind links v to t, unless t contains v. Variables in t sink to v's level,
+// since t is now reachable wherever v is.
+func (c *checker) bind(v *Var, t Type) unifyResult {
+	if !c.adjust(t, v) {
+		return unifyInfinite
+	}
+	c.trail = append(c.trail, trailEntry{v: v, level: v.level, link: v.link})
+	v.link = t
+	return unifyOK
+}
+
+func (c *checker) adjust(t Type, v *Var) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		if t == v {
+			return false
+		}
+		if t.level > v.level {
+			c.trail = append(c.trail, trailEntry{v: t, level: t.level, link: t.link})
+			t.level = v.level
+		}
+	case *Con:
+		for _, arg := range t.Args {
+			if !c.adjust(arg, v) {
+				return false
+			}
+		}
+	case *Function:
+		for _, p := range t.Params {
+			if !c.adjust(p, v) {
+				return false
+			}
+		}
+		return c.adjust(t.Result, v)
+	}
+	return true
+}
+
+// generalize marks the variables of t created inside the let being
+// finished as standing for any type.
+func (c *checker) generalize(t Type) {
+	visit(t, func(v *Var) {
+		if v.level > c.level {
+			v.level = generic
+		}
+	})
+}
+
+// lower keeps the variables of a binding that is not generalized from
+// being generalized with a later one.
+func (c *checker) lower(t Type) {
+	visit(t, func(v *Var) {
+		if v.level > c.level {
+			v.level = c.level
+		}
+	})
+}
+
+// instantiate returns t with fresh variables for its generalized ones.
+func (c *checker) instantiate(t Type) Type {
+	fresh := make(map[*Var]*Var)
+	var copy func(Type) Type
+	copy = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *Var:
+			if t.level != generic {
+				return t
+			}
+			if fresh[t] == nil {
+				fresh[t] = c.newVar()
+			}
+			return fresh[t]
+		case *Con:
+			if len(t.Args) == 0 {
+				return t
+			}
+			args := make([]Type, len(t.Args))
+			for i, arg := range t.Args {
+				args[i] = copy(arg)
+			}
+			return &Con{Name: t.Name, Args: args}
+		case *Function:
+			params := make([]Type, len(t.Params))
+			for i, p := range t.Params {
+				params[i] = copy(p)
+			}
+			return &Function{Params: params, Result: copy(t.Result)}
+		}
+		return t
+	}
+	return copy(t)
+}
+
+// visit calls f for every unbound variable in t.
+func visit(t Type, f func(*Var)) {
+	switch t := prune(t).(type) {
+	case *Var:
+		f(t)
+	case *Con:
+		for _, arg := range t.Args {
+			visit(arg, f)
+		}
+	case *Function:
+		for _, p := range t.Params {
+			visit(p, f)
+		}
+		visit(t.Result, f)
+	}
+}
@@ -0,0 +1,210 @@
+package types
+
+import (
+	"fmt"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"reflect"
+	"testing"
+)
+
+func check(t *testing.T, input string) *Info {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("errors during parsing: %s", p.Errors())
+	}
+	return Check(program, Options{})
+}
+
+// globals lists the type of every global, in declaration order.
+func globals(info *Info) []string {
+	var out []string
+	for _, sym := range info.Names.Global.Symbols {
+		out = append(out, fmt.Sprintf("%s: %s", sym.Name, info.Defs[sym]))
+	}
+	return out
+}
+
+func diagnostics(info *Info) []string {
+	var out []string
+	for _, d := range info.Diagnostics {
+		out = append(out, d.String())
+	}
+	return out
+}
+
+func TestCheck_Globals(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"let x = 5; let y = x < 2;", []string{"x: int", "y: bool"}},
+		{"let id = fn(x) => x;", []string{"id: fn(a) => a"}},
+		{"let k = fn(x, y) { x };", []string{"k: fn(a, b) => a"}},
+		{"let add = fn(a, b) { a + b };", []string{"add: fn(int, int) => int"}},
+		{"let apply = fn(f, x) { f(x) };", []string{"apply: fn(fn(a) => b, a) => b"}},
+		{"let compose = fn(f, g) { fn(x) { g(f(x)) } };", []string{"compose: fn(fn(a) => b, fn(b) => c) => fn(a) => c"}},
+		{"let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1) };", []string{"fact: fn(int) => int"}},
+		{"let size = fn(xs) { len(xs) + 1 };", []string{"size: fn(array(a)) => int"}},
+		{"let inc = fn(x) => x + 1; let y = 2 |> inc();", []string{"inc: fn(int) => int", "y: int"}},
+		{"let pick = fn(c, x, y) { if (c) { x } else { y } };", []string{"pick: fn(bool, a, a) => a"}},
+		// Function bodies see globals declared further down.
+		{"let f = fn() { g() }; let g = fn() { 1 };", []string{"f: fn() => int", "g: fn() => int"}},
+	}
+	for _, tt := range tests {
+		info := check(t, tt.input)
+		if len(info.Diagnostics) != 0 {
+			t.Errorf("%q: diagnostics: %v", tt.input, diagnostics(info))
+			continue
+		}
+		if got := globals(info); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCheck_LetPolymorphism(t *testing.T) {
+	info := check(t, "let id = fn(x) => x; let a = id(1); let b = id(true);")
+	if len(info.Diagnostics) != 0 {
+		t.Fatalf("diagnostics: %v", diagnostics(info))
+	}
+	want := []string{"id: fn(a) => a", "a: int", "b: bool"}
+	if got := globals(info); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Parameters and assigned bindings are not generalized.
+	for _, input := range []string{
+		"let f = fn(id) { id(1); id(true) };",
+		"let id = fn(x) => x; id = fn(y) => y; id(1); id(true);",
+	} {
+		if info := check(t, input); len(info.Diagnostics) != 1 {
+			t.Errorf("%q: diagnostics: %v", input, diagnostics(info))
+		}
+	}
+}
+
+func TestCheck_Diagnostics(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"5 + true;", []string{"1:5: expected int, found bool"}},
+		{"!5;", []string{"1:2: expected bool, found int"}},
+		{"if (1) { 2 }", []string{"1:5: expected bool, found int"}},
+		{"let x = 1; x = true;", []string{"1:16: expected int, found bool"}},
+		{"let f = fn(a, b) { a }; f(1);", []string{"1:25: f expects 2 arguments, found 1"}},
+		{"let x = 1; x(2);", []string{"1:12: cannot call x of type int"}},
+		{"let f = fn(g) { g(g) };", []string{"1:17: expected a, found fn(a) => b (the type would contain itself)"}},
+		{"let f = fn(c) { if (c) { 1 } else { false } };", []string{"1:37: expected int, found bool"}},
+		{"let f = fn(x) { return x < 1; x + 1 };", []string{"1:31: expected bool, found int"}},
+		{"let inc = fn(x) => x + 1; true |> inc();", []string{"1:27: expected int, found bool"}},
+		// Undefined names are reported by resolve only.
+		{"y + 1;", nil},
+	}
+	for _, tt := range tests {
+		info := check(t, tt.input)
+		if got := diagnostics(info); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.input, got, tt.want)
+		}
+	}
+
+	info := check(t, "5 + true;")
+	d := info.Diagnostics[0]
+	if d.Expected != Int || d.Actual != Bool {
+		t.Errorf("expected/actual = %s/%s, want int/bool", d.Expected, d.Actual)
+	}
+}
+
+func TestCheck_Types(t *testing.T) {
+	info := check(t, "let f = fn(x) { x * 2 }; f(3) < 4;")
+	want := map[string]string{
+		"x":               "int",
+		"(x * 2)":         "int",
+		"f(3)":            "int",
+		"(f(3) < 4)":      "bool",
+		"fn(x){(x * 2);}": "fn(int) => int",
+	}
+	got := make(map[string]string)
+	for e, typ := range info.Types {
+		if _, ok := want[e.String()]; ok {
+			got[e.String()] = typ.String()
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCheck_Annotations(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"let n: int = 1;", []string{"n: int"}},
+		{"let inc = fn(x: int) => x;", []string{"inc: fn(int) => int"}},
+		{"let id: fn(a) => a = fn(x) => x;", []string{"id: fn(a) => a"}},
+		{"let k = fn(x: a, y: a) { x };", []string{"k: fn(a, a) => a"}},
+		{"let second: fn(array(a)) => a = fn(xs) => first(rest(xs));", []string{"second: fn(array(a)) => a"}},
+	}
+	for _, tt := range tests {
+		info := check(t, tt.input)
+		if len(info.Diagnostics) != 0 {
+			t.Errorf("%q: diagnostics: %v", tt.input, diagnostics(info))
+			continue
+		}
+		if got := globals(info); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.input, got, tt.want)
+		}
+	}
+
+	mismatches := []struct {
+		input string
+		want  []string
+	}{
+		{"let n: int = true;", []string{"1:14: expected int, found bool"}},
+		{"let f = fn(b: bool) => b; f(1);", []string{"1:29: expected bool, found int"}},
+		{"let f = fn(x: int) => x; let g: fn(bool) => bool = f;", []string{"1:52: expected fn(bool) => bool, found fn(int) => int"}},
+		{"let f: fn(int) => int = fn(x) => x < 1;", []string{"1:25: expected fn(int) => int, found fn(int) => bool"}},
+		// The annotation holds even where the value is wrong.
+		{"let n: int = true; n + 1;", []string{"1:14: expected int, found bool"}},
+	}
+	for _, tt := range mismatches {
+		info := check(t, tt.input)
+		if got := diagnostics(info); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"int", "int"},
+		{"fn(int, bool) => string", "fn(int, bool) => string"},
+		{"fn(t) => t", "fn(a) => a"},
+		{"fn(fn(x) => y, x) => y", "fn(fn(a) => b, a) => b"},
+		{"fn(array(a), hash(string, a)) => null", "fn(array(a), hash(string, a)) => null"},
+	}
+	for _, tt := range tests {
+		typ, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := typ.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+		if again, err := Parse(typ.String()); err != nil || again.String() != typ.String() {
+			t.Errorf("Parse(%q) = %v, %v; want it back", typ, again, err)
+		}
+	}
+	for _, input := range []string{"fn(int", "array(int"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q): no error", input)
+		}
+	}
+}
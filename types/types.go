@@ -0,0 +1,228 @@
+// Package types infers the types of Monkey programs with Hindley-Milner
+// inference and let-polymorphism, and reports the places where a program
+// uses a value at the wrong type, such as `5 + true` or a call with the
+// wrong number of arguments.
+//
+// A let or a parameter may be annotated with a type, as in
+// `let n: int = 1;`, and the annotation must agree with what is inferred.
+package types
+
+import (
+	"fmt"
+	"mcompiler/ast"
+	"mcompiler/lexer"
+	"mcompiler/parser"
+	"strings"
+)
+
+// Type is one of *Con, *Function or *Var.
+type Type interface {
+	String() string
+	typ()
+}
+
+// Con is a type constructor applied to its arguments: a base type such as
+// int, or a container such as array(int).
+type Con struct {
+	Name string
+	Args []Type
+}
+
+// Function is the type of a function, written fn(int, bool) => int.
+type Function struct {
+	Params []Type
+	Result Type
+}
+
+// Var is a type variable, which stands for a type not yet known or, in a
+// generalized type, for any type.
+type Var struct {
+	level int  // see checker.level; generic marks a generalized variable
+	link  Type // the type the variable was unified with, if any
+}
+
+func (*Con) typ()      {}
+func (*Function) typ() {}
+func (*Var) typ()      {}
+
+var (
+	Int    = &Con{Name: "int"}
+	Bool   = &Con{Name: "bool"}
+	String = &Con{Name: "string"}
+	Null   = &Con{Name: "null"} // the value of a block with nothing to give
+)
+
+func Array(elem Type) *Con { return &Con{Name: "array", Args: []Type{elem}} }
+
+func Hash(key, value Type) *Con { return &Con{Name: "hash", Args: []Type{key, value}} }
+
+func Func(params []Type, result Type) *Function {
+	return &Function{Params: params, Result: result}
+}
+
+// NewVar returns a type variable distinct from every other. In a builtin's
+// type every variable is generalized, so NewVar is how a builtin's type
+// says "any type".
+func NewVar() *Var {
+	return &Var{level: generic}
+}
+
+// prune follows the links of unified variables to the type they stand for.
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.link == nil {
+			return t
+		}
+		t = v.link
+	}
+}
+
+// Resolve returns t with every unified variable replaced by what it was
+// unified with, so the result does not change as inference goes on.
+func Resolve(t Type) Type {
+	switch t := prune(t).(type) {
+	case *Con:
+		if len(t.Args) == 0 {
+			return t
+		}
+		args := make([]Type, len(t.Args))
+		for i, arg := range t.Args {
+			args[i] = Resolve(arg)
+		}
+		return &Con{Name: t.Name, Args: args}
+	case *Function:
+		params := make([]Type, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = Resolve(p)
+		}
+		return &Function{Params: params, Result: Resolve(t.Result)}
+	default:
+		return t
+	}
+}
+
+// Types print in the syntax of parser.ParseType, so Parse reads them back.
+// Variables are named a, b, c and so on in order of appearance.
+
+func (c *Con) String() string      { return format(c) }
+func (f *Function) String() string { return format(f) }
+func (v *Var) String() string      { return format(v) }
+
+func format(t Type) string {
+	p := &printer{names: make(map[*Var]string)}
+	p.print(t)
+	return p.b.String()
+}
+
+type printer struct {
+	b     strings.Builder
+	names map[*Var]string
+}
+
+func (p *printer) print(t Type) {
+	switch t := prune(t).(type) {
+	case *Con:
+		p.b.WriteString(t.Name)
+		if len(t.Args) > 0 {
+			p.list(t.Args)
+		}
+	case *Function:
+		p.b.WriteString("fn")
+		p.list(t.Params)
+		p.b.WriteString(" => ")
+		p.print(t.Result)
+	case *Var:
+		name, ok := p.names[t]
+		if !ok {
+			name = varName(len(p.names))
+			p.names[t] = name
+		}
+		p.b.WriteString(name)
+	}
+}
+
+func (p *printer) list(ts []Type) {
+	p.b.WriteByte('(')
+	for i, t := range ts {
+		if i > 0 {
+			p.b.WriteString(", ")
+		}
+		p.print(t)
+	}
+	p.b.WriteByte(')')
+}
+
+// varName returns a, b, ..., z, a1, b1, ...
+func varName(i int) string {
+	name := string(rune('a' + i%26))
+	if i >= 26 {
+		name += fmt.Sprint(i / 26)
+	}
+	return name
+}
+
+// Parse parses a type written in the syntax of parser.ParseType, see
+// FromSyntax.
+func Parse(src string) (Type, error) {
+	p := parser.New(lexer.New(src))
+	typ := p.ParseType()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("types: %s", p.Errors()[0])
+	}
+	return FromSyntax(typ)
+}
+
+// FromSyntax converts the syntax of a type. The names int, bool, string
+// and null are the base types, and a name applied to arguments, such as
+// array(int), is a constructor. Any other name is a type variable, the
+// same variable wherever the name appears, so `fn(a) => a` is the type of
+// the identity function. The variables are generalized, as in the types of
+// Options.Builtins.
+func FromSyntax(t ast.TypeExpression) (Type, error) {
+	return fromSyntax(t, make(map[string]*Var), NewVar)
+}
+
+// fromSyntax converts t, looking the variables it names up in vars and
+// creating those not there with newVar.
+func fromSyntax(t ast.TypeExpression, vars map[string]*Var, newVar func() *Var) (Type, error) {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		if t.Arguments != nil {
+			args := make([]Type, len(t.Arguments))
+			for i, arg := range t.Arguments {
+				typ, err := fromSyntax(arg, vars, newVar)
+				if err != nil {
+					return nil, err
+				}
+				args[i] = typ
+			}
+			return &Con{Name: t.Name, Args: args}, nil
+		}
+		for _, base := range []*Con{Int, Bool, String, Null} {
+			if t.Name == base.Name {
+				return base, nil
+			}
+		}
+		if vars[t.Name] == nil {
+			vars[t.Name] = newVar()
+		}
+		return vars[t.Name], nil
+	case *ast.FunctionType:
+		fn := &Function{Params: make([]Type, len(t.Parameters))}
+		for i, param := range t.Parameters {
+			typ, err := fromSyntax(param, vars, newVar)
+			if err != nil {
+				return nil, err
+			}
+			fn.Params[i] = typ
+		}
+		result, err := fromSyntax(t.Result, vars, newVar)
+		if err != nil {
+			return nil, err
+		}
+		fn.Result = result
+		return fn, nil
+	}
+	return nil, fmt.Errorf("types: cannot convert %T", t)
+}